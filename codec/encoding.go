@@ -62,3 +62,11 @@ func DecodeJsonCopied(dst any, src io.Reader) (*bytes.Buffer, error) {
 	defer sio.PutReader(sr)
 	return bb, sr.Decode(dst)
 }
+
+// DecodeJsonValidated read src with json bytes then decode it into dst.
+// It runs struct-tag validation on dst after decoding and returns *sio.ValidationError when dst is invalid.
+func DecodeJsonValidated(dst any, src io.Reader) error {
+	sr := sio.GetReader(src, sio.SetJsonDecoder(), sio.WithValidation())
+	defer sio.PutReader(sr)
+	return sr.Decode(dst)
+}
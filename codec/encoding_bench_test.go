@@ -5,6 +5,7 @@ import (
 	"crypto/hmac"
 	"crypto/sha256"
 	"encoding/hex"
+	"encoding/json"
 	"testing"
 )
 
@@ -47,3 +48,69 @@ func BenchmarkHmacSha256HexEncoded_LargeMsg(b *testing.B) {
 		_, _ = HmacSha256HexEncoded(secret, msg)
 	}
 }
+
+type _benchStruct struct {
+	ID           int    `json:"id"`
+	EmailAddress string `json:"email_address"`
+	Name         string `json:"name"`
+	Borrowed     bool   `json:"borrowed"`
+	BookID       int    `json:"book_id"`
+}
+
+var _benchJson = []byte(`{"id":1,"email_address":"asdf","name":"asdf","borrowed":false,"book_id":23}`)
+
+func BenchmarkDecodeJson(b *testing.B) {
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		var s _benchStruct
+		_ = DecodeJson(&s, bytes.NewReader(_benchJson))
+	}
+}
+
+func BenchmarkDecode(b *testing.B) {
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		_, _ = Decode[_benchStruct](bytes.NewReader(_benchJson), FormatJson)
+	}
+}
+
+func BenchmarkUnmarshal_Basic(b *testing.B) {
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		var s _benchStruct
+		_ = json.Unmarshal(_benchJson, &s)
+	}
+}
+
+func BenchmarkUnmarshal(b *testing.B) {
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		_, _ = Unmarshal[_benchStruct](FormatJson, _benchJson)
+	}
+}
+
+func BenchmarkEncodeJson(b *testing.B) {
+	s := _benchStruct{ID: 1, EmailAddress: "asdf", Name: "asdf", BookID: 23}
+	var buf bytes.Buffer
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		buf.Reset()
+		_ = EncodeJson(&buf, &s)
+	}
+}
+
+func BenchmarkMarshal_Basic(b *testing.B) {
+	s := _benchStruct{ID: 1, EmailAddress: "asdf", Name: "asdf", BookID: 23}
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		_, _ = json.Marshal(&s)
+	}
+}
+
+func BenchmarkMarshal(b *testing.B) {
+	s := _benchStruct{ID: 1, EmailAddress: "asdf", Name: "asdf", BookID: 23}
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		_, _ = Marshal(FormatJson, &s)
+	}
+}
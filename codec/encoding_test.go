@@ -7,6 +7,7 @@ import (
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+	"github.com/wonksing/si/v2/sio"
 )
 
 func Test_EncodeJson(t *testing.T) {
@@ -77,3 +78,31 @@ func Test_DecodeJsonCopied(t *testing.T) {
 	assert.EqualValues(t, "asdf", out["email_address"].(string))
 	assert.EqualValues(t, v, copied.Bytes())
 }
+
+func Test_DecodeJsonValidated(t *testing.T) {
+	type _testStruct struct {
+		ID    int    `json:"id" validate:"required"`
+		Email string `json:"email_address" validate:"required,email"`
+	}
+
+	t.Run("succeed", func(t *testing.T) {
+		buf := bytes.NewBufferString(`{"id":1,"email_address":"wonk@wonk.org"}`)
+		var out _testStruct
+		err := DecodeJsonValidated(&out, buf)
+		require.Nil(t, err)
+		assert.EqualValues(t, 1, out.ID)
+	})
+
+	t.Run("invalid", func(t *testing.T) {
+		buf := bytes.NewBufferString(`{"id":1,"email_address":"asdf"}`)
+		var out _testStruct
+		err := DecodeJsonValidated(&out, buf)
+		require.NotNil(t, err)
+
+		var ve *sio.ValidationError
+		require.ErrorAs(t, err, &ve)
+		require.Len(t, ve.Errors, 1)
+		assert.EqualValues(t, "email_address", ve.Errors[0].Field)
+		assert.EqualValues(t, "email", ve.Errors[0].Tag)
+	})
+}
@@ -0,0 +1,103 @@
+package codec
+
+import (
+	"bytes"
+	"errors"
+	"io"
+
+	"github.com/wonksing/si/v2/sio"
+)
+
+// Format is a data format that sio's Reader and Writer can decode and encode.
+type Format int
+
+const (
+	// FormatDefault reads and writes []byte or string(or pointer to these two) as they are.
+	FormatDefault Format = iota
+	// FormatJson encodes and decodes json.
+	FormatJson
+)
+
+// String returns name of f.
+func (f Format) String() string {
+	switch f {
+	case FormatDefault:
+		return "default"
+	case FormatJson:
+		return "json"
+	}
+	return "unknown"
+}
+
+var ErrUnsupportedFormat = errors.New("unsupported format")
+
+// option slices are created once so that passing them to the pools doesn't allocate.
+// Reader and Writer fall back to the default decoder and encoder when no option is given.
+var (
+	_jsonReaderOpts = []sio.ReaderOption{sio.SetJsonDecoder()}
+	_jsonWriterOpts = []sio.WriterOption{sio.SetJsonEncoder()}
+)
+
+func readerOpts(format Format) ([]sio.ReaderOption, error) {
+	switch format {
+	case FormatDefault:
+		return nil, nil
+	case FormatJson:
+		return _jsonReaderOpts, nil
+	}
+	return nil, ErrUnsupportedFormat
+}
+
+func writerOpts(format Format) ([]sio.WriterOption, error) {
+	switch format {
+	case FormatDefault:
+		return nil, nil
+	case FormatJson:
+		return _jsonWriterOpts, nil
+	}
+	return nil, ErrUnsupportedFormat
+}
+
+// Decode reads r in format then decodes it into a value of type T.
+func Decode[T any](r io.Reader, format Format) (T, error) {
+	var v T
+	opts, err := readerOpts(format)
+	if err != nil {
+		return v, err
+	}
+
+	sr := sio.GetReader(r, opts...)
+	defer sio.PutReader(sr)
+	err = sr.Decode(&v)
+	return v, err
+}
+
+// Marshal encodes v in format and returns the encoded bytes.
+// Unlike EncodeJson, a trailing newline is not appended to json.
+func Marshal(format Format, v any) ([]byte, error) {
+	opts, err := writerOpts(format)
+	if err != nil {
+		return nil, err
+	}
+
+	sw, buf := sio.GetWriterAndBuffer(opts...)
+	defer sio.PutWriterAndBuffer(sw, buf)
+	if err = sw.EncodeFlush(v); err != nil {
+		return nil, err
+	}
+
+	b := buf.Bytes()
+	if format == FormatJson {
+		b = bytes.TrimSuffix(b, []byte{'\n'})
+	}
+	res := make([]byte, len(b))
+	copy(res, b)
+	return res, nil
+}
+
+// Unmarshal decodes b in format into a value of type T.
+func Unmarshal[T any](format Format, b []byte) (T, error) {
+	br := sio.GetBytesReader(b)
+	defer sio.PutBytesReader(br)
+	return Decode[T](br, format)
+}
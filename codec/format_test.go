@@ -0,0 +1,96 @@
+package codec
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFormat_String(t *testing.T) {
+	assert.EqualValues(t, "default", FormatDefault.String())
+	assert.EqualValues(t, "json", FormatJson.String())
+	assert.EqualValues(t, "unknown", Format(-1).String())
+}
+
+func Test_Decode(t *testing.T) {
+	type _testStruct struct {
+		Msg string `json:"msg"`
+	}
+
+	t.Run("json", func(t *testing.T) {
+		buf := bytes.NewBufferString(`{"msg":"hello world"}`)
+		res, err := Decode[_testStruct](buf, FormatJson)
+		require.Nil(t, err)
+		assert.EqualValues(t, "hello world", res.Msg)
+	})
+
+	t.Run("default", func(t *testing.T) {
+		buf := bytes.NewBufferString("hello world")
+		res, err := Decode[string](buf, FormatDefault)
+		require.Nil(t, err)
+		assert.EqualValues(t, "hello world", res)
+	})
+
+	t.Run("unsupported", func(t *testing.T) {
+		buf := bytes.NewBufferString("hello world")
+		_, err := Decode[string](buf, Format(-1))
+		require.ErrorIs(t, err, ErrUnsupportedFormat)
+	})
+}
+
+func Test_Marshal(t *testing.T) {
+	type _testStruct struct {
+		Msg string `json:"msg"`
+	}
+
+	t.Run("json", func(t *testing.T) {
+		src := _testStruct{Msg: "hello world"}
+		res, err := Marshal(FormatJson, &src)
+		require.Nil(t, err)
+		expected, _ := json.Marshal(&src)
+		assert.EqualValues(t, expected, res)
+	})
+
+	t.Run("default", func(t *testing.T) {
+		res, err := Marshal(FormatDefault, "hello world")
+		require.Nil(t, err)
+		assert.EqualValues(t, []byte("hello world"), res)
+	})
+
+	t.Run("default-fail", func(t *testing.T) {
+		_, err := Marshal(FormatDefault, 1)
+		require.NotNil(t, err)
+	})
+
+	t.Run("unsupported", func(t *testing.T) {
+		_, err := Marshal(Format(-1), "hello world")
+		require.ErrorIs(t, err, ErrUnsupportedFormat)
+	})
+}
+
+func Test_Unmarshal(t *testing.T) {
+	type _testStruct struct {
+		Msg string `json:"msg"`
+	}
+
+	t.Run("json", func(t *testing.T) {
+		res, err := Unmarshal[_testStruct](FormatJson, []byte(`{"msg":"hello world"}`))
+		require.Nil(t, err)
+		assert.EqualValues(t, "hello world", res.Msg)
+	})
+
+	t.Run("json-map", func(t *testing.T) {
+		res, err := Unmarshal[map[string]any](FormatJson, []byte(`{"msg":"hello world"}`))
+		require.Nil(t, err)
+		assert.EqualValues(t, "hello world", res["msg"])
+	})
+
+	t.Run("default", func(t *testing.T) {
+		res, err := Unmarshal[[]byte](FormatDefault, []byte("hello world"))
+		require.Nil(t, err)
+		assert.EqualValues(t, []byte("hello world"), res)
+	})
+}
@@ -0,0 +1,51 @@
+package sign
+
+import "crypto/ed25519"
+
+// Ed25519Signer signs messages with an ed25519 private key.
+type Ed25519Signer struct {
+	key ed25519.PrivateKey
+}
+
+// NewEd25519Signer returns Ed25519Signer with key.
+func NewEd25519Signer(key ed25519.PrivateKey) (*Ed25519Signer, error) {
+	if len(key) != ed25519.PrivateKeySize {
+		return nil, ErrInvalidKey
+	}
+	return &Ed25519Signer{key: key}, nil
+}
+
+// Sign returns signature of message.
+func (s *Ed25519Signer) Sign(message []byte) ([]byte, error) {
+	return ed25519.Sign(s.key, message), nil
+}
+
+// Verify verifies signature with the public key of s.
+func (s *Ed25519Signer) Verify(message, signature []byte) error {
+	return verifyEd25519(s.key.Public().(ed25519.PublicKey), message, signature)
+}
+
+// Ed25519Verifier verifies messages with an ed25519 public key.
+type Ed25519Verifier struct {
+	key ed25519.PublicKey
+}
+
+// NewEd25519Verifier returns Ed25519Verifier with key.
+func NewEd25519Verifier(key ed25519.PublicKey) (*Ed25519Verifier, error) {
+	if len(key) != ed25519.PublicKeySize {
+		return nil, ErrInvalidKey
+	}
+	return &Ed25519Verifier{key: key}, nil
+}
+
+// Verify verifies signature of message.
+func (v *Ed25519Verifier) Verify(message, signature []byte) error {
+	return verifyEd25519(v.key, message, signature)
+}
+
+func verifyEd25519(key ed25519.PublicKey, message, signature []byte) error {
+	if !ed25519.Verify(key, message, signature) {
+		return ErrInvalidSignature
+	}
+	return nil
+}
@@ -0,0 +1,32 @@
+package sign
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestEd25519(t *testing.T) {
+	pub, priv, err := ed25519.GenerateKey(rand.Reader)
+	require.Nil(t, err)
+
+	s, err := NewEd25519Signer(priv)
+	require.Nil(t, err)
+	v, err := NewEd25519Verifier(pub)
+	require.Nil(t, err)
+
+	msg := []byte("hello world")
+	sig, err := s.Sign(msg)
+	require.Nil(t, err)
+
+	require.Nil(t, v.Verify(msg, sig))
+	require.Nil(t, s.Verify(msg, sig))
+	require.ErrorIs(t, v.Verify([]byte("hello world!"), sig), ErrInvalidSignature)
+
+	_, err = NewEd25519Signer(priv[:10])
+	require.ErrorIs(t, err, ErrInvalidKey)
+	_, err = NewEd25519Verifier(pub[:10])
+	require.ErrorIs(t, err, ErrInvalidKey)
+}
@@ -0,0 +1,67 @@
+package sign
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/sha512"
+	"hash"
+	"sync"
+)
+
+// Hmac signs and verifies messages with a secret key.
+// Hashes are pooled per Hmac so it is safe for concurrent use.
+type Hmac struct {
+	pool sync.Pool
+}
+
+// NewHmac returns Hmac with h and secret.
+func NewHmac(h func() hash.Hash, secret []byte) *Hmac {
+	key := make([]byte, len(secret))
+	copy(key, secret)
+
+	return &Hmac{
+		pool: sync.Pool{
+			New: func() interface{} {
+				return hmac.New(h, key)
+			},
+		},
+	}
+}
+
+// NewHmacSha256 returns Hmac with sha256.
+func NewHmacSha256(secret []byte) *Hmac {
+	return NewHmac(sha256.New, secret)
+}
+
+// NewHmacSha512 returns Hmac with sha512.
+func NewHmacSha512(secret []byte) *Hmac {
+	return NewHmac(sha512.New, secret)
+}
+
+// Sign returns mac of message.
+func (s *Hmac) Sign(message []byte) ([]byte, error) {
+	hm := s.pool.Get().(hash.Hash)
+	defer s.put(hm)
+
+	if _, err := hm.Write(message); err != nil {
+		return nil, err
+	}
+	return hm.Sum(nil), nil
+}
+
+// Verify compares mac of message with signature in constant time.
+func (s *Hmac) Verify(message, signature []byte) error {
+	expected, err := s.Sign(message)
+	if err != nil {
+		return err
+	}
+	if !hmac.Equal(expected, signature) {
+		return ErrInvalidSignature
+	}
+	return nil
+}
+
+func (s *Hmac) put(hm hash.Hash) {
+	hm.Reset()
+	s.pool.Put(hm)
+}
@@ -0,0 +1,68 @@
+package sign
+
+import (
+	"encoding/hex"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHmac_Sign(t *testing.T) {
+	t.Run("sha256", func(t *testing.T) {
+		s := NewHmacSha256([]byte("1234"))
+		res, err := s.Sign([]byte("my message"))
+		require.Nil(t, err)
+		assert.EqualValues(t, "34420f26f2612cb4e0a812c5e39f656390e4f6c91699d44303425e37bb979d0a", hex.EncodeToString(res))
+
+		// hash must be reset before it is reused
+		res, err = s.Sign([]byte("my message"))
+		require.Nil(t, err)
+		assert.EqualValues(t, "34420f26f2612cb4e0a812c5e39f656390e4f6c91699d44303425e37bb979d0a", hex.EncodeToString(res))
+	})
+
+	t.Run("sha512", func(t *testing.T) {
+		s := NewHmacSha512([]byte("key"))
+		res, err := s.Sign([]byte("The quick brown fox jumps over the lazy dog"))
+		require.Nil(t, err)
+		assert.EqualValues(t, "b42af09057bac1e2d41708e48a902e09b5ff7f12ab428a4fe86653c73dd248fb82f948a549f7b791a5b41915ee4d1ec3935357e4e2317250d0372afa2ebeeb3a", hex.EncodeToString(res))
+	})
+}
+
+func TestHmac_Verify(t *testing.T) {
+	s := NewHmacSha512([]byte("1234"))
+	sig, err := s.Sign([]byte("my message"))
+	require.Nil(t, err)
+
+	require.Nil(t, s.Verify([]byte("my message"), sig))
+	require.ErrorIs(t, s.Verify([]byte("my message2"), sig), ErrInvalidSignature)
+	require.ErrorIs(t, NewHmacSha512([]byte("4321")).Verify([]byte("my message"), sig), ErrInvalidSignature)
+}
+
+func TestHmac_Concurrency(t *testing.T) {
+	s := NewHmacSha256([]byte("1234"))
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				res, err := s.Sign([]byte("my message"))
+				assert.Nil(t, err)
+				assert.EqualValues(t, "34420f26f2612cb4e0a812c5e39f656390e4f6c91699d44303425e37bb979d0a", hex.EncodeToString(res))
+			}
+		}()
+	}
+	wg.Wait()
+}
+
+func BenchmarkHmac_Sign(b *testing.B) {
+	s := NewHmacSha256([]byte("1234"))
+	msg := []byte("asdf")
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		_, _ = s.Sign(msg)
+	}
+}
@@ -0,0 +1,111 @@
+package sign
+
+import (
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"hash"
+	"sync"
+)
+
+// RsaPssSigner signs messages with an RSA private key using RSASSA-PSS.
+type RsaPssSigner struct {
+	key    *rsa.PrivateKey
+	digest *digester
+	opts   *rsa.PSSOptions
+}
+
+// NewRsaPssSigner returns RsaPssSigner with key and h. h must be available, eg. crypto.SHA256.
+func NewRsaPssSigner(key *rsa.PrivateKey, h crypto.Hash) (*RsaPssSigner, error) {
+	if key == nil {
+		return nil, ErrInvalidKey
+	}
+	if !h.Available() {
+		return nil, ErrUnsupportedHash
+	}
+	return &RsaPssSigner{
+		key:    key,
+		digest: newDigester(h),
+		opts:   &rsa.PSSOptions{SaltLength: rsa.PSSSaltLengthEqualsHash, Hash: h},
+	}, nil
+}
+
+// Sign returns signature of message.
+func (s *RsaPssSigner) Sign(message []byte) ([]byte, error) {
+	d, err := s.digest.sum(message)
+	if err != nil {
+		return nil, err
+	}
+	return rsa.SignPSS(rand.Reader, s.key, s.opts.Hash, d, s.opts)
+}
+
+// Verify verifies signature with the public key of s.
+func (s *RsaPssSigner) Verify(message, signature []byte) error {
+	return verifyPss(&s.key.PublicKey, s.digest, s.opts, message, signature)
+}
+
+// RsaPssVerifier verifies messages with an RSA public key using RSASSA-PSS.
+type RsaPssVerifier struct {
+	key    *rsa.PublicKey
+	digest *digester
+	opts   *rsa.PSSOptions
+}
+
+// NewRsaPssVerifier returns RsaPssVerifier with key and h.
+func NewRsaPssVerifier(key *rsa.PublicKey, h crypto.Hash) (*RsaPssVerifier, error) {
+	if key == nil {
+		return nil, ErrInvalidKey
+	}
+	if !h.Available() {
+		return nil, ErrUnsupportedHash
+	}
+	return &RsaPssVerifier{
+		key:    key,
+		digest: newDigester(h),
+		opts:   &rsa.PSSOptions{SaltLength: rsa.PSSSaltLengthAuto, Hash: h},
+	}, nil
+}
+
+// Verify verifies signature of message.
+func (v *RsaPssVerifier) Verify(message, signature []byte) error {
+	return verifyPss(v.key, v.digest, v.opts, message, signature)
+}
+
+func verifyPss(key *rsa.PublicKey, digest *digester, opts *rsa.PSSOptions, message, signature []byte) error {
+	d, err := digest.sum(message)
+	if err != nil {
+		return err
+	}
+	if err = rsa.VerifyPSS(key, opts.Hash, d, signature, opts); err != nil {
+		return ErrInvalidSignature
+	}
+	return nil
+}
+
+// digester pools hashes of a crypto.Hash.
+type digester struct {
+	pool sync.Pool
+}
+
+func newDigester(h crypto.Hash) *digester {
+	return &digester{
+		pool: sync.Pool{
+			New: func() interface{} {
+				return h.New()
+			},
+		},
+	}
+}
+
+func (d *digester) sum(message []byte) ([]byte, error) {
+	h := d.pool.Get().(hash.Hash)
+	defer func() {
+		h.Reset()
+		d.pool.Put(h)
+	}()
+
+	if _, err := h.Write(message); err != nil {
+		return nil, err
+	}
+	return h.Sum(nil), nil
+}
@@ -0,0 +1,33 @@
+package sign
+
+import (
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestRsaPss(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.Nil(t, err)
+
+	s, err := NewRsaPssSigner(key, crypto.SHA256)
+	require.Nil(t, err)
+	v, err := NewRsaPssVerifier(&key.PublicKey, crypto.SHA256)
+	require.Nil(t, err)
+
+	msg := []byte("hello world")
+	sig, err := s.Sign(msg)
+	require.Nil(t, err)
+
+	require.Nil(t, v.Verify(msg, sig))
+	require.Nil(t, s.Verify(msg, sig))
+	require.ErrorIs(t, v.Verify([]byte("hello world!"), sig), ErrInvalidSignature)
+
+	_, err = NewRsaPssSigner(nil, crypto.SHA256)
+	require.ErrorIs(t, err, ErrInvalidKey)
+	_, err = NewRsaPssVerifier(&key.PublicKey, crypto.Hash(0))
+	require.ErrorIs(t, err, ErrUnsupportedHash)
+}
@@ -0,0 +1,105 @@
+// Package sign provides signers and verifiers of messages such as http request bodies.
+package sign
+
+import (
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+)
+
+var (
+	ErrInvalidSignature    = errors.New("invalid signature")
+	ErrUnsupportedEncoding = errors.New("unsupported encoding")
+	ErrUnsupportedHash     = errors.New("unsupported hash")
+	ErrInvalidKey          = errors.New("invalid key")
+)
+
+// Signer signs a message.
+type Signer interface {
+	Sign(message []byte) ([]byte, error)
+}
+
+// Verifier verifies a signature of a message.
+// It returns ErrInvalidSignature when signature doesn't match.
+type Verifier interface {
+	Verify(message, signature []byte) error
+}
+
+// SignVerifier wraps Signer and Verifier.
+type SignVerifier interface {
+	Signer
+	Verifier
+}
+
+// Encoding is a text encoding of a signature.
+type Encoding int
+
+const (
+	EncodingHex Encoding = iota
+	EncodingBase64
+	EncodingBase64Url
+	EncodingRawBase64Url
+)
+
+// EncodeToString encodes sig with enc.
+func EncodeToString(enc Encoding, sig []byte) (string, error) {
+	switch enc {
+	case EncodingHex:
+		return hex.EncodeToString(sig), nil
+	case EncodingBase64:
+		return base64.StdEncoding.EncodeToString(sig), nil
+	case EncodingBase64Url:
+		return base64.URLEncoding.EncodeToString(sig), nil
+	case EncodingRawBase64Url:
+		return base64.RawURLEncoding.EncodeToString(sig), nil
+	}
+	return "", ErrUnsupportedEncoding
+}
+
+// DecodeString decodes sig encoded with enc.
+func DecodeString(enc Encoding, sig string) ([]byte, error) {
+	switch enc {
+	case EncodingHex:
+		return hex.DecodeString(sig)
+	case EncodingBase64:
+		return base64.StdEncoding.DecodeString(sig)
+	case EncodingBase64Url:
+		return base64.URLEncoding.DecodeString(sig)
+	case EncodingRawBase64Url:
+		return base64.RawURLEncoding.DecodeString(sig)
+	}
+	return nil, ErrUnsupportedEncoding
+}
+
+// SignToString signs message with s then encodes the signature with enc.
+func SignToString(s Signer, enc Encoding, message []byte) (string, error) {
+	sig, err := s.Sign(message)
+	if err != nil {
+		return "", err
+	}
+	return EncodeToString(enc, sig)
+}
+
+// VerifyString decodes sig with enc then verifies it with v.
+// A malformed sig is reported as ErrInvalidSignature.
+func VerifyString(v Verifier, enc Encoding, message []byte, sig string) error {
+	b, err := DecodeString(enc, sig)
+	if err != nil {
+		if err == ErrUnsupportedEncoding {
+			return err
+		}
+		return ErrInvalidSignature
+	}
+	return v.Verify(message, b)
+}
+
+// Equal compares a and b in constant time.
+func Equal(a, b []byte) bool {
+	return subtle.ConstantTimeCompare(a, b) == 1
+}
+
+// EqualString compares a and b in constant time.
+func EqualString(a, b string) bool {
+	return subtle.ConstantTimeCompare([]byte(a), []byte(b)) == 1
+}
@@ -0,0 +1,65 @@
+package sign
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_EncodeToString(t *testing.T) {
+	sig := []byte{0xfb, 0xff, 0x01}
+
+	res, err := EncodeToString(EncodingHex, sig)
+	require.Nil(t, err)
+	assert.EqualValues(t, "fbff01", res)
+
+	res, err = EncodeToString(EncodingBase64, sig)
+	require.Nil(t, err)
+	assert.EqualValues(t, "+/8B", res)
+
+	res, err = EncodeToString(EncodingBase64Url, sig)
+	require.Nil(t, err)
+	assert.EqualValues(t, "-_8B", res)
+
+	res, err = EncodeToString(EncodingRawBase64Url, []byte{0xfb})
+	require.Nil(t, err)
+	assert.EqualValues(t, "-w", res)
+
+	_, err = EncodeToString(Encoding(-1), sig)
+	require.ErrorIs(t, err, ErrUnsupportedEncoding)
+}
+
+func Test_DecodeString(t *testing.T) {
+	for _, enc := range []Encoding{EncodingHex, EncodingBase64, EncodingBase64Url, EncodingRawBase64Url} {
+		s, err := EncodeToString(enc, []byte("hello world"))
+		require.Nil(t, err)
+		res, err := DecodeString(enc, s)
+		require.Nil(t, err)
+		assert.EqualValues(t, []byte("hello world"), res)
+	}
+
+	_, err := DecodeString(Encoding(-1), "")
+	require.ErrorIs(t, err, ErrUnsupportedEncoding)
+}
+
+func Test_SignToString_VerifyString(t *testing.T) {
+	s := NewHmacSha256([]byte("asdf"))
+
+	res, err := SignToString(s, EncodingHex, []byte("hello world"))
+	require.Nil(t, err)
+	assert.EqualValues(t, "2c78fedf60d1f955bf0c9e14ed6b332a6efb6e5668fc6aa067257558cdbb7d6d", res)
+
+	require.Nil(t, VerifyString(s, EncodingHex, []byte("hello world"), res))
+	require.ErrorIs(t, VerifyString(s, EncodingHex, []byte("hello world!"), res), ErrInvalidSignature)
+	require.ErrorIs(t, VerifyString(s, EncodingHex, []byte("hello world"), "not-hex"), ErrInvalidSignature)
+	require.ErrorIs(t, VerifyString(s, Encoding(-1), []byte("hello world"), res), ErrUnsupportedEncoding)
+}
+
+func Test_Equal(t *testing.T) {
+	assert.True(t, Equal([]byte("asdf"), []byte("asdf")))
+	assert.False(t, Equal([]byte("asdf"), []byte("asdq")))
+	assert.False(t, Equal([]byte("asdf"), []byte("asd")))
+	assert.True(t, EqualString("asdf", "asdf"))
+	assert.False(t, EqualString("asdf", "qwer"))
+}
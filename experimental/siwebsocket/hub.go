@@ -197,6 +197,16 @@ func (h *WsHub) Wait() {
 
 var ErrHubClosed = errors.New("hub is closed")
 
+// HealthCheck returns ErrHubClosed if h is stopping or stopped.
+func (h *WsHub) HealthCheck(ctx context.Context) error {
+	select {
+	case <-h.clientDone:
+		return ErrHubClosed
+	default:
+		return nil
+	}
+}
+
 func (h *WsHub) Add(client Client) error {
 	select {
 	case <-h.clientDone:
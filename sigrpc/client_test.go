@@ -10,6 +10,7 @@ import (
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 	pb "github.com/wonksing/si/v2/sigrpc/tests/protos"
+	"github.com/wonksing/si/v2/sitls"
 	"google.golang.org/protobuf/types/known/timestamppb"
 )
 
@@ -51,6 +52,30 @@ func TestClient(t *testing.T) {
 	})
 }
 
+func TestClient_TLS(t *testing.T) {
+	serverConf, err := sitls.NewConfig(
+		sitls.WithReloadingCertificate("./tests/certs/server.crt", "./tests/certs/server.key", time.Minute),
+	)
+	require.Nil(t, err)
+	lis, err := TcpListener("127.0.0.1:0")
+	require.Nil(t, err)
+	server, err := NewServer(lis, WithTLSConfigCreds(serverConf))
+	require.Nil(t, err)
+	defer server.Close()
+	pb.RegisterStudentServer(server, &studentGrpcServer{})
+	go server.Start()
+
+	clientConf, err := sitls.NewConfig(sitls.WithRootCAFile("./tests/certs/rootCA.crt"), sitls.WithServerName("localhost"))
+	require.Nil(t, err)
+	client, err := NewClient(lis.Addr().String(), WithTLSConfigTransportCreds(clientConf))
+	require.Nil(t, err)
+	defer client.Close()
+
+	res, err := pb.NewStudentClient(client).Read(context.Background(), &pb.StudentRequest{Name: "wonka"})
+	require.Nil(t, err)
+	require.EqualValues(t, 200, res.Status)
+}
+
 type studentGrpcServer struct {
 	pb.StudentServer
 }
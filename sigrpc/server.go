@@ -24,6 +24,12 @@ func WithX509KeyPair(certPemFile, certKeyFile string) (grpc.ServerOption, error)
 	return grpc.Creds(credentials.NewServerTLSFromCert(&cert)), nil
 }
 
+// WithTLSConfigCreds serves TLS of c, which can be built by sitls.NewConfig to reload certificates
+// and verify client certificates.
+func WithTLSConfigCreds(c *tls.Config) grpc.ServerOption {
+	return grpc.Creds(credentials.NewTLS(c))
+}
+
 func WithKeepAliveEnforcement(enabled bool, minTime time.Duration, permitWithoutStream bool) grpc.ServerOption {
 	if !enabled {
 		return &grpc.EmptyServerOption{}
@@ -0,0 +1,277 @@
+package sihttp
+
+import (
+	"encoding"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/wonksing/si/v2/codec"
+	"github.com/wonksing/si/v2/sio"
+)
+
+// DefaultMaxMultipartMemory is the maximum memory Bind uses for a multipart body. The rest is stored in temporary files.
+const DefaultMaxMultipartMemory = 32 << 20
+
+// ErrUnsupportedMediaType is returned by Bind for a body of a content type it can't decode.
+var ErrUnsupportedMediaType = errors.New("sihttp: unsupported media type")
+
+// BindError is returned by Bind when a value of the request can't be set to a field.
+type BindError struct {
+	// Source is where the value is from, one of "path", "query", "header", "form" and "body".
+	Source string
+	// Field is the name in the tag of the field. It is empty for body.
+	Field string
+	Err   error
+}
+
+func (e *BindError) Error() string {
+	if e.Field == "" {
+		return "bind " + e.Source + ": " + e.Err.Error()
+	}
+	return "bind " + e.Source + " " + strconv.Quote(e.Field) + ": " + e.Err.Error()
+}
+
+func (e *BindError) Unwrap() error {
+	return e.Err
+}
+
+// Bind returns a T filled from r, which must be a struct. The body is decoded by its Content-Type, json and xml
+// into T with their own struct tags, and application/x-www-form-urlencoded and multipart/form-data into fields
+// tagged with `form:"name"`. Then fields tagged with `path:"name"`, `query:"name"` and `header:"name"` are set
+// from path values of http.ServeMux, query parameters and headers, overwriting the body.
+//
+// Fields can be strings, bools, numbers, time.Duration, encoding.TextUnmarshaler such as time.Time in RFC 3339,
+// or pointers and slices of these. Files of a multipart body are set to *multipart.FileHeader or
+// []*multipart.FileHeader fields. Embedded structs are filled as well.
+// T is validated with sio.Validate at last.
+//
+// It returns *BindError when a value is malformed, ErrUnsupportedMediaType for a body it can't decode,
+// and *sio.ValidationError when T is invalid. RespondError responds with a status matching them.
+func Bind[T any](r *http.Request) (T, error) {
+	var v T
+	rv := reflect.ValueOf(&v).Elem()
+	if rv.Kind() != reflect.Struct {
+		return v, fmt.Errorf("bind: %T is not a struct", v)
+	}
+
+	if err := bindBody(r, &v, rv); err != nil {
+		return v, err
+	}
+	if err := bindValues(rv, "path", func(name string) []string {
+		if p := r.PathValue(name); p != "" {
+			return []string{p}
+		}
+		return nil
+	}); err != nil {
+		return v, err
+	}
+	query := r.URL.Query()
+	if err := bindValues(rv, "query", func(name string) []string { return query[name] }); err != nil {
+		return v, err
+	}
+	if err := bindValues(rv, "header", func(name string) []string { return r.Header.Values(name) }); err != nil {
+		return v, err
+	}
+
+	return v, sio.Validate(&v)
+}
+
+func bindBody(r *http.Request, v any, rv reflect.Value) error {
+	if r.Body == nil || r.Body == http.NoBody || r.ContentLength == 0 {
+		return nil
+	}
+	contentType := r.Header.Get("Content-Type")
+	if contentType == "" {
+		return nil
+	}
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return &BindError{Source: "body", Err: ErrUnsupportedMediaType}
+	}
+
+	switch {
+	case mediaType == "application/json" || strings.HasSuffix(mediaType, "+json"):
+		err = codec.DecodeJson(v, r.Body)
+	case mediaType == "application/xml" || mediaType == "text/xml" || strings.HasSuffix(mediaType, "+xml"):
+		err = xml.NewDecoder(r.Body).Decode(v)
+	case mediaType == "application/x-www-form-urlencoded":
+		if err = r.ParseForm(); err == nil {
+			return bindValues(rv, "form", func(name string) []string { return r.PostForm[name] })
+		}
+	case mediaType == "multipart/form-data":
+		if err = r.ParseMultipartForm(DefaultMaxMultipartMemory); err == nil {
+			return bindMultipart(rv, r.MultipartForm)
+		}
+	default:
+		err = ErrUnsupportedMediaType
+	}
+	if err != nil && !errors.Is(err, io.EOF) {
+		return &BindError{Source: "body", Err: err}
+	}
+	return nil
+}
+
+var (
+	_fileHeaderType  = reflect.TypeOf((*multipart.FileHeader)(nil))
+	_fileHeadersType = reflect.TypeOf([]*multipart.FileHeader(nil))
+)
+
+func bindMultipart(rv reflect.Value, form *multipart.Form) error {
+	err := walkFields(rv, "form", func(name string, fv reflect.Value) error {
+		switch fv.Type() {
+		case _fileHeaderType:
+			if files := form.File[name]; len(files) > 0 {
+				fv.Set(reflect.ValueOf(files[0]))
+			}
+			return nil
+		case _fileHeadersType:
+			if files := form.File[name]; len(files) > 0 {
+				fv.Set(reflect.ValueOf(files))
+			}
+			return nil
+		}
+		return setValues(fv, form.Value[name])
+	})
+	return wrapBindError("form", err)
+}
+
+// bindValues sets values returned by lookup to fields tagged with tagKey.
+func bindValues(rv reflect.Value, tagKey string, lookup func(name string) []string) error {
+	err := walkFields(rv, tagKey, func(name string, fv reflect.Value) error {
+		return setValues(fv, lookup(name))
+	})
+	return wrapBindError(tagKey, err)
+}
+
+type fieldError struct {
+	name string
+	err  error
+}
+
+func (e *fieldError) Error() string {
+	return e.err.Error()
+}
+
+func wrapBindError(source string, err error) error {
+	var fe *fieldError
+	if errors.As(err, &fe) {
+		return &BindError{Source: source, Field: fe.name, Err: fe.err}
+	}
+	return err
+}
+
+// walkFields calls fn with fields of rv tagged with tagKey, including ones of embedded structs.
+func walkFields(rv reflect.Value, tagKey string, fn func(name string, fv reflect.Value) error) error {
+	rt := rv.Type()
+	for i := 0; i < rt.NumField(); i++ {
+		f := rt.Field(i)
+		fv := rv.Field(i)
+		if f.Anonymous && f.Type.Kind() == reflect.Struct {
+			if err := walkFields(fv, tagKey, fn); err != nil {
+				return err
+			}
+			continue
+		}
+		if !f.IsExported() {
+			continue
+		}
+		name, _, _ := strings.Cut(f.Tag.Get(tagKey), ",")
+		if name == "" || name == "-" {
+			continue
+		}
+		if err := fn(name, fv); err != nil {
+			return &fieldError{name: name, err: err}
+		}
+	}
+	return nil
+}
+
+var _durationType = reflect.TypeOf(time.Duration(0))
+
+// setValues sets values to fv, which is left as it is if values is empty.
+func setValues(fv reflect.Value, values []string) error {
+	if len(values) == 0 {
+		return nil
+	}
+	if fv.Kind() == reflect.Slice && !implementsTextUnmarshaler(fv.Type()) {
+		s := reflect.MakeSlice(fv.Type(), len(values), len(values))
+		for i, value := range values {
+			if err := setValue(s.Index(i), value); err != nil {
+				return err
+			}
+		}
+		fv.Set(s)
+		return nil
+	}
+	return setValue(fv, values[0])
+}
+
+func implementsTextUnmarshaler(t reflect.Type) bool {
+	return reflect.PointerTo(t).Implements(reflect.TypeOf((*encoding.TextUnmarshaler)(nil)).Elem())
+}
+
+func setValue(fv reflect.Value, value string) error {
+	if fv.Kind() == reflect.Pointer {
+		ptr := reflect.New(fv.Type().Elem())
+		if err := setValue(ptr.Elem(), value); err != nil {
+			return err
+		}
+		fv.Set(ptr)
+		return nil
+	}
+
+	if fv.CanAddr() {
+		if u, ok := fv.Addr().Interface().(encoding.TextUnmarshaler); ok {
+			return u.UnmarshalText([]byte(value))
+		}
+	}
+	if fv.Type() == _durationType {
+		d, err := time.ParseDuration(value)
+		if err != nil {
+			return err
+		}
+		fv.SetInt(int64(d))
+		return nil
+	}
+
+	switch fv.Kind() {
+	case reflect.String:
+		fv.SetString(value)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(value)
+		if err != nil {
+			return err
+		}
+		fv.SetBool(b)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n, err := strconv.ParseInt(value, 10, fv.Type().Bits())
+		if err != nil {
+			return err
+		}
+		fv.SetInt(n)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		n, err := strconv.ParseUint(value, 10, fv.Type().Bits())
+		if err != nil {
+			return err
+		}
+		fv.SetUint(n)
+	case reflect.Float32, reflect.Float64:
+		n, err := strconv.ParseFloat(value, fv.Type().Bits())
+		if err != nil {
+			return err
+		}
+		fv.SetFloat(n)
+	default:
+		return fmt.Errorf("unsupported type %s", fv.Type())
+	}
+	return nil
+}
@@ -0,0 +1,138 @@
+package sihttp
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/wonksing/si/v2/sio"
+)
+
+type _bindPage struct {
+	Page int `query:"page"`
+}
+
+type _bindBook struct {
+	_bindPage
+	Id        int                   `path:"id" json:"-" xml:"-"`
+	Title     string                `json:"title" xml:"title" form:"title" validate:"required"`
+	Tags      []string              `query:"tag" json:"tags" xml:"tag" form:"tag"`
+	Price     *float64              `json:"price" xml:"price" form:"price"`
+	Published time.Time             `query:"published" json:"published" xml:"published"`
+	Timeout   time.Duration         `header:"X-Timeout" json:"-" xml:"-"`
+	RequestId string                `header:"X-Request-Id" json:"-" xml:"-"`
+	Cover     *multipart.FileHeader `form:"cover" json:"-" xml:"-"`
+}
+
+func _bind(t *testing.T, req *http.Request) (_bindBook, error) {
+	var book _bindBook
+	var err error
+	mux := http.NewServeMux()
+	mux.HandleFunc("/books/{id}", func(w http.ResponseWriter, r *http.Request) {
+		book, err = Bind[_bindBook](r)
+	})
+	mux.ServeHTTP(httptest.NewRecorder(), req)
+	return book, err
+}
+
+func TestBind(t *testing.T) {
+	published := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+
+	req := httptest.NewRequest(http.MethodPost, "/books/7?page=2&tag=a&tag=b&published=2024-01-02T03:04:05Z",
+		strings.NewReader(`{"title":"go","tags":["x"],"price":1.5}`))
+	req.Header.Set("Content-Type", "application/json; charset=utf-8")
+	req.Header.Set("X-Timeout", "3s")
+	req.Header.Set("X-Request-Id", "abc")
+	book, err := _bind(t, req)
+	require.Nil(t, err)
+	assert.EqualValues(t, 7, book.Id)
+	assert.EqualValues(t, 2, book.Page)
+	assert.EqualValues(t, "go", book.Title)
+	assert.EqualValues(t, []string{"a", "b"}, book.Tags)
+	assert.EqualValues(t, 1.5, *book.Price)
+	assert.True(t, published.Equal(book.Published))
+	assert.EqualValues(t, 3*time.Second, book.Timeout)
+	assert.EqualValues(t, "abc", book.RequestId)
+
+	req = httptest.NewRequest(http.MethodPut, "/books/7",
+		strings.NewReader(`<book><title>go</title><tag>a</tag><tag>b</tag></book>`))
+	req.Header.Set("Content-Type", "application/xml")
+	book, err = _bind(t, req)
+	require.Nil(t, err)
+	assert.EqualValues(t, "go", book.Title)
+	assert.EqualValues(t, []string{"a", "b"}, book.Tags)
+
+	req = httptest.NewRequest(http.MethodPost, "/books/7", strings.NewReader("title=go&tag=a&tag=b&price=2"))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	book, err = _bind(t, req)
+	require.Nil(t, err)
+	assert.EqualValues(t, "go", book.Title)
+	assert.EqualValues(t, []string{"a", "b"}, book.Tags)
+	assert.EqualValues(t, 2, *book.Price)
+}
+
+func TestBind_Multipart(t *testing.T) {
+	buf := &bytes.Buffer{}
+	mw := multipart.NewWriter(buf)
+	mw.WriteField("title", "go")
+	fw, _ := mw.CreateFormFile("cover", "cover.png")
+	fw.Write([]byte("png"))
+	mw.Close()
+
+	req := httptest.NewRequest(http.MethodPost, "/books/7", buf)
+	req.Header.Set("Content-Type", mw.FormDataContentType())
+	book, err := _bind(t, req)
+	require.Nil(t, err)
+	assert.EqualValues(t, "go", book.Title)
+	require.NotNil(t, book.Cover)
+	assert.EqualValues(t, "cover.png", book.Cover.Filename)
+	f, err := book.Cover.Open()
+	require.Nil(t, err)
+	b, _ := io.ReadAll(f)
+	f.Close()
+	assert.EqualValues(t, "png", string(b))
+}
+
+func TestBind_Error(t *testing.T) {
+	req := httptest.NewRequest(http.MethodGet, "/books/x", nil)
+	_, err := _bind(t, req)
+	var be *BindError
+	require.True(t, errors.As(err, &be))
+	assert.EqualValues(t, "path", be.Source)
+	assert.EqualValues(t, "id", be.Field)
+
+	req = httptest.NewRequest(http.MethodGet, "/books/1", nil)
+	req.Header.Set("X-Timeout", "3")
+	_, err = _bind(t, req)
+	require.True(t, errors.As(err, &be))
+	assert.EqualValues(t, `bind header "X-Timeout": time: missing unit in duration "3"`, err.Error())
+
+	req = httptest.NewRequest(http.MethodPost, "/books/1", strings.NewReader(`{"title":`))
+	req.Header.Set("Content-Type", "application/json")
+	_, err = _bind(t, req)
+	require.True(t, errors.As(err, &be))
+	assert.EqualValues(t, "body", be.Source)
+
+	req = httptest.NewRequest(http.MethodPost, "/books/1", strings.NewReader(`title`))
+	req.Header.Set("Content-Type", "text/plain")
+	_, err = _bind(t, req)
+	assert.ErrorIs(t, err, ErrUnsupportedMediaType)
+
+	// title is required
+	req = httptest.NewRequest(http.MethodGet, "/books/1", nil)
+	_, err = _bind(t, req)
+	var ve *sio.ValidationError
+	require.True(t, errors.As(err, &ve))
+	assert.EqualValues(t, "title", ve.Errors[0].Field)
+
+	_, err = Bind[int](req)
+	require.NotNil(t, err)
+}
@@ -0,0 +1,419 @@
+package sihttp
+
+import (
+	"bytes"
+	"container/list"
+	"io"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// CacheHeader is set to "HIT" on responses served from the cache, and "REVALIDATED" on ones validated by the origin.
+const CacheHeader = "X-Cache"
+
+// CachedResponse is a response stored in a CacheStore. It must not be modified once stored.
+type CachedResponse struct {
+	StatusCode int
+	Header     http.Header
+	Body       []byte
+	// Vary holds values of request headers named by Vary response header.
+	Vary map[string]string
+	// Expires is when the response becomes stale.
+	Expires time.Time
+	// Date is when the response was generated, which is corrected by Age header.
+	Date time.Time
+}
+
+func (cr *CachedResponse) fresh(now time.Time) bool {
+	return now.Before(cr.Expires)
+}
+
+func (cr *CachedResponse) matches(req *http.Request) bool {
+	for k, v := range cr.Vary {
+		if req.Header.Get(k) != v {
+			return false
+		}
+	}
+	return true
+}
+
+func (cr *CachedResponse) response(req *http.Request, now time.Time, status string) *http.Response {
+	header := cr.Header.Clone()
+	age := now.Sub(cr.Date)
+	if age < 0 {
+		age = 0
+	}
+	header.Set("Age", strconv.FormatInt(int64(age/time.Second), 10))
+	header.Set(CacheHeader, status)
+
+	return &http.Response{
+		Status:        strconv.Itoa(cr.StatusCode) + " " + http.StatusText(cr.StatusCode),
+		StatusCode:    cr.StatusCode,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        header,
+		Body:          io.NopCloser(bytes.NewReader(cr.Body)),
+		ContentLength: int64(len(cr.Body)),
+		Request:       req,
+	}
+}
+
+// CacheStore stores cached responses by keys.
+type CacheStore interface {
+	Get(key string) (*CachedResponse, bool)
+	Set(key string, res *CachedResponse)
+	Delete(key string)
+}
+
+// LRUCacheStore is an in-memory CacheStore that evicts the least recently used response beyond its capacity.
+type LRUCacheStore struct {
+	mu       sync.Mutex
+	capacity int
+	ll       *list.List
+	items    map[string]*list.Element
+}
+
+type lruEntry struct {
+	key string
+	res *CachedResponse
+}
+
+// NewLRUCacheStore returns LRUCacheStore that keeps up to capacity responses.
+func NewLRUCacheStore(capacity int) *LRUCacheStore {
+	if capacity <= 0 {
+		capacity = 1
+	}
+	return &LRUCacheStore{
+		capacity: capacity,
+		ll:       list.New(),
+		items:    make(map[string]*list.Element),
+	}
+}
+
+// Get implements CacheStore's Get method.
+func (s *LRUCacheStore) Get(key string) (*CachedResponse, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	e, ok := s.items[key]
+	if !ok {
+		return nil, false
+	}
+	s.ll.MoveToFront(e)
+	return e.Value.(*lruEntry).res, true
+}
+
+// Set implements CacheStore's Set method.
+func (s *LRUCacheStore) Set(key string, res *CachedResponse) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if e, ok := s.items[key]; ok {
+		e.Value.(*lruEntry).res = res
+		s.ll.MoveToFront(e)
+		return
+	}
+	s.items[key] = s.ll.PushFront(&lruEntry{key: key, res: res})
+	for s.ll.Len() > s.capacity {
+		e := s.ll.Back()
+		s.ll.Remove(e)
+		delete(s.items, e.Value.(*lruEntry).key)
+	}
+}
+
+// Delete implements CacheStore's Delete method.
+func (s *LRUCacheStore) Delete(key string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if e, ok := s.items[key]; ok {
+		s.ll.Remove(e)
+		delete(s.items, key)
+	}
+}
+
+// Len returns the number of stored responses.
+func (s *LRUCacheStore) Len() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.ll.Len()
+}
+
+const defaultCacheMaxEntryBytes = 1 << 20
+
+// CacheOption is an option of WithCache.
+type CacheOption interface {
+	apply(rc *responseCache)
+}
+
+// CacheOptionFunc wraps a function to conforms to CacheOption interface.
+type CacheOptionFunc func(rc *responseCache)
+
+func (o CacheOptionFunc) apply(rc *responseCache) {
+	o(rc)
+}
+
+// WithCacheMaxEntryBytes limits the size of bodies to store, which is 1 MB by default. Responses with
+// a larger or unknown Content-Length are streamed to callers without being stored.
+func WithCacheMaxEntryBytes(n int64) CacheOptionFunc {
+	return CacheOptionFunc(func(rc *responseCache) {
+		if n > 0 {
+			rc.maxEntryBytes = n
+		}
+	})
+}
+
+// responseCache is a private cache following RFC 9111 for GET requests.
+// Responses are fresh for max-age of Cache-Control or until Expires, and stale ones are revalidated
+// with If-None-Match and If-Modified-Since.
+type responseCache struct {
+	store         CacheStore
+	maxEntryBytes int64
+	now           func() time.Time
+}
+
+func newResponseCache(store CacheStore, opts ...CacheOption) *responseCache {
+	rc := &responseCache{store: store, maxEntryBytes: defaultCacheMaxEntryBytes, now: time.Now}
+	for _, o := range opts {
+		if o == nil {
+			continue
+		}
+		o.apply(rc)
+	}
+	return rc
+}
+
+func cacheKey(req *http.Request) string {
+	return req.URL.String()
+}
+
+func (rc *responseCache) middleware(next Doer) Doer {
+	return DoerFunc(func(req *http.Request) (*http.Response, error) {
+		if req.Method != http.MethodGet && req.Method != "" {
+			resp, err := next.Do(req)
+			if err == nil && !isSafeMethod(req.Method) && resp.StatusCode < 400 {
+				rc.store.Delete(cacheKey(req))
+			}
+			return resp, err
+		}
+		if !cacheableRequest(req) {
+			return next.Do(req)
+		}
+
+		key := cacheKey(req)
+		cached, ok := rc.store.Get(key)
+		if ok && !cached.matches(req) {
+			cached, ok = nil, false
+		}
+		reqCC := parseCacheControl(req.Header)
+		if ok && cached.fresh(rc.now()) && !reqCC.has("no-cache") && reqCC["max-age"] != "0" {
+			return cached.response(req, rc.now(), "HIT"), nil
+		}
+
+		sent := req
+		if ok {
+			sent = conditionalRequest(req, cached)
+		}
+		resp, err := next.Do(sent)
+		if err != nil {
+			return nil, err
+		}
+
+		if ok && resp.StatusCode == http.StatusNotModified {
+			drainBody(resp)
+			updated := rc.revalidated(cached, resp)
+			rc.store.Set(key, updated)
+			return updated.response(req, rc.now(), "REVALIDATED"), nil
+		}
+		return rc.storeResponse(key, req, resp)
+	})
+}
+
+// revalidated returns a copy of cached with headers and freshness of a 304 response.
+func (rc *responseCache) revalidated(cached *CachedResponse, resp *http.Response) *CachedResponse {
+	header := cached.Header.Clone()
+	header.Del("Age")
+	for k, v := range resp.Header {
+		if k == "Content-Length" {
+			continue
+		}
+		header[k] = v
+	}
+	updated := *cached
+	updated.Header = header
+	updated.Date, updated.Expires = rc.freshness(header)
+	return &updated
+}
+
+// storeResponse reads the body of a cacheable resp into the store, and returns resp with the body restored.
+func (rc *responseCache) storeResponse(key string, req *http.Request, resp *http.Response) (*http.Response, error) {
+	vary, ok := cacheableResponse(req, resp)
+	if !ok || resp.ContentLength < 0 || resp.ContentLength > rc.maxEntryBytes {
+		// a body of unknown length would have to be read to the end before returning it
+		rc.store.Delete(key)
+		return resp, nil
+	}
+
+	body, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	resp.Body = io.NopCloser(bytes.NewReader(body))
+
+	cached := &CachedResponse{
+		StatusCode: resp.StatusCode,
+		Header:     resp.Header.Clone(),
+		Body:       body,
+		Vary:       vary,
+	}
+	cached.Date, cached.Expires = rc.freshness(resp.Header)
+	rc.store.Set(key, cached)
+	return resp, nil
+}
+
+// freshness returns the date of the response corrected by Age header and when it becomes stale.
+func (rc *responseCache) freshness(header http.Header) (time.Time, time.Time) {
+	now := rc.now()
+	date := now
+	if age, err := strconv.ParseInt(header.Get("Age"), 10, 64); err == nil && age > 0 {
+		date = now.Add(-time.Duration(age) * time.Second)
+	}
+
+	cc := parseCacheControl(header)
+	if cc.has("no-cache") {
+		return date, time.Time{}
+	}
+	if v, ok := cc["max-age"]; ok {
+		if sec, err := strconv.ParseInt(v, 10, 64); err == nil && sec > 0 {
+			return date, date.Add(time.Duration(sec) * time.Second)
+		}
+		return date, time.Time{}
+	}
+	if v := header.Get("Expires"); v != "" {
+		expires, err := http.ParseTime(v)
+		if err != nil {
+			return date, time.Time{}
+		}
+		// Expires is relative to Date of the origin
+		if d, err := http.ParseTime(header.Get("Date")); err == nil {
+			return date, date.Add(expires.Sub(d))
+		}
+		return date, expires
+	}
+	return date, time.Time{}
+}
+
+func conditionalRequest(req *http.Request, cached *CachedResponse) *http.Request {
+	etag := cached.Header.Get("ETag")
+	lastModified := cached.Header.Get("Last-Modified")
+	if etag == "" && lastModified == "" {
+		return req
+	}
+
+	r := req.Clone(req.Context())
+	if etag != "" {
+		r.Header.Set("If-None-Match", etag)
+	}
+	if lastModified != "" {
+		r.Header.Set("If-Modified-Since", lastModified)
+	}
+	return r
+}
+
+func isSafeMethod(method string) bool {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodTrace:
+		return true
+	}
+	return false
+}
+
+// cacheableRequest reports whether req may be served from or stored in the cache.
+// Conditional and range requests are made by callers that handle caching themselves.
+func cacheableRequest(req *http.Request) bool {
+	if parseCacheControl(req.Header).has("no-store") {
+		return false
+	}
+	for _, k := range []string{"If-None-Match", "If-Modified-Since", "If-Match", "If-Unmodified-Since", "Range"} {
+		if _, ok := req.Header[k]; ok {
+			return false
+		}
+	}
+	return true
+}
+
+// cacheableResponse reports whether resp may be stored, and returns request header values it varies by.
+func cacheableResponse(req *http.Request, resp *http.Response) (map[string]string, bool) {
+	switch resp.StatusCode {
+	case http.StatusOK, http.StatusNonAuthoritativeInfo, http.StatusNoContent,
+		http.StatusMultipleChoices, http.StatusMovedPermanently, http.StatusNotFound, http.StatusGone:
+	default:
+		return nil, false
+	}
+
+	if mediaType, _, _ := mime.ParseMediaType(resp.Header.Get("Content-Type")); mediaType == "text/event-stream" {
+		// an event stream never ends, so it can't be read to be stored
+		return nil, false
+	}
+
+	cc := parseCacheControl(resp.Header)
+	if cc.has("no-store") {
+		return nil, false
+	}
+	if req.Header.Get("Authorization") != "" && !cc.has("public") && !cc.has("s-maxage") && !cc.has("must-revalidate") {
+		// responses are keyed by url, so ones to credentials would be served to other credentials (RFC 9111 3.5)
+		return nil, false
+	}
+	_, hasMaxAge := cc["max-age"]
+	if !hasMaxAge && !cc.has("no-cache") && resp.Header.Get("Expires") == "" &&
+		resp.Header.Get("ETag") == "" && resp.Header.Get("Last-Modified") == "" {
+		// neither fresh nor revalidatable
+		return nil, false
+	}
+
+	var vary map[string]string
+	for _, v := range resp.Header.Values("Vary") {
+		for _, k := range strings.Split(v, ",") {
+			k = http.CanonicalHeaderKey(strings.TrimSpace(k))
+			if k == "" {
+				continue
+			}
+			if k == "*" {
+				return nil, false
+			}
+			if vary == nil {
+				vary = make(map[string]string)
+			}
+			vary[k] = req.Header.Get(k)
+		}
+	}
+	return vary, true
+}
+
+type cacheControl map[string]string
+
+func (cc cacheControl) has(directive string) bool {
+	_, ok := cc[directive]
+	return ok
+}
+
+func parseCacheControl(header http.Header) cacheControl {
+	cc := cacheControl{}
+	for _, v := range header.Values("Cache-Control") {
+		for _, d := range strings.Split(v, ",") {
+			d = strings.TrimSpace(d)
+			if d == "" {
+				continue
+			}
+			k, val, _ := strings.Cut(d, "=")
+			cc[strings.ToLower(strings.TrimSpace(k))] = strings.Trim(strings.TrimSpace(val), `"`)
+		}
+	}
+	return cc
+}
@@ -0,0 +1,310 @@
+package sihttp
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/wonksing/si/v2/sio"
+)
+
+type _cacheServer struct {
+	*httptest.Server
+	count       atomic.Int32
+	notModified atomic.Int32
+	version     atomic.Int32
+}
+
+func newCacheServer(t *testing.T) *_cacheServer {
+	s := &_cacheServer{}
+	s.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		s.count.Add(1)
+		etag := fmt.Sprintf(`"v%d"`, s.version.Load())
+		switch r.URL.Path {
+		case "/max-age":
+			w.Header().Set("Cache-Control", "max-age=60")
+		case "/etag":
+			w.Header().Set("Cache-Control", "no-cache")
+			w.Header().Set("ETag", etag)
+			if r.Header.Get("If-None-Match") == etag {
+				s.notModified.Add(1)
+				w.WriteHeader(http.StatusNotModified)
+				return
+			}
+		case "/last-modified":
+			lastModified := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC).Format(http.TimeFormat)
+			w.Header().Set("Last-Modified", lastModified)
+			if r.Header.Get("If-Modified-Since") == lastModified {
+				s.notModified.Add(1)
+				w.WriteHeader(http.StatusNotModified)
+				return
+			}
+		case "/expires":
+			now := time.Now()
+			w.Header().Set("Date", now.Format(http.TimeFormat))
+			w.Header().Set("Expires", now.Add(time.Minute).Format(http.TimeFormat))
+		case "/vary":
+			w.Header().Set("Cache-Control", "max-age=60")
+			w.Header().Set("Vary", "Accept-Language")
+		case "/no-store":
+			w.Header().Set("Cache-Control", "no-store, max-age=60")
+		case "/public":
+			w.Header().Set("Cache-Control", "public, max-age=60")
+		case "/chunked":
+			w.Header().Set("Cache-Control", "max-age=60")
+			// flushing the header sends the body chunked, whose length is unknown
+			w.(http.Flusher).Flush()
+		case "/error":
+			w.Header().Set("Cache-Control", "max-age=60")
+			w.WriteHeader(http.StatusInternalServerError)
+		}
+		fmt.Fprintf(w, `{"msg":"%s %d %s"}`, r.URL.Path, s.version.Load(), r.Header.Get("Accept-Language"))
+	}))
+	return s
+}
+
+type _clock struct {
+	now time.Time
+}
+
+func (c *_clock) Now() time.Time {
+	return c.now
+}
+
+func newCachedClient(baseUrl string, store CacheStore) (*Client, *_clock) {
+	clock := &_clock{now: time.Now()}
+	rc := newResponseCache(store)
+	rc.now = clock.Now
+	c := NewClient(_newStandardClient(), WithBaseUrl(baseUrl), WithMiddleware(rc.middleware),
+		WithReaderOpt(sio.SetJsonDecoder()))
+	return c, clock
+}
+
+func Test_Client_WithCache_MaxAge(t *testing.T) {
+	svr := newCacheServer(t)
+	defer svr.Close()
+	c, clock := newCachedClient(svr.URL, NewLRUCacheStore(10))
+
+	for i := 0; i < 3; i++ {
+		var res _testStruct
+		err := c.GetDecode("/max-age", nil, nil, &res)
+		require.Nil(t, err)
+		assert.EqualValues(t, "/max-age 0 ", res.Msg)
+	}
+	assert.EqualValues(t, 1, svr.count.Load())
+
+	// stale without validators is fetched again
+	svr.version.Store(1)
+	clock.now = clock.now.Add(61 * time.Second)
+	b, err := c.Get("/max-age", nil, nil)
+	require.Nil(t, err)
+	assert.EqualValues(t, `{"msg":"/max-age 1 "}`, string(b))
+	assert.EqualValues(t, 2, svr.count.Load())
+
+	// request directives bypass fresh responses
+	_, err = c.Get("/max-age", http.Header{"Cache-Control": {"no-cache"}}, nil)
+	require.Nil(t, err)
+	assert.EqualValues(t, 3, svr.count.Load())
+	_, err = c.Get("/max-age", http.Header{"Cache-Control": {"no-store"}}, nil)
+	require.Nil(t, err)
+	assert.EqualValues(t, 4, svr.count.Load())
+}
+
+func Test_Client_WithCache_Hit(t *testing.T) {
+	svr := newCacheServer(t)
+	defer svr.Close()
+	c, clock := newCachedClient(svr.URL, NewLRUCacheStore(10))
+
+	req, _ := http.NewRequest(http.MethodGet, svr.URL+"/max-age", nil)
+	resp, err := c.Do(req)
+	require.Nil(t, err)
+	resp.Body.Close()
+	assert.EqualValues(t, "", resp.Header.Get(CacheHeader))
+
+	clock.now = clock.now.Add(10 * time.Second)
+	req, _ = http.NewRequest(http.MethodGet, svr.URL+"/max-age", nil)
+	resp, err = c.Do(req)
+	require.Nil(t, err)
+	resp.Body.Close()
+	assert.EqualValues(t, http.StatusOK, resp.StatusCode)
+	assert.EqualValues(t, "HIT", resp.Header.Get(CacheHeader))
+	assert.EqualValues(t, "10", resp.Header.Get("Age"))
+}
+
+func Test_Client_WithCache_Revalidate(t *testing.T) {
+	svr := newCacheServer(t)
+	defer svr.Close()
+	c, _ := newCachedClient(svr.URL, NewLRUCacheStore(10))
+
+	for _, path := range []string{"/etag", "/last-modified"} {
+		for i := 0; i < 3; i++ {
+			res, err := Get[_testStruct](context.Background(), c, path)
+			require.Nil(t, err, path)
+			assert.EqualValues(t, path+" 0 ", res.Msg, path)
+		}
+	}
+	assert.EqualValues(t, 6, svr.count.Load())
+	assert.EqualValues(t, 4, svr.notModified.Load())
+
+	// changed content replaces the cached one
+	svr.version.Store(1)
+	res, err := Get[_testStruct](context.Background(), c, "/etag")
+	require.Nil(t, err)
+	assert.EqualValues(t, "/etag 1 ", res.Msg)
+	res, err = Get[_testStruct](context.Background(), c, "/etag")
+	require.Nil(t, err)
+	assert.EqualValues(t, "/etag 1 ", res.Msg)
+	assert.EqualValues(t, 5, svr.notModified.Load())
+}
+
+func Test_Client_WithCache_Expires(t *testing.T) {
+	svr := newCacheServer(t)
+	defer svr.Close()
+	c, clock := newCachedClient(svr.URL, NewLRUCacheStore(10))
+
+	for i := 0; i < 2; i++ {
+		_, err := c.Get("/expires", nil, nil)
+		require.Nil(t, err)
+	}
+	assert.EqualValues(t, 1, svr.count.Load())
+
+	clock.now = clock.now.Add(2 * time.Minute)
+	_, err := c.Get("/expires", nil, nil)
+	require.Nil(t, err)
+	assert.EqualValues(t, 2, svr.count.Load())
+}
+
+func Test_Client_WithCache_NotStored(t *testing.T) {
+	svr := newCacheServer(t)
+	defer svr.Close()
+	store := NewLRUCacheStore(10)
+	c, _ := newCachedClient(svr.URL, store)
+
+	for _, path := range []string{"/no-store", "/error", "/plain"} {
+		for i := 0; i < 2; i++ {
+			c.Get(path, nil, nil)
+		}
+	}
+	assert.EqualValues(t, 6, svr.count.Load())
+	assert.EqualValues(t, 0, store.Len())
+}
+
+func Test_Client_WithCache_Authorization(t *testing.T) {
+	svr := newCacheServer(t)
+	defer svr.Close()
+	store := NewLRUCacheStore(10)
+	c, _ := newCachedClient(svr.URL, store)
+
+	for _, token := range []string{"a", "b"} {
+		_, err := c.Get("/max-age", http.Header{"Authorization": {"Bearer " + token}}, nil)
+		require.Nil(t, err)
+	}
+	assert.EqualValues(t, 2, svr.count.Load())
+	assert.EqualValues(t, 0, store.Len())
+
+	for _, token := range []string{"a", "b"} {
+		_, err := c.Get("/public", http.Header{"Authorization": {"Bearer " + token}}, nil)
+		require.Nil(t, err)
+	}
+	assert.EqualValues(t, 3, svr.count.Load())
+	assert.EqualValues(t, 1, store.Len())
+}
+
+func Test_Client_WithCache_Vary(t *testing.T) {
+	svr := newCacheServer(t)
+	defer svr.Close()
+	c, _ := newCachedClient(svr.URL, NewLRUCacheStore(10))
+
+	b, err := c.Get("/vary", http.Header{"Accept-Language": {"ko"}}, nil)
+	require.Nil(t, err)
+	assert.EqualValues(t, `{"msg":"/vary 0 ko"}`, string(b))
+	b, err = c.Get("/vary", http.Header{"Accept-Language": {"ko"}}, nil)
+	require.Nil(t, err)
+	assert.EqualValues(t, `{"msg":"/vary 0 ko"}`, string(b))
+	assert.EqualValues(t, 1, svr.count.Load())
+
+	b, err = c.Get("/vary", http.Header{"Accept-Language": {"en"}}, nil)
+	require.Nil(t, err)
+	assert.EqualValues(t, `{"msg":"/vary 0 en"}`, string(b))
+	assert.EqualValues(t, 2, svr.count.Load())
+}
+
+func Test_Client_WithCache_Invalidate(t *testing.T) {
+	svr := newCacheServer(t)
+	defer svr.Close()
+	store := NewLRUCacheStore(10)
+	c := NewClient(_newStandardClient(), WithBaseUrl(svr.URL), WithCache(store))
+
+	_, err := c.Get("/max-age", nil, nil)
+	require.Nil(t, err)
+	assert.EqualValues(t, 1, store.Len())
+
+	_, err = c.Post("/max-age", nil, []byte("update"))
+	require.Nil(t, err)
+	assert.EqualValues(t, 0, store.Len())
+
+	_, err = c.Get("/max-age", nil, nil)
+	require.Nil(t, err)
+	assert.EqualValues(t, 3, svr.count.Load())
+}
+
+func Test_Client_WithCache_MaxEntryBytes(t *testing.T) {
+	svr := newCacheServer(t)
+	defer svr.Close()
+	store := NewLRUCacheStore(10)
+	c := NewClient(_newStandardClient(), WithBaseUrl(svr.URL), WithCache(store, WithCacheMaxEntryBytes(10)))
+
+	b, err := c.Get("/max-age", nil, nil)
+	require.Nil(t, err)
+	assert.EqualValues(t, `{"msg":"/max-age 0 "}`, string(b))
+	assert.EqualValues(t, 0, store.Len())
+
+	c = NewClient(_newStandardClient(), WithBaseUrl(svr.URL), WithCache(store))
+	b, err = c.Get("/chunked", nil, nil)
+	require.Nil(t, err)
+	assert.EqualValues(t, `{"msg":"/chunked 0 "}`, string(b))
+	assert.EqualValues(t, 0, store.Len())
+
+	_, err = c.Get("/max-age", nil, nil)
+	require.Nil(t, err)
+	assert.EqualValues(t, 1, store.Len())
+}
+
+func TestLRUCacheStore(t *testing.T) {
+	s := NewLRUCacheStore(2)
+	s.Set("a", &CachedResponse{StatusCode: 1})
+	s.Set("b", &CachedResponse{StatusCode: 2})
+	_, ok := s.Get("a")
+	assert.True(t, ok)
+
+	// b is the least recently used
+	s.Set("c", &CachedResponse{StatusCode: 3})
+	_, ok = s.Get("b")
+	assert.False(t, ok)
+	assert.EqualValues(t, 2, s.Len())
+
+	s.Set("a", &CachedResponse{StatusCode: 4})
+	res, ok := s.Get("a")
+	require.True(t, ok)
+	assert.EqualValues(t, 4, res.StatusCode)
+
+	s.Delete("a")
+	s.Delete("unknown")
+	_, ok = s.Get("a")
+	assert.False(t, ok)
+	assert.EqualValues(t, 1, s.Len())
+}
+
+func Test_parseCacheControl(t *testing.T) {
+	cc := parseCacheControl(http.Header{"Cache-Control": {`no-cache, Max-Age="60"`, "private"}})
+	assert.True(t, cc.has("no-cache"))
+	assert.True(t, cc.has("private"))
+	assert.False(t, cc.has("no-store"))
+	assert.EqualValues(t, "60", cc["max-age"])
+}
@@ -0,0 +1,319 @@
+package sihttp
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/wonksing/si/v2/codec/sign"
+)
+
+const (
+	// SignDateHeader carries the time a request was signed at in "20060102T150405Z" format.
+	SignDateHeader = "X-Date"
+	// ContentSha256Header carries the hex encoded sha256 digest of a request body.
+	ContentSha256Header = "X-Content-Sha256"
+
+	signDateFormat         = "20060102T150405Z"
+	defaultSignAlgorithm   = "SI-HMAC-SHA256"
+	defaultSignatureMaxAge = 5 * time.Minute
+)
+
+var (
+	errMalformedAuthorization = errors.New("malformed authorization")
+	errUnsignedComponent      = errors.New("required component is not signed")
+	errSignatureExpired       = errors.New("signature expired")
+	errDigestMismatch         = errors.New("digest mismatch")
+)
+
+// CanonicalSignatureOption configures WithCanonicalSignature and VerifyCanonicalSignature.
+type CanonicalSignatureOption interface {
+	apply(c *canonicalConfig)
+}
+
+// CanonicalSignatureOptionFunc wraps a function to conforms to CanonicalSignatureOption interface.
+type CanonicalSignatureOptionFunc func(c *canonicalConfig)
+
+func (o CanonicalSignatureOptionFunc) apply(c *canonicalConfig) {
+	o(c)
+}
+
+type canonicalConfig struct {
+	algorithm     string
+	signedHeaders []string
+	maxAge        time.Duration
+	maxBodyBytes  int64
+}
+
+func newCanonicalConfig(opts []CanonicalSignatureOption) canonicalConfig {
+	c := canonicalConfig{algorithm: defaultSignAlgorithm, maxAge: defaultSignatureMaxAge,
+		maxBodyBytes: DefaultMaxVerifyBodyBytes}
+	for _, o := range opts {
+		if o == nil {
+			continue
+		}
+		o.apply(&c)
+	}
+	return c
+}
+
+// WithCanonicalAlgorithm names the signing algorithm in Authorization header, which is "SI-HMAC-SHA256" by default.
+// A client and a server must use the same algorithm.
+func WithCanonicalAlgorithm(algorithm string) CanonicalSignatureOptionFunc {
+	return CanonicalSignatureOptionFunc(func(c *canonicalConfig) {
+		if algorithm != "" {
+			c.algorithm = algorithm
+		}
+	})
+}
+
+// WithCanonicalSignedHeaders signs headers in addition to host, X-Date and X-Content-Sha256.
+// It has no effect on VerifyCanonicalSignature, which verifies the headers listed in Authorization header.
+func WithCanonicalSignedHeaders(headers ...string) CanonicalSignatureOptionFunc {
+	return CanonicalSignatureOptionFunc(func(c *canonicalConfig) {
+		c.signedHeaders = append(c.signedHeaders, headers...)
+	})
+}
+
+// WithCanonicalMaxAge sets how old X-Date can be either way for VerifyCanonicalSignature, which is 5 minutes
+// by default.
+func WithCanonicalMaxAge(d time.Duration) CanonicalSignatureOptionFunc {
+	return CanonicalSignatureOptionFunc(func(c *canonicalConfig) {
+		if d > 0 {
+			c.maxAge = d
+		}
+	})
+}
+
+// WithCanonicalMaxBodyBytes limits the size of bodies VerifyCanonicalSignature reads, which is
+// DefaultMaxVerifyBodyBytes by default.
+func WithCanonicalMaxBodyBytes(n int64) CanonicalSignatureOptionFunc {
+	return CanonicalSignatureOptionFunc(func(c *canonicalConfig) {
+		if n > 0 {
+			c.maxBodyBytes = n
+		}
+	})
+}
+
+// WithCanonicalSignature signs the request with signer in the manner of AWS Signature Version 4.
+//
+// It sets X-Date and X-Content-Sha256 headers, then builds a canonical request of the method, the path,
+// the sorted query, the signed headers and the body digest, and sets Authorization header as
+//
+//	SI-HMAC-SHA256 Credential=<keyId>, SignedHeaders=host;x-content-sha256;x-date, Signature=<hex>
+//
+// where the signature is of "<algorithm>\n<X-Date>\n<hex sha256 of the canonical request>".
+func WithCanonicalSignature(keyId string, signer sign.Signer, opts ...CanonicalSignatureOption) RequestOptionFunc {
+	conf := newCanonicalConfig(opts)
+	return RequestOptionFunc(func(req *http.Request) error {
+		return signCanonical(req, keyId, signer, conf, time.Now())
+	})
+}
+
+func signCanonical(req *http.Request, keyId string, signer sign.Signer, conf canonicalConfig, now time.Time) error {
+	body, err := getRequestBody(req)
+	if err != nil {
+		return err
+	}
+	digest := sha256.Sum256(body)
+	date := now.UTC().Format(signDateFormat)
+	req.Header.Set(SignDateHeader, date)
+	req.Header.Set(ContentSha256Header, hex.EncodeToString(digest[:]))
+
+	signedHeaders := canonicalSignedHeaders(conf.signedHeaders)
+	sts := stringToSign(conf.algorithm, date, canonicalRequest(req, signedHeaders))
+	sig, err := sign.SignToString(signer, sign.EncodingHex, []byte(sts))
+	if err != nil {
+		return err
+	}
+
+	req.Header.Set("Authorization", conf.algorithm+" Credential="+keyId+
+		", SignedHeaders="+strings.Join(signedHeaders, ";")+", Signature="+sig)
+	return nil
+}
+
+// VerifyCanonicalSignature returns a middleware that verifies signatures made by WithCanonicalSignature with
+// the verifier of the key id. It responds with 401 when the signature is missing or invalid, or when X-Date
+// is out of the max age, and 413 when the body is too large. The body is restored for next handler.
+func VerifyCanonicalSignature(verifier func(keyId string) (sign.Verifier, error), opts ...CanonicalSignatureOption) func(http.Handler) http.Handler {
+	conf := newCanonicalConfig(opts)
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			body, ok := readVerifiedBody(w, r, conf.maxBodyBytes)
+			if !ok {
+				return
+			}
+			if err := verifyCanonical(r, body, verifier, conf, time.Now()); err != nil {
+				http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+func verifyCanonical(r *http.Request, body []byte, verifier func(keyId string) (sign.Verifier, error),
+	conf canonicalConfig, now time.Time) error {
+	keyId, signedHeaders, sig, err := parseCanonicalAuthorization(r.Header.Get("Authorization"), conf.algorithm)
+	if err != nil {
+		return err
+	}
+	for _, h := range []string{"host", "x-content-sha256", "x-date"} {
+		if !containsString(signedHeaders, h) {
+			return errUnsignedComponent
+		}
+	}
+
+	date := r.Header.Get(SignDateHeader)
+	t, err := time.Parse(signDateFormat, date)
+	if err != nil {
+		return errMalformedAuthorization
+	}
+	if d := now.Sub(t); d > conf.maxAge || d < -conf.maxAge {
+		return errSignatureExpired
+	}
+
+	digest := sha256.Sum256(body)
+	if !sign.EqualString(hex.EncodeToString(digest[:]), r.Header.Get(ContentSha256Header)) {
+		return errDigestMismatch
+	}
+
+	v, err := verifier(keyId)
+	if err != nil {
+		return err
+	}
+	sts := stringToSign(conf.algorithm, date, canonicalRequest(r, signedHeaders))
+	return sign.VerifyString(v, sign.EncodingHex, []byte(sts), sig)
+}
+
+// parseCanonicalAuthorization parses "<algorithm> Credential=<keyId>, SignedHeaders=<h1;h2>, Signature=<sig>".
+func parseCanonicalAuthorization(v, algorithm string) (string, []string, string, error) {
+	params, found := strings.CutPrefix(v, algorithm+" ")
+	if !found {
+		return "", nil, "", errMalformedAuthorization
+	}
+
+	var keyId, headers, sig string
+	for _, p := range strings.Split(params, ",") {
+		k, val, _ := strings.Cut(strings.TrimSpace(p), "=")
+		switch k {
+		case "Credential":
+			keyId = val
+		case "SignedHeaders":
+			headers = val
+		case "Signature":
+			sig = val
+		}
+	}
+	if headers == "" || sig == "" {
+		return "", nil, "", errMalformedAuthorization
+	}
+	return keyId, strings.Split(headers, ";"), sig, nil
+}
+
+func stringToSign(algorithm, date, canonicalRequest string) string {
+	h := sha256.Sum256([]byte(canonicalRequest))
+	return algorithm + "\n" + date + "\n" + hex.EncodeToString(h[:])
+}
+
+// canonicalSignedHeaders returns lower-cased, sorted and deduplicated headers including the mandatory ones.
+func canonicalSignedHeaders(headers []string) []string {
+	signed := []string{"host", "x-content-sha256", "x-date"}
+	for _, h := range headers {
+		h = strings.ToLower(strings.TrimSpace(h))
+		if h != "" && !containsString(signed, h) {
+			signed = append(signed, h)
+		}
+	}
+	sort.Strings(signed)
+	return signed
+}
+
+// canonicalRequest returns
+//
+//	<method>\n<path>\n<sorted query>\n<name:value\n of each signed header>\n<signed headers>\n<X-Content-Sha256>
+func canonicalRequest(r *http.Request, signedHeaders []string) string {
+	b := strings.Builder{}
+	b.WriteString(r.Method)
+	b.WriteByte('\n')
+
+	path := r.URL.EscapedPath()
+	if path == "" {
+		path = "/"
+	}
+	b.WriteString(path)
+	b.WriteByte('\n')
+	b.WriteString(canonicalQuery(r.URL.RawQuery))
+	b.WriteByte('\n')
+
+	for _, h := range signedHeaders {
+		b.WriteString(h)
+		b.WriteByte(':')
+		if h == "host" {
+			b.WriteString(strings.ToLower(requestHost(r)))
+		} else {
+			values := r.Header.Values(h)
+			for i, v := range values {
+				if i > 0 {
+					b.WriteByte(',')
+				}
+				b.WriteString(strings.Join(strings.Fields(v), " "))
+			}
+		}
+		b.WriteByte('\n')
+	}
+	b.WriteByte('\n')
+	b.WriteString(strings.Join(signedHeaders, ";"))
+	b.WriteByte('\n')
+	b.WriteString(r.Header.Get(ContentSha256Header))
+	return b.String()
+}
+
+// canonicalQuery sorts parameters by names then values, and encodes spaces as %20.
+func canonicalQuery(rawQuery string) string {
+	if rawQuery == "" {
+		return ""
+	}
+	q, _ := url.ParseQuery(rawQuery)
+	keys := make([]string, 0, len(q))
+	for k := range q {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	pairs := make([]string, 0, len(q))
+	for _, k := range keys {
+		values := q[k]
+		sort.Strings(values)
+		for _, v := range values {
+			pairs = append(pairs, queryEscape(k)+"="+queryEscape(v))
+		}
+	}
+	return strings.Join(pairs, "&")
+}
+
+func queryEscape(s string) string {
+	return strings.ReplaceAll(url.QueryEscape(s), "+", "%20")
+}
+
+// requestHost returns the host of an outgoing or incoming request.
+func requestHost(r *http.Request) string {
+	if r.Host != "" {
+		return r.Host
+	}
+	return r.URL.Host
+}
+
+func containsString(s []string, v string) bool {
+	for _, e := range s {
+		if e == v {
+			return true
+		}
+	}
+	return false
+}
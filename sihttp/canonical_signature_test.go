@@ -0,0 +1,107 @@
+package sihttp
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/wonksing/si/v2/codec/sign"
+)
+
+func Test_canonicalRequest(t *testing.T) {
+	req, _ := http.NewRequest(http.MethodGet, "http://Example.com/a%20b/c?b=2&a=2&a=1&sp=x+y", nil)
+	req.Header.Set("X-Custom", "  multiple   spaces  ")
+	req.Header.Set(ContentSha256Header, "digest")
+	req.Header.Set(SignDateHeader, "20240101T000000Z")
+
+	expected := "GET\n" +
+		"/a%20b/c\n" +
+		"a=1&a=2&b=2&sp=x%20y\n" +
+		"host:example.com\n" +
+		"x-content-sha256:digest\n" +
+		"x-custom:multiple spaces\n" +
+		"x-date:20240101T000000Z\n" +
+		"\n" +
+		"host;x-content-sha256;x-custom;x-date\n" +
+		"digest"
+	assert.EqualValues(t, expected, canonicalRequest(req, canonicalSignedHeaders([]string{"X-Custom", "host"})))
+}
+
+func Test_WithCanonicalSignature(t *testing.T) {
+	signer := sign.NewHmacSha256([]byte("secret"))
+	verifier := func(keyID string) (sign.Verifier, error) {
+		if keyID != "key-1" {
+			return nil, errors.New("unknown key")
+		}
+		return signer, nil
+	}
+
+	var received []byte
+	h := VerifyCanonicalSignature(verifier)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		received, _ = io.ReadAll(r.Body)
+		w.Write([]byte("ok"))
+	}))
+	svr := httptest.NewServer(h)
+	defer svr.Close()
+
+	c := NewClient(_newStandardClient(), WithBaseUrl(svr.URL), WithRequestOpt(WithCanonicalSignature("key-1", signer,
+		WithCanonicalSignedHeaders("Content-Type"))))
+
+	b, err := c.Post("/path?z=1&a=2", http.Header{"Content-Type": {"application/json"}}, []byte(`{"a":1}`))
+	require.Nil(t, err)
+	assert.EqualValues(t, "ok", string(b))
+	assert.EqualValues(t, `{"a":1}`, string(received))
+
+	b, err = c.Get("/path", nil, map[string]string{"q": "a b"})
+	require.Nil(t, err)
+	assert.EqualValues(t, "ok", string(b))
+
+	// unknown key
+	c = NewClient(_newStandardClient(), WithBaseUrl(svr.URL), WithRequestOpt(WithCanonicalSignature("key-2", signer)))
+	_, err = c.Get("/path", nil, nil)
+	require.NotNil(t, err)
+	assert.EqualValues(t, http.StatusUnauthorized, err.(*Error).GetStatusCode(0))
+}
+
+func Test_verifyCanonical(t *testing.T) {
+	signer := sign.NewHmacSha256([]byte("secret"))
+	verifier := func(string) (sign.Verifier, error) { return signer, nil }
+	conf := newCanonicalConfig([]CanonicalSignatureOption{WithCanonicalMaxAge(time.Minute)})
+	now := time.Now()
+
+	newSigned := func(body string, signedAt time.Time) *http.Request {
+		req, _ := http.NewRequest(http.MethodPost, "http://localhost/path?a=1", bytes.NewBufferString(body))
+		req.Header.Set("X-Custom", "v")
+		err := signCanonical(req, "k", signer, newCanonicalConfig([]CanonicalSignatureOption{WithCanonicalSignedHeaders("X-Custom")}), signedAt)
+		require.Nil(t, err)
+		return req
+	}
+
+	req := newSigned("body", now)
+	assert.Nil(t, verifyCanonical(req, []byte("body"), verifier, conf, now))
+
+	assert.ErrorIs(t, verifyCanonical(req, []byte("tampered"), verifier, conf, now), errDigestMismatch)
+	assert.ErrorIs(t, verifyCanonical(newSigned("body", now.Add(-2*time.Minute)), []byte("body"), verifier, conf, now), errSignatureExpired)
+
+	req = newSigned("body", now)
+	req.Header.Set("X-Custom", "changed")
+	assert.ErrorIs(t, verifyCanonical(req, []byte("body"), verifier, conf, now), sign.ErrInvalidSignature)
+
+	req = newSigned("body", now)
+	req.URL.RawQuery = "a=2"
+	assert.ErrorIs(t, verifyCanonical(req, []byte("body"), verifier, conf, now), sign.ErrInvalidSignature)
+
+	req = newSigned("body", now)
+	req.Header.Set("Authorization", strings.Replace(req.Header.Get("Authorization"), "host;", "", 1))
+	assert.ErrorIs(t, verifyCanonical(req, []byte("body"), verifier, conf, now), errUnsignedComponent)
+
+	req.Header.Set("Authorization", "Bearer token")
+	assert.ErrorIs(t, verifyCanonical(req, []byte("body"), verifier, conf, now), errMalformedAuthorization)
+}
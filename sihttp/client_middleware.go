@@ -0,0 +1,324 @@
+package sihttp
+
+import (
+	"bytes"
+	"io"
+	"log"
+	"mime"
+	"net/http"
+	"regexp"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Doer sends an http request and returns its response.
+type Doer interface {
+	Do(req *http.Request) (*http.Response, error)
+}
+
+// DoerFunc wraps a function to conforms to Doer interface.
+type DoerFunc func(req *http.Request) (*http.Response, error)
+
+// Do implements Doer's Do method.
+func (f DoerFunc) Do(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
+
+// ClientMiddleware wraps a Doer to run before and after it, eg. logging, metrics, idempotency keys or cache lookups.
+// A middleware wraps retries of a request, so it runs once per Client.Do.
+type ClientMiddleware func(next Doer) Doer
+
+func chainMiddlewares(d Doer, mws []ClientMiddleware) Doer {
+	for i := len(mws) - 1; i >= 0; i-- {
+		d = mws[i](d)
+	}
+	return d
+}
+
+const (
+	defaultLogMaxBodySize = 1024
+	redactedValue         = "***"
+)
+
+// LogConfig configures LoggingMiddleware.
+type LogConfig struct {
+	// Logger writes log lines. log.Default() is used if nil.
+	Logger *log.Logger
+	// LogBody logs request and response bodies.
+	LogBody bool
+	// MaxBodySize is the max number of bytes of a body to log. defaultLogMaxBodySize is used if zero or negative.
+	MaxBodySize int
+	// RedactHeaders are headers whose values are masked.
+	// Authorization, Proxy-Authorization, Cookie and Set-Cookie are used if nil.
+	RedactHeaders []string
+	// RedactFields are json fields whose values are masked in bodies, eg. "password". Names are case-insensitive.
+	RedactFields []string
+}
+
+var _defaultRedactHeaders = []string{"Authorization", "Proxy-Authorization", "Cookie", "Set-Cookie"}
+
+// LoggingMiddleware logs method, url, status and elapsed time of requests, and optionally their headers and bodies.
+func LoggingMiddleware(conf LogConfig) ClientMiddleware {
+	l := newRequestLogger(conf)
+	return func(next Doer) Doer {
+		return DoerFunc(func(req *http.Request) (*http.Response, error) {
+			if l.logBody {
+				l.logRequest(req)
+			}
+
+			start := time.Now()
+			resp, err := next.Do(req)
+			elapsed := time.Since(start)
+			if err != nil {
+				l.logger.Printf("%s %s error=%v elapsed=%s", req.Method, redactUrl(req.URL), err, elapsed)
+				return resp, err
+			}
+
+			l.logger.Printf("%s %s status=%d elapsed=%s", req.Method, redactUrl(req.URL), resp.StatusCode, elapsed)
+			if l.logBody {
+				l.logResponse(resp)
+			}
+			return resp, nil
+		})
+	}
+}
+
+type requestLogger struct {
+	logger        *log.Logger
+	logBody       bool
+	maxBodySize   int
+	redactHeaders map[string]struct{}
+	redactFields  *regexp.Regexp
+}
+
+func newRequestLogger(conf LogConfig) *requestLogger {
+	l := &requestLogger{
+		logger:        conf.Logger,
+		logBody:       conf.LogBody,
+		maxBodySize:   conf.MaxBodySize,
+		redactHeaders: make(map[string]struct{}),
+	}
+	if l.logger == nil {
+		l.logger = log.Default()
+	}
+	if l.maxBodySize <= 0 {
+		l.maxBodySize = defaultLogMaxBodySize
+	}
+
+	headers := conf.RedactHeaders
+	if headers == nil {
+		headers = _defaultRedactHeaders
+	}
+	for _, h := range headers {
+		l.redactHeaders[http.CanonicalHeaderKey(h)] = struct{}{}
+	}
+
+	if len(conf.RedactFields) > 0 {
+		names := make([]string, len(conf.RedactFields))
+		for i, f := range conf.RedactFields {
+			names[i] = regexp.QuoteMeta(f)
+		}
+		// matches "field": followed by a string, or any other value up to the next delimiter
+		l.redactFields = regexp.MustCompile(`(?i)("(?:` + strings.Join(names, "|") + `)"\s*:\s*)("(?:[^"\\]|\\.)*"|[^,}\]\s]+)`)
+	}
+	return l
+}
+
+// logRequest logs up to maxBodySize bytes of the body of req. It reads them with GetBody if it is set,
+// and otherwise from the body, which is replaced to read them again followed by the rest.
+func (l *requestLogger) logRequest(req *http.Request) {
+	var body []byte
+	var err error
+	switch {
+	case req.Body == nil || req.Body == http.NoBody:
+	case req.GetBody != nil:
+		var r io.ReadCloser
+		if r, err = req.GetBody(); err == nil {
+			body, err = io.ReadAll(io.LimitReader(r, int64(l.maxBodySize)+1))
+			r.Close()
+		}
+	default:
+		body, req.Body, err = peekBody(req.Body, l.maxBodySize)
+	}
+	if err != nil {
+		l.logger.Printf("%s %s header=%v body_error=%v", req.Method, redactUrl(req.URL), l.header(req.Header), err)
+		return
+	}
+	l.logger.Printf("%s %s header=%v body=%s", req.Method, redactUrl(req.URL), l.header(req.Header), l.body(body))
+}
+
+// logResponse logs up to maxBodySize bytes of the body of resp, which is replaced to read them again followed
+// by the rest. Bodies of event streams are not logged, as they last as long as the stream.
+func (l *requestLogger) logResponse(resp *http.Response) {
+	mediaType, _, _ := mime.ParseMediaType(resp.Header.Get("Content-Type"))
+	if resp.Body == nil || resp.Body == http.NoBody || mediaType == "text/event-stream" {
+		l.logger.Printf("response header=%v", l.header(resp.Header))
+		return
+	}
+
+	body, rc, err := peekBody(resp.Body, l.maxBodySize)
+	resp.Body = rc
+	if err != nil {
+		l.logger.Printf("response header=%v body_error=%v", l.header(resp.Header), err)
+		return
+	}
+	l.logger.Printf("response header=%v body=%s", l.header(resp.Header), l.body(body))
+}
+
+// peekBody reads up to n+1 bytes of body, so that a longer body is logged as truncated. It returns them
+// with a body reading them again followed by the rest of body, which is closed with it.
+func peekBody(body io.ReadCloser, n int) ([]byte, io.ReadCloser, error) {
+	head, err := io.ReadAll(io.LimitReader(body, int64(n)+1))
+	return head, struct {
+		io.Reader
+		io.Closer
+	}{io.MultiReader(bytes.NewReader(head), body), body}, err
+}
+
+func (l *requestLogger) header(h http.Header) http.Header {
+	redacted := make(http.Header, len(h))
+	for k, v := range h {
+		if _, ok := l.redactHeaders[http.CanonicalHeaderKey(k)]; ok {
+			redacted[k] = []string{redactedValue}
+			continue
+		}
+		redacted[k] = v
+	}
+	return redacted
+}
+
+func (l *requestLogger) body(b []byte) string {
+	truncated := false
+	if len(b) > l.maxBodySize {
+		b = b[:l.maxBodySize]
+		truncated = true
+	}
+	if l.redactFields != nil {
+		b = l.redactFields.ReplaceAll(b, []byte(`${1}"`+redactedValue+`"`))
+	}
+	if truncated {
+		return string(b) + "...(truncated)"
+	}
+	return string(b)
+}
+
+// MetricsRecorder receives the result of each request sent through MetricsMiddleware.
+// status is zero if err is not nil.
+type MetricsRecorder interface {
+	Record(method, host string, status int, err error, elapsed time.Duration)
+}
+
+// MetricsRecorderFunc wraps a function to conforms to MetricsRecorder interface.
+type MetricsRecorderFunc func(method, host string, status int, err error, elapsed time.Duration)
+
+// Record implements MetricsRecorder's Record method.
+func (f MetricsRecorderFunc) Record(method, host string, status int, err error, elapsed time.Duration) {
+	f(method, host, status, err, elapsed)
+}
+
+// MetricsMiddleware records method, host, status and elapsed time of requests to recorder.
+func MetricsMiddleware(recorder MetricsRecorder) ClientMiddleware {
+	return func(next Doer) Doer {
+		return DoerFunc(func(req *http.Request) (*http.Response, error) {
+			start := time.Now()
+			resp, err := next.Do(req)
+			status := 0
+			if err == nil && resp != nil {
+				status = resp.StatusCode
+			}
+			recorder.Record(req.Method, req.URL.Host, status, err, time.Since(start))
+			return resp, err
+		})
+	}
+}
+
+// RequestMetrics is a snapshot of counters of ClientMetrics.
+type RequestMetrics struct {
+	Requests  uint64
+	Errors    uint64 // requests that failed without a response
+	Status1xx uint64
+	Status2xx uint64
+	Status3xx uint64
+	Status4xx uint64
+	Status5xx uint64
+	// Elapsed is the sum of elapsed time of all requests.
+	Elapsed time.Duration
+}
+
+// AvgElapsed returns the average elapsed time of requests.
+func (m RequestMetrics) AvgElapsed() time.Duration {
+	if m.Requests == 0 {
+		return 0
+	}
+	return m.Elapsed / time.Duration(m.Requests)
+}
+
+type requestMeter struct {
+	requests atomic.Uint64
+	errors   atomic.Uint64
+	status   [5]atomic.Uint64
+	elapsed  atomic.Int64
+}
+
+func (m *requestMeter) record(status int, err error, elapsed time.Duration) {
+	m.requests.Add(1)
+	m.elapsed.Add(int64(elapsed))
+	if err != nil {
+		m.errors.Add(1)
+		return
+	}
+	if class := status / 100; class >= 1 && class <= 5 {
+		m.status[class-1].Add(1)
+	}
+}
+
+func (m *requestMeter) snapshot() RequestMetrics {
+	return RequestMetrics{
+		Requests:  m.requests.Load(),
+		Errors:    m.errors.Load(),
+		Status1xx: m.status[0].Load(),
+		Status2xx: m.status[1].Load(),
+		Status3xx: m.status[2].Load(),
+		Status4xx: m.status[3].Load(),
+		Status5xx: m.status[4].Load(),
+		Elapsed:   time.Duration(m.elapsed.Load()),
+	}
+}
+
+// ClientMetrics is an in-memory MetricsRecorder that counts requests in total and per host.
+type ClientMetrics struct {
+	total requestMeter
+	hosts sync.Map
+}
+
+// NewClientMetrics returns ClientMetrics.
+func NewClientMetrics() *ClientMetrics {
+	return &ClientMetrics{}
+}
+
+// Record implements MetricsRecorder's Record method.
+func (m *ClientMetrics) Record(method, host string, status int, err error, elapsed time.Duration) {
+	m.total.record(status, err, elapsed)
+
+	hm, ok := m.hosts.Load(host)
+	if !ok {
+		hm, _ = m.hosts.LoadOrStore(host, &requestMeter{})
+	}
+	hm.(*requestMeter).record(status, err, elapsed)
+}
+
+// Total returns metrics of all requests.
+func (m *ClientMetrics) Total() RequestMetrics {
+	return m.total.snapshot()
+}
+
+// Host returns metrics of requests to host.
+func (m *ClientMetrics) Host(host string) RequestMetrics {
+	hm, ok := m.hosts.Load(host)
+	if !ok {
+		return RequestMetrics{}
+	}
+	return hm.(*requestMeter).snapshot()
+}
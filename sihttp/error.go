@@ -2,17 +2,73 @@ package sihttp
 
 import (
 	"bytes"
+	"context"
+	"encoding/xml"
+	"errors"
 	"fmt"
+	"maps"
+	"mime"
+	"net"
 	"net/http"
+	"net/url"
+	"reflect"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/wonksing/si/v2/codec"
+	"github.com/wonksing/si/v2/sio"
+)
+
+// Sentinel errors to classify an error with errors.Is. Error matches them by its response status or
+// the transport error it wraps.
+//
+//	if errors.Is(err, sihttp.ErrServerError) { ... }
+var (
+	ErrTimeout     = errors.New("sihttp: timeout")
+	ErrTemporary   = errors.New("sihttp: temporary")
+	ErrCanceled    = errors.New("sihttp: canceled")
+	ErrClientError = errors.New("sihttp: client error")
+	ErrServerError = errors.New("sihttp: server error")
 )
 
+// Error is returned by Client for a failed request. It has either Response of a status that is not
+// successful, or Err of the transport.
 type Error struct {
 	Response *http.Response
 	Body     []byte
+
+	// Method is the method of the request.
+	Method string
+	// Url is the url of the request, whose password and secret query parameters are redacted.
+	Url string
+	// Attempt is the number of attempts the request has been sent, including retries.
+	Attempt int
+	// Elapsed is the time taken from the first attempt to the failure.
+	Elapsed time.Duration
+	// Err is the error of the transport, or of reading the response.
+	Err error
+	// Problem is decoded from the body of an application/problem+json response.
+	Problem *Problem
+
+	// readerOpts are options of the Client that received Response, used to decode Body by DecodeError
+	readerOpts []sio.ReaderOption
 }
 
 func (e Error) Error() string {
 	msg := bytes.Buffer{}
+	if e.Method != "" || e.Url != "" {
+		msg.WriteString(strings.TrimSpace(e.Method + " " + e.Url))
+		if e.Attempt > 1 {
+			msg.WriteString(fmt.Sprintf(" (attempt %d)", e.Attempt))
+		}
+		msg.WriteString(": ")
+	}
+	if e.Response == nil && e.Err != nil {
+		msg.WriteString(errorMessage(e.Err))
+		return msg.String()
+	}
+
 	if e.Response == nil {
 		msg.WriteString("status: unknown")
 	} else {
@@ -25,9 +81,48 @@ func (e Error) Error() string {
 		}
 		msg.WriteString(fmt.Sprintf("body: %s", e.Body))
 	}
+	if e.Err != nil {
+		msg.WriteString(", error: " + errorMessage(e.Err))
+	}
 	return msg.String()
 }
 
+// errorMessage returns the message of err without the method and url of url.Error, which are in Error already.
+func errorMessage(err error) string {
+	var ue *url.Error
+	if errors.As(err, &ue) && ue.Err != nil {
+		return ue.Err.Error()
+	}
+	return err.Error()
+}
+
+// Unwrap returns Err.
+func (e Error) Unwrap() error {
+	return e.Err
+}
+
+// Is reports whether e is classified as target, which is one of ErrTimeout, ErrTemporary, ErrCanceled,
+// ErrClientError and ErrServerError.
+func (e Error) Is(target error) bool {
+	status := e.GetStatusCode(0)
+	switch target {
+	case ErrTimeout:
+		return status == http.StatusRequestTimeout || status == http.StatusGatewayTimeout || isTimeout(e.Err)
+	case ErrTemporary:
+		if e.Err != nil {
+			return isTemporary(e.Err)
+		}
+		return DefaultRetryClassifier(e.Response, nil)
+	case ErrCanceled:
+		return errors.Is(e.Err, context.Canceled)
+	case ErrClientError:
+		return status >= 400 && status <= 499
+	case ErrServerError:
+		return status >= 500 && status <= 599
+	}
+	return false
+}
+
 func (e Error) GetStatusCode(defaultStatusCode int) int {
 	if e.Response != nil {
 		return e.Response.StatusCode
@@ -40,3 +135,228 @@ func (e Error) GetStatus(defaultStatusCode int) string {
 	}
 	return http.StatusText(defaultStatusCode)
 }
+
+// IsTimeout reports whether err is caused by a timeout of the transport or the context,
+// or a response of 408 or 504 status.
+func IsTimeout(err error) bool {
+	return errors.Is(err, ErrTimeout) || isTimeout(err)
+}
+
+// IsTemporary reports whether err is likely to succeed if the request is sent again, such as timeouts,
+// reset or refused connections, open circuits, full bulkheads, or a response of 429, 502, 503 or 504 status.
+func IsTemporary(err error) bool {
+	return errors.Is(err, ErrTemporary) || isTemporary(err)
+}
+
+// IsCanceled reports whether err is caused by a canceled context.
+func IsCanceled(err error) bool {
+	return errors.Is(err, context.Canceled)
+}
+
+// IsClientError reports whether err is an Error of 4xx status.
+func IsClientError(err error) bool {
+	return errors.Is(err, ErrClientError)
+}
+
+// IsServerError reports whether err is an Error of 5xx status.
+func IsServerError(err error) bool {
+	return errors.Is(err, ErrServerError)
+}
+
+func isTimeout(err error) bool {
+	if err == nil {
+		return false
+	}
+	if errors.Is(err, context.DeadlineExceeded) {
+		return true
+	}
+	var ne net.Error
+	return errors.As(err, &ne) && ne.Timeout()
+}
+
+func isTemporary(err error) bool {
+	if err == nil {
+		return false
+	}
+	return DefaultRetryClassifier(nil, err) ||
+		errors.Is(err, context.DeadlineExceeded) ||
+		errors.Is(err, ErrCircuitOpen) ||
+		errors.Is(err, ErrBulkheadFull)
+}
+
+// Problem is the problem details of RFC 7807.
+type Problem struct {
+	Type     string
+	Title    string
+	Status   int
+	Detail   string
+	Instance string
+	// Extensions are members other than the ones above.
+	Extensions map[string]any
+}
+
+// MarshalJSON encodes p as an object of RFC 7807 members, omitting empty ones, with Extensions as members.
+func (p Problem) MarshalJSON() ([]byte, error) {
+	members := make(map[string]any, len(p.Extensions)+5)
+	for k, v := range p.Extensions {
+		members[k] = v
+	}
+	for k, v := range map[string]string{"type": p.Type, "title": p.Title, "detail": p.Detail, "instance": p.Instance} {
+		if v != "" {
+			members[k] = v
+		}
+	}
+	if p.Status != 0 {
+		members["status"] = p.Status
+	}
+	return codec.Marshal(codec.FormatJson, members)
+}
+
+// MarshalXML encodes p as a problem element of RFC 7807 appendix A, omitting empty members, with Extensions
+// as child elements in order of their names. Elements of slices and arrays are encoded as i elements, and
+// values of maps as child elements.
+func (p Problem) MarshalXML(e *xml.Encoder, _ xml.StartElement) error {
+	start := xml.StartElement{Name: xml.Name{Space: "urn:ietf:rfc:7807", Local: "problem"}}
+	if err := e.EncodeToken(start); err != nil {
+		return err
+	}
+	for _, m := range []struct {
+		name  string
+		value any
+	}{{"type", p.Type}, {"title", p.Title}, {"status", p.Status}, {"detail", p.Detail}, {"instance", p.Instance}} {
+		if reflect.ValueOf(m.value).IsZero() {
+			continue
+		}
+		if err := encodeXmlMember(e, m.name, m.value); err != nil {
+			return err
+		}
+	}
+	for _, k := range slices.Sorted(maps.Keys(p.Extensions)) {
+		if err := encodeXmlMember(e, k, p.Extensions[k]); err != nil {
+			return err
+		}
+	}
+	return e.EncodeToken(start.End())
+}
+
+// encodeXmlMember encodes v as an element of name as described in Problem.MarshalXML.
+func encodeXmlMember(e *xml.Encoder, name string, v any) error {
+	start := xml.StartElement{Name: xml.Name{Local: name}}
+	rv := reflect.ValueOf(v)
+	switch {
+	case rv.Kind() == reflect.Map && rv.Type().Key().Kind() == reflect.String:
+		if err := e.EncodeToken(start); err != nil {
+			return err
+		}
+		keys := rv.MapKeys()
+		slices.SortFunc(keys, func(a, b reflect.Value) int {
+			return strings.Compare(a.String(), b.String())
+		})
+		for _, k := range keys {
+			if err := encodeXmlMember(e, k.String(), rv.MapIndex(k).Interface()); err != nil {
+				return err
+			}
+		}
+		return e.EncodeToken(start.End())
+	case (rv.Kind() == reflect.Slice || rv.Kind() == reflect.Array) && rv.Type().Elem().Kind() != reflect.Uint8:
+		if err := e.EncodeToken(start); err != nil {
+			return err
+		}
+		for i := 0; i < rv.Len(); i++ {
+			if err := encodeXmlMember(e, "i", rv.Index(i).Interface()); err != nil {
+				return err
+			}
+		}
+		return e.EncodeToken(start.End())
+	default:
+		return e.EncodeElement(v, start)
+	}
+}
+
+// decodeProblem decodes body if resp is an application/problem+json response. It returns nil otherwise.
+func decodeProblem(resp *http.Response, body []byte) *Problem {
+	if resp == nil || len(body) == 0 {
+		return nil
+	}
+	mediaType, _, err := mime.ParseMediaType(resp.Header.Get("Content-Type"))
+	if err != nil || mediaType != "application/problem+json" {
+		return nil
+	}
+	members, err := codec.Unmarshal[map[string]any](codec.FormatJson, body)
+	if err != nil {
+		return nil
+	}
+
+	p := &Problem{}
+	for k, v := range members {
+		switch k {
+		case "type":
+			p.Type, _ = v.(string)
+		case "title":
+			p.Title, _ = v.(string)
+		case "status":
+			if f, ok := v.(float64); ok {
+				p.Status = int(f)
+			}
+		case "detail":
+			p.Detail, _ = v.(string)
+		case "instance":
+			p.Instance, _ = v.(string)
+		default:
+			if p.Extensions == nil {
+				p.Extensions = make(map[string]any)
+			}
+			p.Extensions[k] = v
+		}
+	}
+	return p
+}
+
+// _secretQueryParams are query parameters redacted by redactUrl, compared case-insensitively.
+var _secretQueryParams = []string{
+	"access_token", "api_key", "apikey", "client_secret", "key", "password", "secret", "sig", "signature", "token",
+}
+
+// redactUrl returns u as a string, replacing its password and the values of secret query parameters with "xxxxx".
+func redactUrl(u *url.URL) string {
+	if u == nil {
+		return ""
+	}
+	if u.RawQuery == "" {
+		return u.Redacted()
+	}
+
+	q := u.Query()
+	redacted := false
+	for k, v := range q {
+		if !containsString(_secretQueryParams, strings.ToLower(k)) {
+			continue
+		}
+		for i := range v {
+			v[i] = "xxxxx"
+		}
+		redacted = true
+	}
+	if !redacted {
+		return u.Redacted()
+	}
+	ru := *u
+	ru.RawQuery = q.Encode()
+	return ru.Redacted()
+}
+
+// WriteValidationError writes err as a json response with 400 status code if it is a *sio.ValidationError,
+// then reports whether it has written.
+//
+//	{"errors":[{"field":"books[0].title","tag":"required","message":"books[0].title failed on 'required'"}]}
+func WriteValidationError(w http.ResponseWriter, err error) bool {
+	var ve *sio.ValidationError
+	if !errors.As(err, &ve) {
+		return false
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusBadRequest)
+	_ = codec.EncodeJson(w, ve)
+	return true
+}
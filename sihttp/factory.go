@@ -1,10 +1,13 @@
 package sihttp
 
 import (
+	"context"
 	"crypto/tls"
 	"net"
 	"net/http"
 	"time"
+
+	"golang.org/x/net/http2"
 )
 
 // DefaultInsecureStandardClient instantiate http.Client with InsecureSkipVerify set to true
@@ -17,19 +20,8 @@ func DefaultInsecureStandardClient() *http.Client {
 
 // DefaultStandardClient instantiate http.Client with input parameter `tlsConfig`
 func DefaultStandardClient(tlsConfig *tls.Config) *http.Client {
-
-	dialer := &net.Dialer{Timeout: 5 * time.Second}
-
-	tr := &http.Transport{
-		MaxIdleConns:       50,
-		IdleConnTimeout:    time.Duration(60) * time.Second,
-		DisableCompression: false,
-		TLSClientConfig:    tlsConfig,
-		DisableKeepAlives:  false,
-		Dial:               dialer.Dial,
-	}
-
-	return NewStandardClient(30*time.Second, tr)
+	conf := newTransportConfig([]TransportOption{WithTransportTLSConfig(tlsConfig)})
+	return NewStandardClient(30*time.Second, conf.transport())
 }
 
 func NewStandardClient(clientTimeout time.Duration, transport *http.Transport) *http.Client {
@@ -40,3 +32,152 @@ func NewStandardClient(clientTimeout time.Duration, transport *http.Transport) *
 	}
 	return client
 }
+
+// TransportOption is an option of NewTransport.
+type TransportOption interface {
+	apply(c *transportConfig)
+}
+
+// TransportOptionFunc wraps a function to conforms to TransportOption interface.
+type TransportOptionFunc func(c *transportConfig)
+
+func (o TransportOptionFunc) apply(c *transportConfig) {
+	o(c)
+}
+
+type transportConfig struct {
+	dialer              *net.Dialer
+	tlsConfig           *tls.Config
+	maxIdleConns        int
+	maxIdleConnsPerHost int
+	maxConnsPerHost     int
+	idleConnTimeout     time.Duration
+	http2               bool
+	h2c                 bool
+}
+
+func newTransportConfig(opts []TransportOption) *transportConfig {
+	c := &transportConfig{
+		dialer:          &net.Dialer{Timeout: 5 * time.Second, KeepAlive: 30 * time.Second},
+		maxIdleConns:    50,
+		idleConnTimeout: 60 * time.Second,
+	}
+	for _, o := range opts {
+		if o == nil {
+			continue
+		}
+		o.apply(c)
+	}
+	return c
+}
+
+// WithDialTimeout sets the timeout of dialing a connection, which is 5 seconds by default.
+func WithDialTimeout(d time.Duration) TransportOptionFunc {
+	return TransportOptionFunc(func(c *transportConfig) {
+		c.dialer.Timeout = d
+	})
+}
+
+// WithTransportTLSConfig sets the tls.Config of connections.
+func WithTransportTLSConfig(conf *tls.Config) TransportOptionFunc {
+	return TransportOptionFunc(func(c *transportConfig) {
+		c.tlsConfig = conf
+	})
+}
+
+// WithMaxConnsPerHost limits connections per host including ones in use. It is unlimited by default.
+func WithMaxConnsPerHost(n int) TransportOptionFunc {
+	return TransportOptionFunc(func(c *transportConfig) {
+		c.maxConnsPerHost = n
+	})
+}
+
+// WithMaxIdleConns limits idle connections in total and per host, which are 50 and 2 by default.
+func WithMaxIdleConns(n, perHost int) TransportOptionFunc {
+	return TransportOptionFunc(func(c *transportConfig) {
+		c.maxIdleConns = n
+		c.maxIdleConnsPerHost = perHost
+	})
+}
+
+// WithIdleConnTimeout sets how long an idle connection is kept, which is 60 seconds by default.
+func WithIdleConnTimeout(d time.Duration) TransportOptionFunc {
+	return TransportOptionFunc(func(c *transportConfig) {
+		c.idleConnTimeout = d
+	})
+}
+
+// WithTransportHTTP2 attempts HTTP/2 over TLS, falling back to HTTP/1.1 if the server doesn't support it.
+func WithTransportHTTP2() TransportOptionFunc {
+	return TransportOptionFunc(func(c *transportConfig) {
+		c.http2 = true
+	})
+}
+
+// WithTransportH2C sends requests of http urls in HTTP/2 over cleartext(h2c) with prior knowledge,
+// for internal traffic to servers known to support it. Requests of https urls are sent as WithTransportHTTP2.
+func WithTransportH2C() TransportOptionFunc {
+	return TransportOptionFunc(func(c *transportConfig) {
+		c.http2 = true
+		c.h2c = true
+	})
+}
+
+// NewTransport returns an http.RoundTripper configured by opts. It is an *http.Transport unless
+// WithTransportH2C is set.
+//
+//	client := &http.Client{
+//		Timeout:   30 * time.Second,
+//		Transport: sihttp.NewTransport(sihttp.WithTransportHTTP2(), sihttp.WithMaxConnsPerHost(100)),
+//	}
+func NewTransport(opts ...TransportOption) http.RoundTripper {
+	c := newTransportConfig(opts)
+	tr := c.transport()
+	if !c.h2c {
+		return tr
+	}
+	return &h2cTransport{
+		h2c: &http2.Transport{
+			AllowHTTP: true,
+			DialTLSContext: func(ctx context.Context, network, addr string, _ *tls.Config) (net.Conn, error) {
+				return c.dialer.DialContext(ctx, network, addr)
+			},
+			IdleConnTimeout: c.idleConnTimeout,
+		},
+		tls: tr,
+	}
+}
+
+// transport returns an http.Transport of c. tls.Config is cloned since HTTP/2 adds its protocol to it.
+func (c *transportConfig) transport() *http.Transport {
+	return &http.Transport{
+		DialContext:           c.dialer.DialContext,
+		TLSClientConfig:       c.tlsConfig.Clone(),
+		TLSHandshakeTimeout:   10 * time.Second,
+		ExpectContinueTimeout: time.Second,
+		MaxIdleConns:          c.maxIdleConns,
+		MaxIdleConnsPerHost:   c.maxIdleConnsPerHost,
+		MaxConnsPerHost:       c.maxConnsPerHost,
+		IdleConnTimeout:       c.idleConnTimeout,
+		ForceAttemptHTTP2:     c.http2,
+	}
+}
+
+// h2cTransport sends requests of http urls by h2c, and others by tls.
+type h2cTransport struct {
+	h2c *http2.Transport
+	tls *http.Transport
+}
+
+func (t *h2cTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.URL.Scheme == "http" {
+		return t.h2c.RoundTrip(req)
+	}
+	return t.tls.RoundTrip(req)
+}
+
+// CloseIdleConnections closes idle connections of both transports.
+func (t *h2cTransport) CloseIdleConnections() {
+	t.h2c.CloseIdleConnections()
+	t.tls.CloseIdleConnections()
+}
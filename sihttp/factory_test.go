@@ -7,6 +7,7 @@ import (
 	"testing"
 	"time"
 
+	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 )
 
@@ -38,3 +39,19 @@ func Test_NewStandardClient(t *testing.T) {
 	c := NewStandardClient(30*time.Second, tr)
 	require.NotNil(t, c)
 }
+
+func Test_NewTransport(t *testing.T) {
+	config := &tls.Config{}
+	tr, ok := NewTransport(WithTransportTLSConfig(config), WithMaxConnsPerHost(10), WithMaxIdleConns(20, 5),
+		WithIdleConnTimeout(time.Second), WithDialTimeout(time.Second), WithTransportHTTP2()).(*http.Transport)
+	require.True(t, ok)
+	assert.EqualValues(t, 10, tr.MaxConnsPerHost)
+	assert.EqualValues(t, 20, tr.MaxIdleConns)
+	assert.EqualValues(t, 5, tr.MaxIdleConnsPerHost)
+	assert.EqualValues(t, time.Second, tr.IdleConnTimeout)
+	assert.True(t, tr.ForceAttemptHTTP2)
+	assert.NotSame(t, config, tr.TLSClientConfig)
+
+	_, ok = NewTransport(WithTransportH2C()).(*h2cTransport)
+	assert.True(t, ok)
+}
@@ -0,0 +1,74 @@
+package sihttp
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"net/http"
+
+	"github.com/wonksing/si/v2/sio"
+)
+
+// Get sends a GET request to url, which is appended to the base url of c, then decodes its response body into T.
+func Get[T any](ctx context.Context, c *Client, url string, opts ...RequestOption) (T, error) {
+	var res T
+	if err := c.requestDecode(ctx, http.MethodGet, c.baseUrl+url, nil, nil, nil, &res, opts...); err != nil {
+		var zero T
+		return zero, err
+	}
+	return res, nil
+}
+
+// Post sends a POST request to url, which is appended to the base url of c, with body encoded,
+// then decodes its response body into Res.
+func Post[Req, Res any](ctx context.Context, c *Client, url string, body Req, opts ...RequestOption) (Res, error) {
+	var res Res
+	if err := c.requestDecode(ctx, http.MethodPost, c.baseUrl+url, nil, nil, body, &res, opts...); err != nil {
+		var zero Res
+		return zero, err
+	}
+	return res, nil
+}
+
+// ErrorBody is an Error whose body is decoded into E.
+type ErrorBody[E any] struct {
+	Err   *Error
+	Value E
+}
+
+func (e *ErrorBody[E]) Error() string {
+	return e.Err.Error()
+}
+
+// Unwrap returns the underlying Error.
+func (e *ErrorBody[E]) Unwrap() error {
+	return e.Err
+}
+
+// StatusCode returns the status code of the response.
+func (e *ErrorBody[E]) StatusCode() int {
+	return e.Err.GetStatusCode(0)
+}
+
+// DecodeError decodes the body of a non-2xx response carried by err into E with the reader options of the Client
+// that received it. It reports false if err doesn't carry a response body or the body cannot be decoded into E.
+//
+//	user, err := sihttp.Get[User](ctx, c, "/users/1")
+//	if eb, ok := sihttp.DecodeError[ApiError](err); ok {
+//		log.Println(eb.Value.Code)
+//	}
+func DecodeError[E any](err error) (*ErrorBody[E], bool) {
+	var e *Error
+	if !errors.As(err, &e) || e.Response == nil || len(e.Body) == 0 {
+		return nil, false
+	}
+
+	r := sio.GetReader(bytes.NewReader(e.Body), e.readerOpts...)
+	defer sio.PutReader(r)
+
+	eb := &ErrorBody[E]{Err: e}
+	if err := r.Decode(&eb.Value); err != nil {
+		return nil, false
+	}
+	return eb, true
+}
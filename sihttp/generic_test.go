@@ -0,0 +1,110 @@
+package sihttp
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/wonksing/si/v2/sio"
+)
+
+type _testApiError struct {
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+func newGenericTestServer(t *testing.T) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/hello":
+			assert.EqualValues(t, http.MethodGet, r.Method)
+			w.Write([]byte(`{"msg":"hello ` + r.URL.Query().Get("name") + `"}`))
+		case "/echo":
+			assert.EqualValues(t, http.MethodPost, r.Method)
+			b, _ := io.ReadAll(r.Body)
+			w.Write(b)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+			w.Write([]byte(`{"code":"not_found","message":"` + r.URL.Path + ` not found"}`))
+		}
+	}))
+}
+
+func TestGet(t *testing.T) {
+	svr := newGenericTestServer(t)
+	defer svr.Close()
+
+	c := NewClient(_newStandardClient(), WithBaseUrl(svr.URL),
+		WithWriterOpt(sio.SetJsonEncoder()),
+		WithReaderOpt(sio.SetJsonDecoder()),
+	)
+
+	res, err := Get[_testStruct](context.Background(), c, "/hello?name=wonk")
+	require.Nil(t, err)
+	assert.EqualValues(t, "hello wonk", res.Msg)
+
+	ptr, err := Get[*_testStruct](context.Background(), c, "/hello?name=si")
+	require.Nil(t, err)
+	assert.EqualValues(t, "hello si", ptr.Msg)
+
+	m, err := Get[map[string]string](context.Background(), c, "/hello")
+	require.Nil(t, err)
+	assert.EqualValues(t, "hello ", m["msg"])
+}
+
+func TestPost(t *testing.T) {
+	svr := newGenericTestServer(t)
+	defer svr.Close()
+
+	c := NewClient(_newStandardClient(), WithBaseUrl(svr.URL),
+		WithWriterOpt(sio.SetJsonEncoder()),
+		WithReaderOpt(sio.SetJsonDecoder()),
+	)
+
+	res, err := Post[_testStruct, _testStruct](context.Background(), c, "/echo", _testStruct{Msg: "echo"})
+	require.Nil(t, err)
+	assert.EqualValues(t, "echo", res.Msg)
+
+	res, err = Post[[]byte, _testStruct](context.Background(), c, "/echo", []byte(`{"msg":"raw"}`))
+	require.Nil(t, err)
+	assert.EqualValues(t, "raw", res.Msg)
+}
+
+func TestDecodeError(t *testing.T) {
+	svr := newGenericTestServer(t)
+	defer svr.Close()
+
+	c := NewClient(_newStandardClient(), WithBaseUrl(svr.URL),
+		WithWriterOpt(sio.SetJsonEncoder()),
+		WithReaderOpt(sio.SetJsonDecoder()),
+	)
+
+	res, err := Get[_testStruct](context.Background(), c, "/unknown")
+	require.NotNil(t, err)
+	assert.EqualValues(t, _testStruct{}, res)
+
+	eb, ok := DecodeError[_testApiError](err)
+	require.True(t, ok)
+	assert.EqualValues(t, http.StatusNotFound, eb.StatusCode())
+	assert.EqualValues(t, "not_found", eb.Value.Code)
+	assert.EqualValues(t, "/unknown not found", eb.Value.Message)
+
+	var e *Error
+	assert.True(t, errors.As(eb, &e))
+	assert.EqualValues(t, err.Error(), eb.Error())
+
+	_, err = c.Get("/unknown", nil, nil)
+	eb, ok = DecodeError[_testApiError](err)
+	require.True(t, ok)
+	assert.EqualValues(t, "not_found", eb.Value.Code)
+
+	_, ok = DecodeError[_testApiError](errors.New("not an http error"))
+	assert.False(t, ok)
+	_, ok = DecodeError[_testApiError](nil)
+	assert.False(t, ok)
+}
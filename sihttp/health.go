@@ -0,0 +1,182 @@
+package sihttp
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/wonksing/si/v2/codec"
+)
+
+// Paths of the handlers served by Server with WithHealth and WithMetrics.
+const (
+	HealthzPath = "/healthz"
+	ReadyzPath  = "/readyz"
+	MetricsPath = "/metrics"
+)
+
+// Statuses of HealthStatus and CheckStatus.
+const (
+	HealthOk   = "ok"
+	HealthFail = "fail"
+)
+
+const defaultHealthCheckTimeout = 5 * time.Second
+
+var errShuttingDown = errors.New("shutting down")
+
+// HealthChecker checks the health of a dependency, such as a database or a message broker.
+// sisql.SqlDB, sikafka.SyncProducer, sirabbitmq.Conn and siwebsocket.WsHub implement it.
+type HealthChecker interface {
+	HealthCheck(ctx context.Context) error
+}
+
+// HealthCheckerFunc wraps a function to conforms to HealthChecker interface.
+type HealthCheckerFunc func(ctx context.Context) error
+
+// HealthCheck implements HealthChecker's HealthCheck method.
+func (f HealthCheckerFunc) HealthCheck(ctx context.Context) error {
+	return f(ctx)
+}
+
+// HealthStatus is the result of Health.Check.
+//
+//	{"status":"fail","checks":{"db":{"status":"ok","elapsed":"1.2ms"},"kafka":{"status":"fail","error":"...","elapsed":"5s"}}}
+type HealthStatus struct {
+	Status string                 `json:"status"`
+	Error  string                 `json:"error,omitempty"`
+	Checks map[string]CheckStatus `json:"checks,omitempty"`
+}
+
+// CheckStatus is the result of a HealthChecker.
+type CheckStatus struct {
+	Status  string `json:"status"`
+	Error   string `json:"error,omitempty"`
+	Elapsed string `json:"elapsed"`
+}
+
+type healthCheck struct {
+	name    string
+	checker HealthChecker
+	timeout time.Duration
+}
+
+// Health is a registry of HealthCheckers.
+type Health struct {
+	mu     sync.RWMutex
+	checks []healthCheck
+}
+
+// NewHealth returns Health.
+func NewHealth() *Health {
+	return &Health{}
+}
+
+// Register registers checker of name, replacing the one of the same name. The check fails if it does not
+// return in timeout, which is 5 seconds if zero.
+func (h *Health) Register(name string, checker HealthChecker, timeout time.Duration) {
+	if timeout <= 0 {
+		timeout = defaultHealthCheckTimeout
+	}
+	c := healthCheck{name: name, checker: checker, timeout: timeout}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for i := range h.checks {
+		if h.checks[i].name == name {
+			h.checks[i] = c
+			return
+		}
+	}
+	h.checks = append(h.checks, c)
+}
+
+// Check runs the registered checks concurrently, and returns their statuses. Its status is HealthOk only if
+// all of them succeed.
+func (h *Health) Check(ctx context.Context) HealthStatus {
+	h.mu.RLock()
+	checks := make([]healthCheck, len(h.checks))
+	copy(checks, h.checks)
+	h.mu.RUnlock()
+
+	statuses := make([]CheckStatus, len(checks))
+	var wg sync.WaitGroup
+	for i, c := range checks {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			statuses[i] = c.run(ctx)
+		}()
+	}
+	wg.Wait()
+
+	hs := HealthStatus{Status: HealthOk}
+	if len(checks) > 0 {
+		hs.Checks = make(map[string]CheckStatus, len(checks))
+	}
+	for i, c := range checks {
+		hs.Checks[c.name] = statuses[i]
+		if statuses[i].Status != HealthOk {
+			hs.Status = HealthFail
+		}
+	}
+	return hs
+}
+
+// run runs the check, returning when it times out even if the checker does not.
+func (c healthCheck) run(ctx context.Context) CheckStatus {
+	ctx, cancel := context.WithTimeout(ctx, c.timeout)
+	defer cancel()
+
+	start := time.Now()
+	errc := make(chan error, 1)
+	go func() {
+		errc <- c.checker.HealthCheck(ctx)
+	}()
+
+	var err error
+	select {
+	case err = <-errc:
+	case <-ctx.Done():
+		err = ctx.Err()
+	}
+
+	cs := CheckStatus{Status: HealthOk, Elapsed: time.Since(start).String()}
+	if err != nil {
+		cs.Status = HealthFail
+		cs.Error = err.Error()
+	}
+	return cs
+}
+
+// Handler returns a handler responding with the json of Check, of 200 status if it is HealthOk or 503 otherwise.
+func (h *Health) Handler() http.Handler {
+	return h.handler(nil)
+}
+
+// ReadinessHandler returns Handler that fails without running checks if ready returns false.
+func (h *Health) ReadinessHandler(ready func() bool) http.Handler {
+	return h.handler(ready)
+}
+
+func (h *Health) handler(ready func() bool) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var hs HealthStatus
+		if ready != nil && !ready() {
+			hs = HealthStatus{Status: HealthFail, Error: errShuttingDown.Error()}
+		} else {
+			hs = h.Check(r.Context())
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Cache-Control", "no-store")
+		if hs.Status == HealthOk {
+			w.WriteHeader(http.StatusOK)
+		} else {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+		_ = codec.EncodeJson(w, hs)
+	})
+}
@@ -0,0 +1,111 @@
+package sihttp
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/wonksing/si/v2/codec"
+)
+
+func TestHealth_Check(t *testing.T) {
+	h := NewHealth()
+	h.Register("db", HealthCheckerFunc(func(ctx context.Context) error { return nil }), 0)
+	h.Register("kafka", HealthCheckerFunc(func(ctx context.Context) error { return errors.New("broker down") }), 0)
+	h.Register("slow", HealthCheckerFunc(func(ctx context.Context) error {
+		// ignores ctx
+		time.Sleep(time.Second)
+		return nil
+	}), 20*time.Millisecond)
+
+	start := time.Now()
+	hs := h.Check(context.Background())
+	assert.Less(t, time.Since(start), 500*time.Millisecond)
+	assert.EqualValues(t, HealthFail, hs.Status)
+	require.Len(t, hs.Checks, 3)
+	assert.EqualValues(t, HealthOk, hs.Checks["db"].Status)
+	assert.EqualValues(t, CheckStatus{Status: HealthFail, Error: "broker down", Elapsed: hs.Checks["kafka"].Elapsed}, hs.Checks["kafka"])
+	assert.EqualValues(t, context.DeadlineExceeded.Error(), hs.Checks["slow"].Error)
+
+	// replaced
+	h.Register("kafka", HealthCheckerFunc(func(ctx context.Context) error { return nil }), 0)
+	h.Register("slow", HealthCheckerFunc(func(ctx context.Context) error { return nil }), 0)
+	hs = h.Check(context.Background())
+	assert.EqualValues(t, HealthOk, hs.Status)
+	assert.Len(t, hs.Checks, 3)
+
+	assert.EqualValues(t, HealthStatus{Status: HealthOk}, NewHealth().Check(context.Background()))
+}
+
+func TestServer_Probes(t *testing.T) {
+	var dbErr error
+	health := NewHealth()
+	health.Register("db", HealthCheckerFunc(func(ctx context.Context) error { return dbErr }), time.Second)
+	metrics := NewServerMetrics()
+
+	s := NewServerWithOptions(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/missing" {
+			http.NotFound(w, r)
+			return
+		}
+		w.Write([]byte(r.URL.Path))
+	}), WithHealth(health), WithMetrics(metrics))
+	svr := httptest.NewServer(s.Server.Handler)
+	defer svr.Close()
+
+	get := func(path string) (int, []byte) {
+		resp, err := http.Get(svr.URL + path)
+		require.Nil(t, err)
+		defer resp.Body.Close()
+		b, _ := io.ReadAll(resp.Body)
+		return resp.StatusCode, b
+	}
+
+	status, b := get(HealthzPath)
+	assert.EqualValues(t, http.StatusOK, status)
+	hs, err := codec.Unmarshal[HealthStatus](codec.FormatJson, b)
+	require.Nil(t, err)
+	assert.EqualValues(t, HealthOk, hs.Checks["db"].Status)
+
+	dbErr = errors.New("connection refused")
+	status, b = get(ReadyzPath)
+	assert.EqualValues(t, http.StatusServiceUnavailable, status)
+	assert.Contains(t, string(b), `"error":"connection refused"`)
+
+	dbErr = nil
+	status, _ = get(ReadyzPath)
+	assert.EqualValues(t, http.StatusOK, status)
+
+	s.draining.Store(true)
+	status, b = get(ReadyzPath)
+	assert.EqualValues(t, http.StatusServiceUnavailable, status)
+	assert.JSONEq(t, `{"status":"fail","error":"shutting down"}`, string(b))
+	// liveness is kept while draining
+	status, _ = get(HealthzPath)
+	assert.EqualValues(t, http.StatusOK, status)
+
+	status, b = get("/books")
+	assert.EqualValues(t, http.StatusOK, status)
+	assert.EqualValues(t, "/books", string(b))
+	status, _ = get("/missing")
+	assert.EqualValues(t, http.StatusNotFound, status)
+
+	// probes are not counted
+	total := metrics.Total()
+	assert.EqualValues(t, 2, total.Requests)
+	assert.EqualValues(t, 1, total.Status2xx)
+	assert.EqualValues(t, 1, total.Status4xx)
+
+	status, b = get(MetricsPath)
+	assert.EqualValues(t, http.StatusOK, status)
+	assert.Contains(t, string(b), "http_server_requests_total{code=\"2xx\"} 1\n")
+	assert.Contains(t, string(b), "http_server_requests_total{code=\"4xx\"} 1\n")
+	assert.Contains(t, string(b), "http_server_request_duration_seconds_count 2\n")
+	assert.Contains(t, string(b), "http_server_requests_in_flight 0\n")
+}
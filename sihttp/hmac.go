@@ -0,0 +1,233 @@
+package sihttp
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/wonksing/si/v2/sio"
+)
+
+const (
+	defaultHmacMaxSkew        = 5 * time.Minute
+	defaultHmacNonceCacheSize = 10000
+)
+
+// ErrBodyNotRewindable is returned by WithHeaderHmac256 with HmacOptions for a body without GetBody,
+// which can't be signed without consuming it.
+var ErrBodyNotRewindable = errors.New("sihttp: request body can't be read again")
+
+var (
+	errHmacMismatch  = errors.New("hmac mismatch")
+	errHmacTimestamp = errors.New("hmac timestamp is missing or out of range")
+	errHmacNonce     = errors.New("hmac nonce is missing or used")
+)
+
+// HmacOption configures replay protection of WithHeaderHmac256 and VerifyHeaderHmac256. A client and a server
+// must use the same options, as the timestamp and the nonce are signed along with the body.
+type HmacOption interface {
+	apply(c *hmacConfig)
+}
+
+// HmacOptionFunc wraps a function to conforms to HmacOption interface.
+type HmacOptionFunc func(c *hmacConfig)
+
+func (o HmacOptionFunc) apply(c *hmacConfig) {
+	o(c)
+}
+
+type hmacConfig struct {
+	timestampHeader string
+	maxSkew         time.Duration
+	nonceHeader     string
+	nonceCacheSize  int
+	maxBodyBytes    int64
+}
+
+func newHmacConfig(opts []HmacOption) hmacConfig {
+	c := hmacConfig{maxSkew: defaultHmacMaxSkew, nonceCacheSize: defaultHmacNonceCacheSize,
+		maxBodyBytes: DefaultMaxVerifyBodyBytes}
+	for _, o := range opts {
+		if o == nil {
+			continue
+		}
+		o.apply(&c)
+	}
+	return c
+}
+
+// newNonceCache returns a nonceCache remembering nonces for 2*maxSkew, since a request stamped maxSkew ahead
+// is accepted until 2*maxSkew after it is received.
+func (c hmacConfig) newNonceCache() *nonceCache {
+	return newNonceCache(c.nonceCacheSize, 2*c.maxSkew)
+}
+
+// WithHmacTimestamp signs the unix time in seconds in header. Servers reject requests whose time differs
+// from theirs by more than maxSkew, which is 5 minutes if zero.
+func WithHmacTimestamp(header string, maxSkew time.Duration) HmacOptionFunc {
+	return HmacOptionFunc(func(c *hmacConfig) {
+		c.timestampHeader = header
+		if maxSkew > 0 {
+			c.maxSkew = maxSkew
+		}
+	})
+}
+
+// WithHmacNonce signs a random nonce in header. Servers reject requests of a nonce they have seen, remembering
+// up to cacheSize nonces, 10000 if zero. Use it with WithHmacTimestamp, which bounds how long a nonce has to be
+// remembered. Nonces are forgotten after twice the max skew.
+func WithHmacNonce(header string, cacheSize int) HmacOptionFunc {
+	return HmacOptionFunc(func(c *hmacConfig) {
+		c.nonceHeader = header
+		if cacheSize > 0 {
+			c.nonceCacheSize = cacheSize
+		}
+	})
+}
+
+// WithHmacMaxBodyBytes limits the size of bodies VerifyHeaderHmac256 reads, which is DefaultMaxVerifyBodyBytes
+// by default. It has no effect on WithHeaderHmac256.
+func WithHmacMaxBodyBytes(n int64) HmacOptionFunc {
+	return HmacOptionFunc(func(c *hmacConfig) {
+		if n > 0 {
+			c.maxBodyBytes = n
+		}
+	})
+}
+
+// hmacSha256Hex returns the hex encoded HMAC-SHA256 of timestamp, nonce and body. timestamp and nonce are
+// followed by a new line if they are not empty, so the HMAC is of the body only without them.
+func hmacSha256Hex(secret []byte, timestamp, nonce string, body []byte) string {
+	h := sio.GetHmacSha256Hash(string(secret))
+	defer sio.PutHmacSha256Hash(string(secret), h)
+
+	if timestamp != "" {
+		h.Write([]byte(timestamp + "\n"))
+	}
+	if nonce != "" {
+		h.Write([]byte(nonce + "\n"))
+	}
+	h.Write(body)
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+func newNonce() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// VerifyHeaderHmac256 returns a middleware that verifies the hex encoded HMAC-SHA256 in header key signed by
+// WithHeaderHmac256 with the same secret and opts. The body is read once and restored for next handler.
+// It responds with 401 when the HMAC is missing or invalid, or the request is replayed, and 413 when the body is
+// too large.
+func VerifyHeaderHmac256(key string, secret []byte, opts ...HmacOption) func(http.Handler) http.Handler {
+	conf := newHmacConfig(opts)
+	var nonces *nonceCache
+	if conf.nonceHeader != "" {
+		nonces = conf.newNonceCache()
+	}
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			sig := r.Header.Get(key)
+			if sig == "" {
+				http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+				return
+			}
+
+			body, ok := readVerifiedBody(w, r, conf.maxBodyBytes)
+			if !ok {
+				return
+			}
+
+			if err := verifyHmac(r, body, sig, secret, conf, nonces, time.Now()); err != nil {
+				http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+func verifyHmac(r *http.Request, body []byte, sig string, secret []byte, conf hmacConfig, nonces *nonceCache, now time.Time) error {
+	var timestamp, nonce string
+	if conf.timestampHeader != "" {
+		timestamp = r.Header.Get(conf.timestampHeader)
+		sec, err := strconv.ParseInt(timestamp, 10, 64)
+		if err != nil {
+			return errHmacTimestamp
+		}
+		if skew := now.Sub(time.Unix(sec, 0)); skew > conf.maxSkew || skew < -conf.maxSkew {
+			return errHmacTimestamp
+		}
+	}
+	if conf.nonceHeader != "" {
+		if nonce = r.Header.Get(conf.nonceHeader); nonce == "" {
+			return errHmacNonce
+		}
+	}
+
+	expected := hmacSha256Hex(secret, timestamp, nonce, body)
+	if !hmac.Equal([]byte(expected), []byte(sig)) {
+		return errHmacMismatch
+	}
+
+	// nonces are remembered only if signed, so they can't be flooded by unsigned requests
+	if nonces != nil && !nonces.add(nonce, now) {
+		return errHmacNonce
+	}
+	return nil
+}
+
+// nonceCache remembers up to size nonces for ttl. The oldest one is forgotten when it is full.
+type nonceCache struct {
+	mu   sync.Mutex
+	ttl  time.Duration
+	seen map[string]seenNonce
+	ring []string
+	next int
+}
+
+// seenNonce is when a nonce was seen, and its slot in the ring.
+type seenNonce struct {
+	at   time.Time
+	slot int
+}
+
+func newNonceCache(size int, ttl time.Duration) *nonceCache {
+	return &nonceCache{
+		ttl:  ttl,
+		seen: make(map[string]seenNonce, size),
+		ring: make([]string, size),
+	}
+}
+
+// add remembers nonce, and reports whether it was not seen in ttl.
+func (c *nonceCache) add(nonce string, now time.Time) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if s, ok := c.seen[nonce]; ok {
+		if now.Sub(s.at) <= c.ttl {
+			return false
+		}
+		// the slot of an expired nonce is cleared, so that evicting it later doesn't forget the nonce added here
+		c.ring[s.slot] = ""
+	}
+
+	if old := c.ring[c.next]; old != "" {
+		delete(c.seen, old)
+	}
+	c.ring[c.next] = nonce
+	c.seen[nonce] = seenNonce{at: now, slot: c.next}
+	c.next = (c.next + 1) % len(c.ring)
+	return true
+}
@@ -0,0 +1,196 @@
+package sihttp
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/wonksing/si/v2/codec"
+)
+
+func Test_hmacSha256Hex(t *testing.T) {
+	expected, err := codec.HmacSha256HexEncodedWithReader("secret", bytes.NewBufferString("body"))
+	require.Nil(t, err)
+	assert.EqualValues(t, expected, hmacSha256Hex([]byte("secret"), "", "", []byte("body")))
+	assert.NotEqualValues(t, expected, hmacSha256Hex([]byte("secret"), "1", "", []byte("body")))
+}
+
+func TestVerifyHeaderHmac256(t *testing.T) {
+	secret := []byte("secret")
+	var received []byte
+	h := VerifyHeaderHmac256("X-Signature", secret)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		received, _ = io.ReadAll(r.Body)
+		w.Write([]byte("ok"))
+	}))
+	svr := httptest.NewServer(h)
+	defer svr.Close()
+
+	c := NewClient(_newStandardClient(), WithBaseUrl(svr.URL), WithRequestHeaderHmac256("X-Signature", secret))
+	b, err := c.Post("/path", nil, []byte(`{"a":1}`))
+	require.Nil(t, err)
+	assert.EqualValues(t, "ok", string(b))
+	assert.EqualValues(t, `{"a":1}`, string(received))
+
+	// wrong secret
+	c = NewClient(_newStandardClient(), WithBaseUrl(svr.URL), WithRequestHeaderHmac256("X-Signature", []byte("wrong")))
+	_, err = c.Post("/path", nil, []byte(`{"a":1}`))
+	require.NotNil(t, err)
+	assert.EqualValues(t, http.StatusUnauthorized, err.(*Error).GetStatusCode(0))
+
+	// requests without body are not signed without options
+	_, err = c.Get("/path", nil, nil)
+	require.NotNil(t, err)
+	assert.EqualValues(t, http.StatusUnauthorized, err.(*Error).GetStatusCode(0))
+}
+
+func TestVerifyHeaderHmac256_Replay(t *testing.T) {
+	secret := []byte("secret")
+	opts := []HmacOption{WithHmacTimestamp("X-Timestamp", time.Minute), WithHmacNonce("X-Nonce", 0)}
+	svr := httptest.NewServer(VerifyHeaderHmac256("X-Signature", secret, opts...)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("ok"))
+	})))
+	defer svr.Close()
+
+	c := NewClient(_newStandardClient(), WithBaseUrl(svr.URL), WithRequestHeaderHmac256("X-Signature", secret, opts...))
+	b, err := c.Get("/path", nil, nil)
+	require.Nil(t, err)
+	assert.EqualValues(t, "ok", string(b))
+
+	// replayed
+	req, _ := http.NewRequest(http.MethodPost, svr.URL+"/path", bytes.NewBufferString("body"))
+	require.Nil(t, WithHeaderHmac256("X-Signature", secret, opts...).apply(req))
+	assert.NotEmpty(t, req.Header.Get("X-Nonce"))
+	resp, err := http.DefaultClient.Do(req)
+	require.Nil(t, err)
+	resp.Body.Close()
+	assert.EqualValues(t, http.StatusOK, resp.StatusCode)
+
+	replayed, _ := http.NewRequest(http.MethodPost, svr.URL+"/path", bytes.NewBufferString("body"))
+	replayed.Header = req.Header.Clone()
+	resp, err = http.DefaultClient.Do(replayed)
+	require.Nil(t, err)
+	resp.Body.Close()
+	assert.EqualValues(t, http.StatusUnauthorized, resp.StatusCode)
+}
+
+func TestWithHeaderHmac256_NotRewindable(t *testing.T) {
+	secret := []byte("secret")
+	newReq := func() *http.Request {
+		req, _ := http.NewRequest(http.MethodPost, "/", io.NopCloser(strings.NewReader("body")))
+		return req
+	}
+
+	// skipped without options as before
+	req := newReq()
+	require.Nil(t, WithHeaderHmac256("X-Signature", secret).apply(req))
+	assert.Empty(t, req.Header.Get("X-Signature"))
+
+	req = newReq()
+	err := WithHeaderHmac256("X-Signature", secret, WithHmacTimestamp("X-Timestamp", 0)).apply(req)
+	assert.ErrorIs(t, err, ErrBodyNotRewindable)
+	assert.Empty(t, req.Header.Get("X-Signature"))
+}
+
+func Test_Client_WithRequestHeaderHmac256_NotRewindable(t *testing.T) {
+	var called bool
+	svr := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		called = true
+	}))
+	defer svr.Close()
+
+	secret := []byte("secret")
+	body := struct{ io.Reader }{strings.NewReader("body")}
+	c := NewClient(_newStandardClient(), WithBaseUrl(svr.URL),
+		WithRequestHeaderHmac256("X-Signature", secret, WithHmacTimestamp("X-Timestamp", 0)))
+	_, err := c.Post("/", nil, body)
+	assert.ErrorIs(t, err, ErrBodyNotRewindable)
+	err = c.PostDecode("/", nil, struct{ io.Reader }{strings.NewReader("body")}, &struct{}{})
+	assert.ErrorIs(t, err, ErrBodyNotRewindable)
+
+	// per-call options fail as well
+	c = NewClient(_newStandardClient(), WithBaseUrl(svr.URL))
+	_, err = c.Post("/", nil, struct{ io.Reader }{strings.NewReader("body")},
+		WithHeaderHmac256("X-Signature", secret, WithHmacTimestamp("X-Timestamp", 0)))
+	assert.ErrorIs(t, err, ErrBodyNotRewindable)
+	assert.False(t, called)
+}
+
+func Test_verifyHmac(t *testing.T) {
+	secret := []byte("secret")
+	conf := newHmacConfig([]HmacOption{WithHmacTimestamp("X-Timestamp", time.Minute), WithHmacNonce("X-Nonce", 2)})
+	now := time.Now()
+
+	newSigned := func(signedAt time.Time, nonce string) *http.Request {
+		ts := strconv.FormatInt(signedAt.Unix(), 10)
+		req, _ := http.NewRequest(http.MethodPost, "http://localhost/path", nil)
+		req.Header.Set("X-Timestamp", ts)
+		req.Header.Set("X-Nonce", nonce)
+		req.Header.Set("X-Signature", hmacSha256Hex(secret, ts, nonce, []byte("body")))
+		return req
+	}
+	verify := func(req *http.Request, body string, nonces *nonceCache) error {
+		return verifyHmac(req, []byte(body), req.Header.Get("X-Signature"), secret, conf, nonces, now)
+	}
+
+	nonces := conf.newNonceCache()
+	assert.Nil(t, verify(newSigned(now, "a"), "body", nonces))
+	assert.ErrorIs(t, verify(newSigned(now, "a"), "body", nonces), errHmacNonce)
+	assert.ErrorIs(t, verify(newSigned(now, "b"), "tampered", nonces), errHmacMismatch)
+	assert.ErrorIs(t, verify(newSigned(now.Add(-2*time.Minute), "c"), "body", nonces), errHmacTimestamp)
+	assert.ErrorIs(t, verify(newSigned(now.Add(2*time.Minute), "c"), "body", nonces), errHmacTimestamp)
+	assert.ErrorIs(t, verify(newSigned(now, ""), "body", nonces), errHmacNonce)
+
+	req := newSigned(now, "d")
+	req.Header.Set("X-Timestamp", strconv.FormatInt(now.Unix()-1, 10))
+	assert.ErrorIs(t, verify(req, "body", nonces), errHmacMismatch)
+
+	// the oldest nonce is forgotten when the cache is full
+	assert.Nil(t, verify(newSigned(now, "b"), "body", nonces))
+	assert.Nil(t, verify(newSigned(now, "c"), "body", nonces))
+	assert.Nil(t, verify(newSigned(now, "a"), "body", nonces))
+	assert.Len(t, nonces.seen, 2)
+}
+
+func Test_verifyHmac_FutureSkew(t *testing.T) {
+	secret := []byte("secret")
+	conf := newHmacConfig([]HmacOption{WithHmacTimestamp("X-Timestamp", time.Minute), WithHmacNonce("X-Nonce", 10)})
+	nonces := conf.newNonceCache()
+	now := time.Now()
+
+	// signed by a client whose clock is ahead by the max skew
+	ts := strconv.FormatInt(now.Add(time.Minute).Unix(), 10)
+	req, _ := http.NewRequest(http.MethodPost, "http://localhost/path", nil)
+	req.Header.Set("X-Timestamp", ts)
+	req.Header.Set("X-Nonce", "a")
+	sig := hmacSha256Hex(secret, ts, "a", []byte("body"))
+
+	assert.Nil(t, verifyHmac(req, []byte("body"), sig, secret, conf, nonces, now))
+	// the timestamp is still within the max skew, so the nonce must be remembered
+	for _, d := range []time.Duration{time.Minute + time.Second, 2*time.Minute - time.Second} {
+		assert.ErrorIs(t, verifyHmac(req, []byte("body"), sig, secret, conf, nonces, now.Add(d)), errHmacNonce)
+	}
+	assert.ErrorIs(t, verifyHmac(req, []byte("body"), sig, secret, conf, nonces, now.Add(2*time.Minute+time.Second)),
+		errHmacTimestamp)
+}
+
+func Test_nonceCache_Expired(t *testing.T) {
+	c := newNonceCache(3, time.Minute)
+	now := time.Now()
+
+	assert.True(t, c.add("a", now))
+	assert.True(t, c.add("b", now))
+	// "a" has expired, and is added again to another slot
+	later := now.Add(2 * time.Minute)
+	assert.True(t, c.add("a", later))
+	// "c" takes the old slot of "a", which must not forget "a" added later
+	assert.True(t, c.add("c", later))
+	assert.False(t, c.add("a", later))
+	assert.Len(t, c.seen, 3)
+}
@@ -1,10 +1,9 @@
 package sihttp
 
 import (
-	"bytes"
 	"context"
+	"errors"
 	"io"
-	"mime/multipart"
 	"net/http"
 	"os"
 	"time"
@@ -18,8 +17,12 @@ type Client struct {
 	baseUrl        string
 	defaultHeaders map[string]string
 
-	retryAttempts int
-	retryDelay    time.Duration
+	retryPolicy RetryPolicy
+	breakers    *circuitBreakers
+	bulkheads   *bulkheads
+
+	middlewares []ClientMiddleware
+	doer        Doer
 
 	requestOpts []RequestOption
 	writerOpts  []sio.WriterOption
@@ -29,9 +32,7 @@ type Client struct {
 // NewClient returns Client
 func NewClient(client *http.Client, opts ...ClientOption) *Client {
 	c := &Client{
-		client:        client,
-		retryAttempts: 0,
-		retryDelay:    20 * time.Millisecond,
+		client: client,
 	}
 	for _, o := range opts {
 		if o == nil {
@@ -39,21 +40,133 @@ func NewClient(client *http.Client, opts ...ClientOption) *Client {
 		}
 		o.apply(c)
 	}
+	c.doer = chainMiddlewares(DoerFunc(c.do), c.middlewares)
 
 	return c
 }
 
-// Do is a wrapper of http.Client.Do
+// Do is a wrapper of http.Client.Do. It sends request through middlewares, and sends it again
+// as long as the retry policy allows. Errors are returned as *Error wrapping the error of the transport.
 func (hc *Client) Do(request *http.Request) (*http.Response, error) {
+	resp, _, err := hc.doTrace(request)
+	return resp, err
+}
+
+// requestTrace describes how a request has been sent, for Error.
+type requestTrace struct {
+	method   string
+	url      string
+	start    time.Time
+	attempts int
+}
+
+type requestTraceKey struct{}
+
+// doTrace sends request like Do, and returns the trace of it.
+func (hc *Client) doTrace(request *http.Request) (*http.Response, *requestTrace, error) {
 	hc.setDefaultHeader(request)
 
+	tr := &requestTrace{
+		method: request.Method,
+		url:    redactUrl(request.URL),
+		start:  time.Now(),
+	}
+	req := request.WithContext(context.WithValue(request.Context(), requestTraceKey{}, tr))
+
+	doer := hc.doer
+	if doer == nil {
+		doer = DoerFunc(hc.do)
+	}
+	resp, err := doer.Do(req)
+	if err != nil {
+		var e *Error
+		if errors.As(err, &e) {
+			return resp, tr, err
+		}
+		return resp, tr, tr.error(nil, nil, err, hc.readerOpts)
+	}
+	return resp, tr, nil
+}
+
+// error returns an Error of the request with resp of body, or err.
+func (tr *requestTrace) error(resp *http.Response, body []byte, err error, readerOpts []sio.ReaderOption) *Error {
+	return &Error{
+		Response:   resp,
+		Body:       body,
+		Method:     tr.method,
+		Url:        tr.url,
+		Attempt:    tr.attempts,
+		Elapsed:    time.Since(tr.start),
+		Err:        err,
+		Problem:    decodeProblem(resp, body),
+		readerOpts: readerOpts,
+	}
+}
+
+func (hc *Client) do(request *http.Request) (*http.Response, error) {
 	// return ctxhttp.Do(request.Context(), hc.client, request)
-	return hc.client.Do(request)
+	if hc.retryPolicy == nil {
+		return hc.send(request)
+	}
+	return hc.doRetry(request)
+}
+
+// send sends a single attempt of req through the bulkhead and the circuit breaker of its host.
+func (hc *Client) send(req *http.Request) (*http.Response, error) {
+	if tr, ok := req.Context().Value(requestTraceKey{}).(*requestTrace); ok {
+		tr.attempts++
+	}
+
+	send := hc.client.Do
+	if hc.breakers != nil {
+		next := send
+		send = func(r *http.Request) (*http.Response, error) {
+			return hc.breakers.do(r, next)
+		}
+	}
+	if hc.bulkheads != nil {
+		return hc.bulkheads.do(req, send)
+	}
+	return send(req)
+}
+
+// CircuitState returns the state of the circuit breaker of host. It is CircuitClosed if no breaker is set.
+func (hc *Client) CircuitState(host string) CircuitState {
+	if hc.breakers == nil {
+		return CircuitClosed
+	}
+	return hc.breakers.State(host)
+}
+
+func (hc *Client) doRetry(request *http.Request) (*http.Response, error) {
+	start := time.Now()
+	req := request
+	for attempt := 1; ; attempt++ {
+		resp, err := hc.send(req)
+		if request.Context().Err() != nil {
+			return resp, err
+		}
+
+		wait, retry := hc.retryPolicy.Retry(req, resp, err, attempt, time.Since(start))
+		if !retry {
+			return resp, err
+		}
+		next, ok := rewindRequest(request)
+		if !ok {
+			return resp, err
+		}
+
+		drainBody(resp)
+		if err := sleepContext(request.Context(), wait); err != nil {
+			return nil, err
+		}
+		req = next
+	}
 }
 
 // DoRead sends Do request and read all data from response.Body
 func (hc *Client) DoRead(request *http.Request) ([]byte, error) {
-	resp, err := hc.Do(request)
+	resp, tr, err := hc.doTrace(request)
 	if err != nil {
 		return nil, err
 	}
@@ -64,46 +177,38 @@ func (hc *Client) DoRead(request *http.Request) ([]byte, error) {
 	b, err := r.ReadAll()
 	resp.Body.Close()
 	if err != nil {
-		return nil, &Error{
-			Response: resp,
-			Body:     b,
-		}
+		return nil, tr.error(resp, b, err, hc.readerOpts)
 	}
 	if code := resp.StatusCode; code < 100 || code > 399 {
-		return nil, &Error{
-			Response: resp,
-			Body:     b,
-		}
+		return nil, tr.error(resp, b, nil, hc.readerOpts)
 	}
 	return b, nil
 }
 
 // DoDecode sends Do request and decode response.Body
 func (hc *Client) DoDecode(request *http.Request, res any) error {
-	resp, err := hc.Do(request)
+	resp, tr, err := hc.doTrace(request)
 	if err != nil {
 		return err
 	}
 
 	bb := sio.GetBytesBuffer(nil)
-	tr := io.TeeReader(resp.Body, bb)
-	r := sio.GetReader(tr, hc.readerOpts...)
+	tee := io.TeeReader(resp.Body, bb)
+	r := sio.GetReader(tee, hc.readerOpts...)
 	defer sio.PutReader(r)
 
 	err = r.Decode(res)
-	resp.Body.Close()
 	if err != nil {
-		return &Error{
-			Response: resp,
-			Body:     bb.Bytes(),
-		}
+		// keep the rest of the body for Error
+		_, _ = io.Copy(io.Discard, tee)
 	}
+	resp.Body.Close()
 
 	if code := resp.StatusCode; code < 100 || code > 399 {
-		return &Error{
-			Response: resp,
-			Body:     bb.Bytes(),
-		}
+		return tr.error(resp, bb.Bytes(), nil, hc.readerOpts)
+	}
+	if err != nil {
+		return tr.error(resp, bb.Bytes(), err, hc.readerOpts)
 	}
 
 	return nil
@@ -118,132 +223,56 @@ func (hc *Client) Request(method string, url string, header http.Header, queries
 func (hc *Client) RequestContext(ctx context.Context, method string, url string, header http.Header,
 	queries map[string]string, body []byte, opts ...RequestOption) ([]byte, error) {
 
-	var res []byte
-	var err error
-	for i := 0; i <= hc.retryAttempts; i++ {
-		res, err = hc.request(ctx, method, hc.baseUrl+url, header, queries, body, opts...)
-		if err != nil && hc.isRetryError(err) {
-			continue
-		} else {
-			break
-		}
-	}
-	return res, err
+	return hc.request(ctx, method, hc.baseUrl+url, header, queries, body, opts...)
 }
 func (hc *Client) RequestDecode(method string, url string, header http.Header, queries map[string]string,
 	body any, res any, opts ...RequestOption) error {
 
-	return hc.RequestDecodeContext(context.Background(), http.MethodPost, url, header, queries, body, res, opts...)
+	return hc.RequestDecodeContext(context.Background(), method, url, header, queries, body, res, opts...)
 }
 func (hc *Client) RequestDecodeContext(ctx context.Context, method string, url string, header http.Header,
 	queries map[string]string, body any, res any, opts ...RequestOption) error {
 
-	var err error
-	for i := 0; i <= hc.retryAttempts; i++ {
-		err = hc.requestDecode(ctx, method, hc.baseUrl+url, header, queries, body, res, opts...)
-		if err != nil && hc.isRetryError(err) {
-			continue
-		} else {
-			break
-		}
-	}
-	return err
+	return hc.requestDecode(ctx, method, hc.baseUrl+url, header, queries, body, res, opts...)
 }
 
 func (hc *Client) Get(url string, header http.Header, queries map[string]string, opts ...RequestOption) ([]byte, error) {
 	return hc.GetContext(context.Background(), url, header, queries, opts...)
 }
 func (hc *Client) GetContext(ctx context.Context, url string, header http.Header, queries map[string]string, opts ...RequestOption) ([]byte, error) {
-	var res []byte
-	var err error
-	for i := 0; i <= hc.retryAttempts; i++ {
-		res, err = hc.request(ctx, http.MethodGet, hc.baseUrl+url, header, queries, nil, opts...)
-		if err != nil && hc.isRetryError(err) {
-			continue
-		} else {
-			break
-		}
-	}
-	return res, err
+	return hc.request(ctx, http.MethodGet, hc.baseUrl+url, header, queries, nil, opts...)
 }
 func (hc *Client) GetDecode(url string, header http.Header, queries map[string]string, res any, opts ...RequestOption) error {
 	return hc.GetDecodeContext(context.Background(), url, header, queries, res, opts...)
 }
 func (hc *Client) GetDecodeContext(ctx context.Context, url string, header http.Header, queries map[string]string, res any, opts ...RequestOption) error {
-	var err error
-	for i := 0; i <= hc.retryAttempts; i++ {
-		err = hc.requestDecode(ctx, http.MethodGet, hc.baseUrl+url, header, queries, nil, res, opts...)
-		if err != nil && hc.isRetryError(err) {
-			continue
-		} else {
-			break
-		}
-	}
-	return err
+	return hc.requestDecode(ctx, http.MethodGet, hc.baseUrl+url, header, queries, nil, res, opts...)
 }
 
 func (hc *Client) Post(url string, header http.Header, body any, opts ...RequestOption) ([]byte, error) {
 	return hc.PostContext(context.Background(), url, header, body, opts...)
 }
 func (hc *Client) PostContext(ctx context.Context, url string, header http.Header, body any, opts ...RequestOption) ([]byte, error) {
-	var res []byte
-	var err error
-	for i := 0; i <= hc.retryAttempts; i++ {
-		res, err = hc.request(ctx, http.MethodPost, hc.baseUrl+url, header, nil, body, opts...)
-		if err != nil && hc.isRetryError(err) {
-			continue
-		} else {
-			break
-		}
-	}
-	return res, err
+	return hc.request(ctx, http.MethodPost, hc.baseUrl+url, header, nil, body, opts...)
 }
 func (hc *Client) PostDecode(url string, header http.Header, body any, res any, opts ...RequestOption) error {
 	return hc.PostDecodeContext(context.Background(), url, header, body, res, opts...)
 }
 func (hc *Client) PostDecodeContext(ctx context.Context, url string, header http.Header, body any, res any, opts ...RequestOption) error {
-	var err error
-	for i := 0; i <= hc.retryAttempts; i++ {
-		err = hc.requestDecode(ctx, http.MethodPost, hc.baseUrl+url, header, nil, body, res, opts...)
-		if err != nil && hc.isRetryError(err) {
-			continue
-		} else {
-			break
-		}
-	}
-	return err
+	return hc.requestDecode(ctx, http.MethodPost, hc.baseUrl+url, header, nil, body, res, opts...)
 }
 
 func (hc *Client) Put(url string, header http.Header, body any, opts ...RequestOption) ([]byte, error) {
 	return hc.PutContext(context.Background(), url, header, body, opts...)
 }
 func (hc *Client) PutContext(ctx context.Context, url string, header http.Header, body any, opts ...RequestOption) ([]byte, error) {
-	var res []byte
-	var err error
-	for i := 0; i <= hc.retryAttempts; i++ {
-		res, err = hc.request(ctx, http.MethodPut, hc.baseUrl+url, header, nil, body, opts...)
-		if err != nil && hc.isRetryError(err) {
-			continue
-		} else {
-			break
-		}
-	}
-	return res, err
+	return hc.request(ctx, http.MethodPut, hc.baseUrl+url, header, nil, body, opts...)
 }
 func (hc *Client) PutDecode(url string, header http.Header, body any, res any, opts ...RequestOption) error {
 	return hc.PutDecodeContext(context.Background(), url, header, body, res, opts...)
 }
 func (hc *Client) PutDecodeContext(ctx context.Context, url string, header http.Header, body any, res any, opts ...RequestOption) error {
-	var err error
-	for i := 0; i <= hc.retryAttempts; i++ {
-		err = hc.requestDecode(ctx, http.MethodPut, hc.baseUrl+url, header, nil, body, res, opts...)
-		if err != nil && hc.isRetryError(err) {
-			continue
-		} else {
-			break
-		}
-	}
-	return err
+	return hc.requestDecode(ctx, http.MethodPut, hc.baseUrl+url, header, nil, body, res, opts...)
 }
 
 func (hc *Client) Patch(url string, header http.Header, body any, opts ...RequestOption) ([]byte, error) {
@@ -251,32 +280,13 @@ func (hc *Client) Patch(url string, header http.Header, body any, opts ...Reques
 }
 
 func (hc *Client) PatchContext(ctx context.Context, url string, header http.Header, body any, opts ...RequestOption) ([]byte, error) {
-	var res []byte
-	var err error
-	for i := 0; i <= hc.retryAttempts; i++ {
-		res, err = hc.request(ctx, http.MethodPatch, hc.baseUrl+url, header, nil, body, opts...)
-		if err != nil && hc.isRetryError(err) {
-			continue
-		} else {
-			break
-		}
-	}
-	return res, err
+	return hc.request(ctx, http.MethodPatch, hc.baseUrl+url, header, nil, body, opts...)
 }
 func (hc *Client) PatchDecode(url string, header http.Header, body any, res any, opts ...RequestOption) error {
 	return hc.PatchDecodeContext(context.Background(), url, header, body, res, opts...)
 }
 func (hc *Client) PatchDecodeContext(ctx context.Context, url string, header http.Header, body any, res any, opts ...RequestOption) error {
-	var err error
-	for i := 0; i <= hc.retryAttempts; i++ {
-		err = hc.requestDecode(ctx, http.MethodPatch, hc.baseUrl+url, header, nil, body, res, opts...)
-		if err != nil && hc.isRetryError(err) {
-			continue
-		} else {
-			break
-		}
-	}
-	return err
+	return hc.requestDecode(ctx, http.MethodPatch, hc.baseUrl+url, header, nil, body, res, opts...)
 }
 
 func (hc *Client) Delete(url string, header http.Header, queries map[string]string,
@@ -287,17 +297,7 @@ func (hc *Client) Delete(url string, header http.Header, queries map[string]stri
 func (hc *Client) DeleteContext(ctx context.Context, url string, header http.Header, queries map[string]string,
 	opts ...RequestOption) ([]byte, error) {
 
-	var res []byte
-	var err error
-	for i := 0; i <= hc.retryAttempts; i++ {
-		res, err = hc.request(ctx, http.MethodDelete, hc.baseUrl+url, header, queries, nil, opts...)
-		if err != nil && hc.isRetryError(err) {
-			continue
-		} else {
-			break
-		}
-	}
-	return res, err
+	return hc.request(ctx, http.MethodDelete, hc.baseUrl+url, header, queries, nil, opts...)
 }
 func (hc *Client) DeleteDecode(url string, header http.Header, queries map[string]string,
 	res any, opts ...RequestOption) error {
@@ -307,16 +307,7 @@ func (hc *Client) DeleteDecode(url string, header http.Header, queries map[strin
 func (hc *Client) DeleteDecodeContext(ctx context.Context, url string, header http.Header, queries map[string]string,
 	res any, opts ...RequestOption) error {
 
-	var err error
-	for i := 0; i <= hc.retryAttempts; i++ {
-		err = hc.requestDecode(ctx, http.MethodDelete, hc.baseUrl+url, header, queries, nil, res, opts...)
-		if err != nil && hc.isRetryError(err) {
-			continue
-		} else {
-			break
-		}
-	}
-	return err
+	return hc.requestDecode(ctx, http.MethodDelete, hc.baseUrl+url, header, queries, nil, res, opts...)
 }
 
 func (hc *Client) PostFile(url string, header http.Header,
@@ -325,6 +316,8 @@ func (hc *Client) PostFile(url string, header http.Header,
 	return hc.PostFileContext(context.Background(), url, header, params, formKeyName, fileName)
 }
 
+// PostFileContext sends the file of fileName in the form field formKeyName, followed by params, as a
+// multipart/form-data body. It is streamed as PostMultipartContext does.
 func (hc *Client) PostFileContext(ctx context.Context, url string, header http.Header,
 	params map[string]string, formKeyName, fileName string) ([]byte, error) {
 
@@ -333,41 +326,14 @@ func (hc *Client) PostFileContext(ctx context.Context, url string, header http.H
 	if err != nil {
 		return nil, err
 	}
-	defer f.Close()
 
-	// create multipart.Writer
-	buf := bytes.NewBuffer(make([]byte, 0, 512))
-	mw := multipart.NewWriter(buf)
-	w, err := mw.CreateFormFile(formKeyName, f.Name())
-	if err != nil {
-		return nil, err
-	}
-
-	// write file contents
-	sr := sio.GetReader(f)
-	defer sio.PutReader(sr)
-	_, err = sr.WriteTo(w)
-	if err != nil {
-		return nil, err
-	}
-
-	// set Content-Type, overwrite existing Content-Type
-	if header == nil {
-		header = make(http.Header)
-	}
-	header["Content-Type"] = []string{mw.FormDataContentType()}
-
-	// write params, this closes multipart.Writer
+	// the file is closed after it is written
+	parts := make([]FormPart, 0, len(params)+1)
+	parts = append(parts, FormFile(formKeyName, f.Name(), "", f))
 	for k, v := range params {
-		mw.WriteField(k, v)
+		parts = append(parts, FormField(k, v))
 	}
-
-	// close multipart writer
-	if err = mw.Close(); err != nil {
-		return nil, err
-	}
-
-	return hc.request(ctx, http.MethodPost, hc.baseUrl+url, header, nil, buf)
+	return hc.PostMultipartContext(ctx, url, header, parts)
 }
 
 // setDefaultHeader sets defaultHeaders to request. It doesn't replace headers that are already assigned to `request`
@@ -379,6 +345,10 @@ func (hc *Client) setDefaultHeader(request *http.Request) {
 	}
 }
 
+func (hc *Client) appendMiddleware(mw ClientMiddleware) {
+	hc.middlewares = append(hc.middlewares, mw)
+}
+
 func (hc *Client) appendRequestOption(opt RequestOption) {
 	hc.requestOpts = append(hc.requestOpts, opt)
 }
@@ -427,12 +397,11 @@ func (hc *Client) request(ctx context.Context, method string, url string,
 	setHeader(req, header)
 	setQueries(req, queries)
 
-	for _, v := range hc.requestOpts {
-		v.apply(req)
+	if err := ApplyRequestOptions(req, hc.requestOpts...); err != nil {
+		return nil, err
 	}
-
-	for _, v := range opts {
-		v.apply(req)
+	if err := ApplyRequestOptions(req, opts...); err != nil {
+		return nil, err
 	}
 
 	respBody, err := hc.DoRead(req)
@@ -480,12 +449,11 @@ func (hc *Client) requestDecode(ctx context.Context, method string, url string,
 	setHeader(req, header)
 	setQueries(req, queries)
 
-	for _, v := range hc.requestOpts {
-		v.apply(req)
+	if err := ApplyRequestOptions(req, hc.requestOpts...); err != nil {
+		return err
 	}
-
-	for _, v := range opts {
-		v.apply(req)
+	if err := ApplyRequestOptions(req, opts...); err != nil {
+		return err
 	}
 
 	err = hc.DoDecode(req, res)
@@ -518,18 +486,3 @@ func setQueries(req *http.Request, queries map[string]string) {
 		req.URL.RawQuery = q.Encode()
 	}
 }
-
-func (hc *Client) isRetryError(err error) bool {
-	if err == nil {
-		return false
-	}
-	switch t := err.(type) {
-	case *Error:
-		status := t.GetStatusCode(http.StatusInternalServerError)
-		if status == http.StatusUnauthorized {
-			time.Sleep(hc.retryDelay)
-			return true
-		}
-	}
-	return false
-}
@@ -157,6 +157,7 @@ func Test_Client_RequestDecode(t *testing.T) {
 	expected := []byte(`{"msg":"hello there"}`)
 
 	svr := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.EqualValues(t, http.MethodGet, r.Method)
 		w.Write(expected)
 	}))
 	defer svr.Close()
@@ -546,6 +547,38 @@ func Test_Client_PostFile(t *testing.T) {
 	assert.EqualValues(t, expected, res)
 }
 
+func Test_Client_PostFile_Streamed(t *testing.T) {
+	svr := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		file, header, err := r.FormFile("file_to_upload")
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		defer file.Close()
+		b, _ := io.ReadAll(file)
+		w.Write([]byte(header.Filename + " " + r.FormValue("name") + " " + string(b)))
+	}))
+	defer svr.Close()
+
+	var buffered bool
+	c := NewClient(_newStandardClient(), WithMiddleware(func(next Doer) Doer {
+		return DoerFunc(func(req *http.Request) (*http.Response, error) {
+			buffered = req.GetBody != nil
+			return next.Do(req)
+		})
+	}))
+
+	path := filepath.Join(t.TempDir(), "upload.txt")
+	require.Nil(t, os.WriteFile(path, []byte("content"), 0600))
+	res, err := c.PostFile(svr.URL, nil, map[string]string{"name": "wonk"}, "file_to_upload", path)
+	require.Nil(t, err)
+	assert.EqualValues(t, "upload.txt wonk content", string(res))
+	assert.False(t, buffered, "the body must be streamed")
+
+	_, err = c.PostFile(svr.URL, nil, nil, "file_to_upload", filepath.Join(t.TempDir(), "unknown"))
+	assert.ErrorIs(t, err, os.ErrNotExist)
+}
+
 func _newStandardClient() *http.Client {
 	tlsConfig := &tls.Config{
 		InsecureSkipVerify: true,
@@ -679,17 +712,25 @@ func Test_setQueries(t *testing.T) {
 	assert.EqualValues(t, "hello there", req.URL.Query().Get("msg"))
 }
 
-func Test_Client_isRetryError(t *testing.T) {
-	c := NewClient(nil)
+func Test_Client_retryPolicy(t *testing.T) {
+	c := NewClient(nil, WithRetryAttempts(1))
+	req, _ := http.NewRequest(http.MethodGet, "/", nil)
+	retry := func(resp *http.Response, err error) bool {
+		_, ok := c.retryPolicy.Retry(req, resp, err, 1, 0)
+		return ok
+	}
 
-	assert.EqualValues(t, false, c.isRetryError(nil))
+	assert.EqualValues(t, false, retry(&http.Response{StatusCode: http.StatusOK}, nil))
+	assert.EqualValues(t, false, retry(&http.Response{StatusCode: http.StatusUnauthorized}, nil))
+	assert.EqualValues(t, true, retry(&http.Response{StatusCode: http.StatusServiceUnavailable, Header: http.Header{}}, nil))
+	assert.EqualValues(t, false, retry(nil, errors.New("just error")))
 
-	e := &Error{
-		Response: &http.Response{
-			StatusCode: http.StatusUnauthorized,
-		},
+	// retrying 401 as before is a matter of the classifier
+	p := NewBackoffRetryPolicy(1)
+	p.Classifier = func(resp *http.Response, err error) bool {
+		return resp != nil && resp.StatusCode == http.StatusUnauthorized
 	}
-	assert.EqualValues(t, true, c.isRetryError(e))
-
-	assert.EqualValues(t, false, c.isRetryError(errors.New("just error")))
+	c = NewClient(nil, WithRetryPolicy(p))
+	assert.EqualValues(t, true, retry(&http.Response{StatusCode: http.StatusUnauthorized}, nil))
+	assert.EqualValues(t, false, retry(nil, errors.New("just error")))
 }
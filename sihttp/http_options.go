@@ -2,10 +2,14 @@ package sihttp
 
 import (
 	"encoding/base64"
+	"io"
 	"net/http"
+	"strconv"
 	"strings"
+	"time"
 
 	"github.com/wonksing/si/v2/codec"
+	"github.com/wonksing/si/v2/sihttp/middleware"
 	"github.com/wonksing/si/v2/sio"
 	"golang.org/x/oauth2"
 )
@@ -20,6 +24,20 @@ func (o RequestOptionFunc) apply(c *http.Request) error {
 	return o(c)
 }
 
+// ApplyRequestOptions applies opts to req, for requests sent by other than Client, such as a reverse proxy.
+// It returns the first error of opts.
+func ApplyRequestOptions(req *http.Request, opts ...RequestOption) error {
+	for _, o := range opts {
+		if o == nil {
+			continue
+		}
+		if err := o.apply(req); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 func WithHeaderSet(key string, value string) RequestOptionFunc {
 	return RequestOptionFunc(func(req *http.Request) error {
 		header := req.Header
@@ -72,7 +90,13 @@ func WithTokenSource(tokenSource oauth2.TokenSource) RequestOptionFunc {
 	})
 }
 
-func WithHeaderHmac256(key string, secret []byte) RequestOptionFunc {
+// WithHeaderHmac256 sets header key to the hex encoded HMAC-SHA256 of the request body with secret.
+// It is skipped if the header is already set, the body is multipart, or the body can't be read again.
+// With opts, a timestamp and a nonce are signed along with the body, and requests without body are signed too.
+// It fails with ErrBodyNotRewindable then instead of skipping a body that can't be read again, such as the one
+// of PostMultipart. See VerifyHeaderHmac256 for the server side.
+func WithHeaderHmac256(key string, secret []byte, opts ...HmacOption) RequestOptionFunc {
+	conf := newHmacConfig(opts)
 	return RequestOptionFunc(func(req *http.Request) error {
 		header := req.Header
 		if _, ok := header[key]; ok {
@@ -80,32 +104,83 @@ func WithHeaderHmac256(key string, secret []byte) RequestOptionFunc {
 			return nil
 		}
 
-		contentType := header.Get("Content-Type")
-		if strings.Contains(contentType, "multipart/form-data") {
-			// skip
-			return nil
-		}
+		if conf.timestampHeader == "" && conf.nonceHeader == "" {
+			contentType := header.Get("Content-Type")
+			if strings.Contains(contentType, "multipart/form-data") {
+				// skip
+				return nil
+			}
+			if req.GetBody == nil {
+				// skip
+				return nil
+			}
+
+			r, err := req.GetBody()
+			if err != nil {
+				return err
+			}
+
+			hashed, err := codec.HmacSha256HexEncodedWithReader(string(secret), r)
+			if err != nil {
+				return err
+			}
+			header[key] = []string{hashed}
 
-		if req.GetBody == nil {
-			// skip
 			return nil
 		}
 
-		r, err := req.GetBody()
-		if err != nil {
-			return err
+		var body []byte
+		if req.GetBody != nil {
+			r, err := req.GetBody()
+			if err != nil {
+				return err
+			}
+			body, err = io.ReadAll(r)
+			r.Close()
+			if err != nil {
+				return err
+			}
+		} else if req.Body != nil && req.Body != http.NoBody {
+			// servers verifying the timestamp or the nonce reject unsigned requests
+			return ErrBodyNotRewindable
 		}
 
-		hashed, err := codec.HmacSha256HexEncodedWithReader(string(secret), r)
-		if err != nil {
-			return err
+		var timestamp, nonce string
+		if conf.timestampHeader != "" {
+			timestamp = strconv.FormatInt(time.Now().Unix(), 10)
+			header.Set(conf.timestampHeader, timestamp)
 		}
-		header[key] = []string{hashed}
+		if conf.nonceHeader != "" {
+			var err error
+			if nonce, err = newNonce(); err != nil {
+				return err
+			}
+			header.Set(conf.nonceHeader, nonce)
+		}
+		header[key] = []string{hmacSha256Hex(secret, timestamp, nonce, body)}
 
 		return nil
 	})
 }
 
+// WithRequestID sets the request id in the context of the request to header, which is middleware.RequestIDHeader
+// if empty, propagating the id from middleware.RequestID of the server handling the request.
+func WithRequestID(header string) RequestOptionFunc {
+	if header == "" {
+		header = middleware.RequestIDHeader
+	}
+	return RequestOptionFunc(func(req *http.Request) error {
+		if req.Header.Get(header) != "" {
+			// skip
+			return nil
+		}
+		if id := middleware.RequestIDFromContext(req.Context()); id != "" {
+			req.Header.Set(header, id)
+		}
+		return nil
+	})
+}
+
 type ClientOption interface {
 	apply(c *Client) error
 }
@@ -152,16 +227,77 @@ func WithRequestOpt(opt RequestOption) ClientOptionFunc {
 	})
 }
 
-func WithRequestHeaderHmac256(key string, secret []byte) ClientOptionFunc {
+// WithRequestHeaderHmac256 signs every request of Client with WithHeaderHmac256.
+func WithRequestHeaderHmac256(key string, secret []byte, opts ...HmacOption) ClientOptionFunc {
 	return ClientOptionFunc(func(c *Client) error {
-		c.appendRequestOption(WithHeaderHmac256(key, secret))
+		c.appendRequestOption(WithHeaderHmac256(key, secret, opts...))
 		return nil
 	})
 }
 
+// WithRetryAttempts sets Client to retry up to attempts times with NewBackoffRetryPolicy.
 func WithRetryAttempts(attempts int) ClientOptionFunc {
 	return ClientOptionFunc(func(c *Client) error {
-		c.retryAttempts = attempts
+		if attempts <= 0 {
+			c.retryPolicy = nil
+			return nil
+		}
+		c.retryPolicy = NewBackoffRetryPolicy(attempts)
+		return nil
+	})
+}
+
+// WithRetryPolicy sets policy to Client. Requests whose body cannot be rebuilt with GetBody are not retried.
+func WithRetryPolicy(policy RetryPolicy) ClientOptionFunc {
+	return ClientOptionFunc(func(c *Client) error {
+		c.retryPolicy = policy
+		return nil
+	})
+}
+
+// WithCircuitBreaker sets Client with circuit breakers per host configured by conf.
+// Requests to a host whose breaker is open fail with ErrCircuitOpen.
+func WithCircuitBreaker(conf CircuitBreakerConfig) ClientOptionFunc {
+	return ClientOptionFunc(func(c *Client) error {
+		c.breakers = newCircuitBreakers(conf)
+		return nil
+	})
+}
+
+// WithBulkhead limits concurrent requests per host to maxConcurrent. A request waits up to maxWait for a slot,
+// then fails with ErrBulkheadFull. A slot is held until the response body is closed.
+func WithBulkhead(maxConcurrent int, maxWait time.Duration) ClientOptionFunc {
+	return ClientOptionFunc(func(c *Client) error {
+		if maxConcurrent <= 0 {
+			c.bulkheads = nil
+			return nil
+		}
+		c.bulkheads = newBulkheads(maxConcurrent, maxWait)
+		return nil
+	})
+}
+
+// WithMiddleware appends mw to Client. Middlewares wrap Client.Do in the order they are appended,
+// so the first one is the outermost.
+func WithMiddleware(mw ClientMiddleware) ClientOptionFunc {
+	return ClientOptionFunc(func(c *Client) error {
+		if mw != nil {
+			c.appendMiddleware(mw)
+		}
+		return nil
+	})
+}
+
+// WithCache caches responses of GET requests in store following their Cache-Control, Expires, ETag and Last-Modified
+// headers, and revalidates stale ones with conditional requests. Successful requests of unsafe methods invalidate
+// the response of their url. Responses to requests with Authorization are stored only if they are public.
+// Responses larger than WithCacheMaxEntryBytes are not stored. The cache wraps middlewares appended after it.
+func WithCache(store CacheStore, opts ...CacheOption) ClientOptionFunc {
+	return ClientOptionFunc(func(c *Client) error {
+		if store == nil {
+			return nil
+		}
+		c.appendMiddleware(newResponseCache(store, opts...).middleware)
 		return nil
 	})
 }
@@ -0,0 +1,484 @@
+package sihttp
+
+import (
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/base64"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/wonksing/si/v2/codec/sign"
+)
+
+const defaultSignatureLabel = "sig1"
+
+var (
+	errMissingSignature   = errors.New("missing signature")
+	errMalformedSignature = errors.New("malformed signature")
+	errMissingComponent   = errors.New("missing component")
+)
+
+// MessageSignatureOption configures WithMessageSignature and VerifyMessageSignature.
+type MessageSignatureOption interface {
+	apply(c *messageConfig)
+}
+
+// MessageSignatureOptionFunc wraps a function to conforms to MessageSignatureOption interface.
+type MessageSignatureOptionFunc func(c *messageConfig)
+
+func (o MessageSignatureOptionFunc) apply(c *messageConfig) {
+	o(c)
+}
+
+type messageConfig struct {
+	label              string
+	alg                string
+	components         []string
+	expires            time.Duration
+	requiredComponents []string
+	unsignedBody       bool
+	maxAge             time.Duration
+	maxBodyBytes       int64
+}
+
+func newMessageConfig(opts []MessageSignatureOption) messageConfig {
+	c := messageConfig{
+		requiredComponents: []string{"@method", "@authority", "@path"},
+		maxAge:             defaultSignatureMaxAge,
+		maxBodyBytes:       DefaultMaxVerifyBodyBytes,
+	}
+	for _, o := range opts {
+		if o == nil {
+			continue
+		}
+		o.apply(&c)
+	}
+	return c
+}
+
+// WithMessageLabel names the signature in Signature-Input and Signature headers, which is "sig1" by default.
+// VerifyMessageSignature verifies the signature of label, or the first one in Signature-Input if not set.
+func WithMessageLabel(label string) MessageSignatureOptionFunc {
+	return MessageSignatureOptionFunc(func(c *messageConfig) {
+		c.label = label
+	})
+}
+
+// WithMessageAlg sets alg parameter of the signature, eg. "hmac-sha256" or "ed25519".
+func WithMessageAlg(alg string) MessageSignatureOptionFunc {
+	return MessageSignatureOptionFunc(func(c *messageConfig) {
+		c.alg = alg
+	})
+}
+
+// WithMessageComponents sets the covered components, which are derived components such as "@method" or
+// lower-cased header names. They are "@method", "@authority", "@path" and "@query" by default, with
+// "content-digest" for requests with body.
+func WithMessageComponents(components ...string) MessageSignatureOptionFunc {
+	return MessageSignatureOptionFunc(func(c *messageConfig) {
+		c.components = components
+	})
+}
+
+// WithMessageExpires sets expires parameter of the signature to its created time plus d.
+func WithMessageExpires(d time.Duration) MessageSignatureOptionFunc {
+	return MessageSignatureOptionFunc(func(c *messageConfig) {
+		c.expires = d
+	})
+}
+
+// WithMessageRequiredComponents sets the components VerifyMessageSignature requires the signature to cover,
+// which are "@method", "@authority" and "@path" by default. None are required if components is empty.
+func WithMessageRequiredComponents(components ...string) MessageSignatureOptionFunc {
+	return MessageSignatureOptionFunc(func(c *messageConfig) {
+		c.requiredComponents = components
+	})
+}
+
+// WithMessageUnsignedBody makes VerifyMessageSignature accept signatures not covering "content-digest" for
+// requests with body, whose bodies are then not verified. Such signatures are rejected by default.
+func WithMessageUnsignedBody() MessageSignatureOptionFunc {
+	return MessageSignatureOptionFunc(func(c *messageConfig) {
+		c.unsignedBody = true
+	})
+}
+
+// WithMessageMaxAge sets how old created parameter can be for VerifyMessageSignature, which is 5 minutes by default.
+func WithMessageMaxAge(d time.Duration) MessageSignatureOptionFunc {
+	return MessageSignatureOptionFunc(func(c *messageConfig) {
+		if d > 0 {
+			c.maxAge = d
+		}
+	})
+}
+
+// WithMessageMaxBodyBytes limits the size of bodies VerifyMessageSignature reads, which is DefaultMaxVerifyBodyBytes
+// by default.
+func WithMessageMaxBodyBytes(n int64) MessageSignatureOptionFunc {
+	return MessageSignatureOptionFunc(func(c *messageConfig) {
+		if n > 0 {
+			c.maxBodyBytes = n
+		}
+	})
+}
+
+// WithMessageSignature signs the request with signer following RFC 9421 HTTP Message Signatures.
+// keyId is set to keyid parameter if not empty.
+//
+// It sets Content-Digest header of RFC 9530 with sha-256 if "content-digest" is covered and the header is not set,
+// then sets Signature-Input and Signature headers, eg.
+//
+//	Signature-Input: sig1=("@method" "@authority" "@path" "@query");created=1618884473;keyid="key"
+//	Signature: sig1=:<base64>:
+//
+// Supported derived components are @method, @target-uri, @authority, @scheme, @request-target, @path and @query.
+func WithMessageSignature(keyId string, signer sign.Signer, opts ...MessageSignatureOption) RequestOptionFunc {
+	conf := newMessageConfig(opts)
+	return RequestOptionFunc(func(req *http.Request) error {
+		return signMessage(req, keyId, signer, conf, time.Now())
+	})
+}
+
+func signMessage(req *http.Request, keyId string, signer sign.Signer, conf messageConfig, now time.Time) error {
+	label := conf.label
+	if label == "" {
+		label = defaultSignatureLabel
+	}
+
+	body, err := getRequestBody(req)
+	if err != nil {
+		return err
+	}
+	components := conf.components
+	if components == nil {
+		components = []string{"@method", "@authority", "@path", "@query"}
+		if len(body) > 0 {
+			components = append(components, "content-digest")
+		}
+	}
+	if containsString(components, "content-digest") && req.Header.Get("Content-Digest") == "" {
+		req.Header.Set("Content-Digest", contentDigest(body))
+	}
+
+	params := signatureParams(components, now, keyId, conf)
+	base, err := signatureBase(req, components, params)
+	if err != nil {
+		return err
+	}
+	sig, err := signer.Sign([]byte(base))
+	if err != nil {
+		return err
+	}
+
+	req.Header.Set("Signature-Input", label+"="+params)
+	req.Header.Set("Signature", label+"=:"+base64.StdEncoding.EncodeToString(sig)+":")
+	return nil
+}
+
+// VerifyMessageSignature returns a middleware that verifies RFC 9421 signatures made by WithMessageSignature with
+// the verifier of keyid and alg parameters of the signature. Content-Digest header is verified against the body
+// when it is covered, which is required for requests with body unless WithMessageUnsignedBody is set. It responds with 401 when the signature is missing, invalid or expired, and 413 when
+// the body is too large. The body is restored for next handler.
+func VerifyMessageSignature(verifier func(keyId, alg string) (sign.Verifier, error), opts ...MessageSignatureOption) func(http.Handler) http.Handler {
+	conf := newMessageConfig(opts)
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			body, ok := readVerifiedBody(w, r, conf.maxBodyBytes)
+			if !ok {
+				return
+			}
+			if err := verifyMessage(r, body, verifier, conf, time.Now()); err != nil {
+				http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+func verifyMessage(r *http.Request, body []byte, verifier func(keyId, alg string) (sign.Verifier, error),
+	conf messageConfig, now time.Time) error {
+	inputs := parseDictionary(r.Header.Get("Signature-Input"))
+	sigs := parseDictionary(r.Header.Get("Signature"))
+	if len(inputs) == 0 {
+		return errMissingSignature
+	}
+
+	label := conf.label
+	if label == "" {
+		label = inputs[0].key
+	}
+	params, ok := dictionaryValue(inputs, label)
+	if !ok {
+		return errMissingSignature
+	}
+	sigValue, ok := dictionaryValue(sigs, label)
+	if !ok || len(sigValue) < 2 || sigValue[0] != ':' || sigValue[len(sigValue)-1] != ':' {
+		return errMissingSignature
+	}
+	sig, err := base64.StdEncoding.DecodeString(sigValue[1 : len(sigValue)-1])
+	if err != nil {
+		return errMalformedSignature
+	}
+
+	components, p, err := parseSignatureParams(params)
+	if err != nil {
+		return err
+	}
+	for _, c := range conf.requiredComponents {
+		if !containsString(components, c) {
+			return errUnsignedComponent
+		}
+	}
+	if len(body) > 0 && !conf.unsignedBody && !containsString(components, "content-digest") {
+		return errUnsignedComponent
+	}
+
+	created, err := strconv.ParseInt(p["created"], 10, 64)
+	if err != nil {
+		return errMalformedSignature
+	}
+	if d := now.Sub(time.Unix(created, 0)); d > conf.maxAge || d < -conf.maxAge {
+		return errSignatureExpired
+	}
+	if v, ok := p["expires"]; ok {
+		expires, err := strconv.ParseInt(v, 10, 64)
+		if err != nil {
+			return errMalformedSignature
+		}
+		if !now.Before(time.Unix(expires, 0)) {
+			return errSignatureExpired
+		}
+	}
+
+	if containsString(components, "content-digest") {
+		if err := verifyContentDigest(r.Header.Get("Content-Digest"), body); err != nil {
+			return err
+		}
+	}
+
+	base, err := signatureBase(r, components, params)
+	if err != nil {
+		return err
+	}
+	v, err := verifier(p["keyid"], p["alg"])
+	if err != nil {
+		return err
+	}
+	return v.Verify([]byte(base), sig)
+}
+
+func signatureParams(components []string, now time.Time, keyId string, conf messageConfig) string {
+	b := strings.Builder{}
+	b.WriteByte('(')
+	for i, c := range components {
+		if i > 0 {
+			b.WriteByte(' ')
+		}
+		b.WriteString(strconv.Quote(c))
+	}
+	b.WriteString(");created=")
+	b.WriteString(strconv.FormatInt(now.Unix(), 10))
+	if conf.expires > 0 {
+		b.WriteString(";expires=")
+		b.WriteString(strconv.FormatInt(now.Add(conf.expires).Unix(), 10))
+	}
+	if keyId != "" {
+		b.WriteString(";keyid=")
+		b.WriteString(strconv.Quote(keyId))
+	}
+	if conf.alg != "" {
+		b.WriteString(";alg=")
+		b.WriteString(strconv.Quote(conf.alg))
+	}
+	return b.String()
+}
+
+// signatureBase returns the signature base of RFC 9421 section 2.5, which ends with the signature params line.
+func signatureBase(r *http.Request, components []string, params string) (string, error) {
+	b := strings.Builder{}
+	for _, c := range components {
+		v, err := componentValue(r, c)
+		if err != nil {
+			return "", err
+		}
+		b.WriteString(strconv.Quote(c))
+		b.WriteString(": ")
+		b.WriteString(v)
+		b.WriteByte('\n')
+	}
+	b.WriteString(`"@signature-params": `)
+	b.WriteString(params)
+	return b.String(), nil
+}
+
+// componentValue returns the value of a component of an outgoing or incoming request.
+func componentValue(r *http.Request, name string) (string, error) {
+	switch name {
+	case "@method":
+		return r.Method, nil
+	case "@authority":
+		return strings.ToLower(requestHost(r)), nil
+	case "@scheme":
+		return requestScheme(r), nil
+	case "@target-uri":
+		return requestScheme(r) + "://" + strings.ToLower(requestHost(r)) + r.URL.RequestURI(), nil
+	case "@request-target":
+		return r.URL.RequestURI(), nil
+	case "@path":
+		if p := r.URL.EscapedPath(); p != "" {
+			return p, nil
+		}
+		return "/", nil
+	case "@query":
+		return "?" + r.URL.RawQuery, nil
+	}
+	if strings.HasPrefix(name, "@") {
+		return "", errMalformedSignature
+	}
+
+	values := r.Header.Values(name)
+	if len(values) == 0 {
+		return "", errMissingComponent
+	}
+	b := strings.Builder{}
+	for i, v := range values {
+		if i > 0 {
+			b.WriteString(", ")
+		}
+		b.WriteString(strings.TrimSpace(v))
+	}
+	return b.String(), nil
+}
+
+func requestScheme(r *http.Request) string {
+	if r.URL.Scheme != "" {
+		return strings.ToLower(r.URL.Scheme)
+	}
+	if r.TLS != nil {
+		return "https"
+	}
+	return "http"
+}
+
+func contentDigest(body []byte) string {
+	h := sha256.Sum256(body)
+	return "sha-256=:" + base64.StdEncoding.EncodeToString(h[:]) + ":"
+}
+
+// verifyContentDigest verifies sha-256 or sha-512 digests of Content-Digest header against body.
+func verifyContentDigest(header string, body []byte) error {
+	verified := false
+	for _, m := range parseDictionary(header) {
+		if len(m.value) < 2 || m.value[0] != ':' || m.value[len(m.value)-1] != ':' {
+			return errDigestMismatch
+		}
+		var digest []byte
+		switch m.key {
+		case "sha-256":
+			h := sha256.Sum256(body)
+			digest = h[:]
+		case "sha-512":
+			h := sha512.Sum512(body)
+			digest = h[:]
+		default:
+			continue
+		}
+		if !sign.EqualString(base64.StdEncoding.EncodeToString(digest), m.value[1:len(m.value)-1]) {
+			return errDigestMismatch
+		}
+		verified = true
+	}
+	if !verified {
+		return errDigestMismatch
+	}
+	return nil
+}
+
+type dictionaryMember struct {
+	key   string
+	value string
+}
+
+// parseDictionary splits a structured field dictionary into raw members, respecting quotes and inner lists.
+func parseDictionary(v string) []dictionaryMember {
+	var members []dictionaryMember
+	start, depth, quoted := 0, 0, false
+	add := func(s string) {
+		s = strings.TrimSpace(s)
+		if s == "" {
+			return
+		}
+		k, val, _ := strings.Cut(s, "=")
+		members = append(members, dictionaryMember{key: strings.TrimSpace(k), value: strings.TrimSpace(val)})
+	}
+	for i := 0; i < len(v); i++ {
+		switch c := v[i]; {
+		case quoted && c == '\\':
+			i++
+		case c == '"':
+			quoted = !quoted
+		case quoted:
+		case c == '(':
+			depth++
+		case c == ')':
+			depth--
+		case c == ',' && depth == 0:
+			add(v[start:i])
+			start = i + 1
+		}
+	}
+	add(v[start:])
+	return members
+}
+
+func dictionaryValue(members []dictionaryMember, key string) (string, bool) {
+	for _, m := range members {
+		if m.key == key {
+			return m.value, true
+		}
+	}
+	return "", false
+}
+
+// parseSignatureParams parses an inner list of component names followed by parameters,
+// eg. ("@method" "content-digest");created=1618884473;keyid="key".
+func parseSignatureParams(v string) ([]string, map[string]string, error) {
+	if !strings.HasPrefix(v, "(") {
+		return nil, nil, errMalformedSignature
+	}
+	end := strings.IndexByte(v, ')')
+	if end < 0 {
+		return nil, nil, errMalformedSignature
+	}
+
+	var components []string
+	for _, item := range strings.Fields(v[1:end]) {
+		c, err := strconv.Unquote(item)
+		if err != nil {
+			// component parameters such as ;sf or ;key are not supported
+			return nil, nil, errMalformedSignature
+		}
+		components = append(components, c)
+	}
+
+	params := make(map[string]string)
+	for _, p := range strings.Split(v[end+1:], ";") {
+		if p == "" {
+			continue
+		}
+		k, val, _ := strings.Cut(p, "=")
+		if strings.HasPrefix(val, `"`) {
+			unquoted, err := strconv.Unquote(val)
+			if err != nil {
+				return nil, nil, errMalformedSignature
+			}
+			val = unquoted
+		}
+		params[k] = val
+	}
+	return components, params, nil
+}
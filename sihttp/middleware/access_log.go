@@ -0,0 +1,39 @@
+package middleware
+
+import (
+	"log"
+	"net/http"
+	"time"
+)
+
+// AccessLog logs a line of each request after it is handled to logger, which is log.Default() if nil.
+//
+//	GET /books status=200 size=512 elapsed=1.2ms remote=10.0.0.1:53211 request_id=4d6f...
+func AccessLog(logger *log.Logger) Middleware {
+	if logger == nil {
+		logger = log.Default()
+	}
+	return Observe(func(r *http.Request, status int, size int64, elapsed time.Duration) {
+		logger.Printf("%s %s status=%d size=%d elapsed=%s remote=%s request_id=%s",
+			r.Method, r.URL.Path, status, size, elapsed, r.RemoteAddr, RequestIDFromContext(r.Context()))
+	})
+}
+
+// Observe calls fn with the status, the size of the body and the elapsed time of each request
+// after it is handled. status is 200 if the handler wrote nothing.
+func Observe(fn func(r *http.Request, status int, size int64, elapsed time.Duration)) Middleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			start := time.Now()
+			rw := &responseWriter{ResponseWriter: w}
+			defer func() {
+				status := rw.status
+				if status == 0 {
+					status = http.StatusOK
+				}
+				fn(r, status, rw.size, time.Since(start))
+			}()
+			next.ServeHTTP(rw, r)
+		})
+	}
+}
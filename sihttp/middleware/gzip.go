@@ -0,0 +1,160 @@
+package middleware
+
+import (
+	"compress/gzip"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+
+	"golang.org/x/net/http/httpguts"
+)
+
+// Gzip compresses responses with gzip of level for requests accepting it. level is gzip.DefaultCompression
+// if 0. Responses that already have Content-Encoding, and the ones without body such as 204, 304, responses
+// to HEAD and empty ones, are not compressed. Upgrade requests such as websocket handshakes are passed as
+// they are, so that handlers can hijack their connections.
+func Gzip(level int) Middleware {
+	if level == 0 || level < gzip.HuffmanOnly || level > gzip.BestCompression {
+		level = gzip.DefaultCompression
+	}
+	pool := &sync.Pool{
+		New: func() any {
+			gz, _ := gzip.NewWriterLevel(io.Discard, level)
+			return gz
+		},
+	}
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Add("Vary", "Accept-Encoding")
+			if !acceptsGzip(r.Header.Get("Accept-Encoding")) ||
+				httpguts.HeaderValuesContainsToken(r.Header["Connection"], "upgrade") {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			gw := &gzipResponseWriter{ResponseWriter: w, pool: pool, head: r.Method == http.MethodHead}
+			defer gw.close()
+			next.ServeHTTP(gw, r)
+		})
+	}
+}
+
+// acceptsGzip reports whether the Accept-Encoding header v accepts gzip.
+func acceptsGzip(v string) bool {
+	for _, coding := range strings.Split(v, ",") {
+		name, params, _ := strings.Cut(coding, ";")
+		if name = strings.TrimSpace(name); name != "gzip" && name != "*" {
+			continue
+		}
+		q, found := strings.CutPrefix(strings.TrimSpace(params), "q=")
+		if !found {
+			return true
+		}
+		f, err := strconv.ParseFloat(q, 64)
+		return err == nil && f > 0
+	}
+	return false
+}
+
+type gzipResponseWriter struct {
+	http.ResponseWriter
+	pool *sync.Pool
+	head bool
+
+	gz          *gzip.Writer
+	code        int
+	wroteHeader bool
+	sentHeader  bool
+	compress    bool
+}
+
+// WriteHeader holds the header of a response that may be compressed until its body is written, so that
+// Content-Encoding is set only if the body is not empty. 1xx responses are sent as they are.
+func (w *gzipResponseWriter) WriteHeader(code int) {
+	if w.wroteHeader {
+		return
+	}
+	if code >= 100 && code <= 199 && code != http.StatusSwitchingProtocols {
+		w.ResponseWriter.WriteHeader(code)
+		return
+	}
+	w.wroteHeader = true
+	w.code = code
+
+	w.compress = !w.head && w.Header().Get("Content-Encoding") == "" &&
+		code >= http.StatusOK && code != http.StatusNoContent && code != http.StatusNotModified
+	if !w.compress {
+		w.sendHeader()
+	}
+}
+
+// sendHeader writes the held header, with Content-Encoding if the response is compressed.
+func (w *gzipResponseWriter) sendHeader() {
+	if w.sentHeader {
+		return
+	}
+	w.sentHeader = true
+	if w.compress {
+		h := w.Header()
+		h.Set("Content-Encoding", "gzip")
+		h.Del("Content-Length")
+		w.gz = w.pool.Get().(*gzip.Writer)
+		w.gz.Reset(w.ResponseWriter)
+	}
+	w.ResponseWriter.WriteHeader(w.code)
+}
+
+func (w *gzipResponseWriter) Write(b []byte) (int, error) {
+	if !w.wroteHeader {
+		if w.Header().Get("Content-Type") == "" {
+			// detect from the uncompressed content
+			w.Header().Set("Content-Type", http.DetectContentType(b))
+		}
+		w.WriteHeader(http.StatusOK)
+	}
+	if !w.sentHeader {
+		if len(b) == 0 {
+			return 0, nil
+		}
+		w.sendHeader()
+	}
+	if !w.compress {
+		return w.ResponseWriter.Write(b)
+	}
+	return w.gz.Write(b)
+}
+
+// Flush sends the header, compressed if it may be, as a body is expected to follow.
+func (w *gzipResponseWriter) Flush() {
+	if !w.wroteHeader {
+		w.WriteHeader(http.StatusOK)
+	}
+	w.sendHeader()
+	if w.gz != nil {
+		_ = w.gz.Flush()
+	}
+	_ = http.NewResponseController(w.ResponseWriter).Flush()
+}
+
+// Unwrap returns the wrapped http.ResponseWriter for http.ResponseController.
+func (w *gzipResponseWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+func (w *gzipResponseWriter) close() {
+	if w.wroteHeader && !w.sentHeader {
+		// no body has been written
+		w.compress = false
+		w.sendHeader()
+	}
+	if w.gz == nil {
+		return
+	}
+	_ = w.gz.Close()
+	w.gz.Reset(io.Discard)
+	w.pool.Put(w.gz)
+	w.gz = nil
+}
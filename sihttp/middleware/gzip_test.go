@@ -0,0 +1,130 @@
+package middleware
+
+import (
+	"compress/gzip"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGzip(t *testing.T) {
+	body := strings.Repeat("<html>hello</html>", 100)
+	h := Gzip(0)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/encoded":
+			w.Header().Set("Content-Encoding", "br")
+		case "/nocontent":
+			w.WriteHeader(http.StatusNoContent)
+			return
+		case "/notmodified":
+			w.WriteHeader(http.StatusNotModified)
+			return
+		case "/empty":
+			w.Header().Set("Content-Type", "text/plain")
+			w.WriteHeader(http.StatusOK)
+			w.Write(nil)
+			return
+		}
+		w.Header().Set("Content-Length", "1800")
+		w.Write([]byte(body[:900]))
+		w.(http.Flusher).Flush()
+		w.Write([]byte(body[900:]))
+	}))
+	svr := httptest.NewServer(h)
+	defer svr.Close()
+
+	// the transport of the client decodes gzip transparently without Accept-Encoding set
+	get := func(path, acceptEncoding string) *http.Response {
+		req, _ := http.NewRequest(http.MethodGet, svr.URL+path, nil)
+		req.Header.Set("Accept-Encoding", acceptEncoding)
+		resp, err := http.DefaultTransport.RoundTrip(req)
+		require.Nil(t, err)
+		t.Cleanup(func() { resp.Body.Close() })
+		return resp
+	}
+
+	resp := get("/", "deflate, gzip;q=0.5")
+	assert.EqualValues(t, "gzip", resp.Header.Get("Content-Encoding"))
+	assert.EqualValues(t, "Accept-Encoding", resp.Header.Get("Vary"))
+	assert.EqualValues(t, "text/html; charset=utf-8", resp.Header.Get("Content-Type"))
+	assert.EqualValues(t, -1, resp.ContentLength)
+	gz, err := gzip.NewReader(resp.Body)
+	require.Nil(t, err)
+	b, err := io.ReadAll(gz)
+	require.Nil(t, err)
+	assert.EqualValues(t, body, string(b))
+
+	for _, ae := range []string{"", "deflate", "gzip;q=0"} {
+		resp = get("/", ae)
+		assert.EqualValues(t, "", resp.Header.Get("Content-Encoding"))
+		b, _ = io.ReadAll(resp.Body)
+		assert.EqualValues(t, body, string(b))
+	}
+
+	resp = get("/encoded", "gzip")
+	assert.EqualValues(t, "br", resp.Header.Get("Content-Encoding"))
+	b, _ = io.ReadAll(resp.Body)
+	assert.EqualValues(t, body, string(b))
+
+	for path, status := range map[string]int{
+		"/nocontent":   http.StatusNoContent,
+		"/notmodified": http.StatusNotModified,
+		"/empty":       http.StatusOK,
+	} {
+		resp = get(path, "gzip")
+		assert.EqualValues(t, status, resp.StatusCode, path)
+		assert.EqualValues(t, "", resp.Header.Get("Content-Encoding"), path)
+		b, _ = io.ReadAll(resp.Body)
+		assert.Len(t, b, 0, path)
+	}
+
+	req, _ := http.NewRequest(http.MethodHead, svr.URL+"/", nil)
+	req.Header.Set("Accept-Encoding", "gzip")
+	resp, err = http.DefaultTransport.RoundTrip(req)
+	require.Nil(t, err)
+	resp.Body.Close()
+	assert.EqualValues(t, "", resp.Header.Get("Content-Encoding"))
+	assert.EqualValues(t, 1800, resp.ContentLength)
+}
+
+func TestGzip_Upgrade(t *testing.T) {
+	h := Gzip(0)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hj, ok := w.(http.Hijacker)
+		if !ok {
+			http.Error(w, "hijacking is not supported", http.StatusInternalServerError)
+			return
+		}
+		conn, rw, err := hj.Hijack()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		rw.WriteString("HTTP/1.1 101 Switching Protocols\r\nConnection: Upgrade\r\nUpgrade: test\r\n\r\n")
+		rw.Flush()
+	}))
+	svr := httptest.NewServer(h)
+	defer svr.Close()
+
+	req, _ := http.NewRequest(http.MethodGet, svr.URL, nil)
+	req.Header.Set("Accept-Encoding", "gzip, deflate")
+	req.Header.Set("Connection", "keep-alive, Upgrade")
+	req.Header.Set("Upgrade", "test")
+	resp, err := http.DefaultTransport.RoundTrip(req)
+	require.Nil(t, err)
+	defer resp.Body.Close()
+	assert.EqualValues(t, http.StatusSwitchingProtocols, resp.StatusCode)
+}
+
+func Test_acceptsGzip(t *testing.T) {
+	assert.True(t, acceptsGzip("gzip"))
+	assert.True(t, acceptsGzip("br, gzip;q=0.8"))
+	assert.True(t, acceptsGzip("*"))
+	assert.False(t, acceptsGzip("gzip;q=0"))
+	assert.False(t, acceptsGzip("gzipx"))
+	assert.False(t, acceptsGzip(""))
+}
@@ -0,0 +1,32 @@
+package middleware
+
+import (
+	"net/http"
+	"time"
+)
+
+// Timeout responds with 503 if the handler does not complete in d, and cancels the context of the request.
+// Apply it to routes that need one, as the response is buffered and can't be flushed or hijacked.
+// See http.TimeoutHandler.
+func Timeout(d time.Duration) Middleware {
+	return func(next http.Handler) http.Handler {
+		return http.TimeoutHandler(next, d, http.StatusText(http.StatusServiceUnavailable))
+	}
+}
+
+// BodyLimit limits request bodies to n bytes. Requests of a larger Content-Length are responded with 413,
+// and reading beyond n bytes fails with *http.MaxBytesError otherwise.
+func BodyLimit(n int64) Middleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.ContentLength > n {
+				http.Error(w, http.StatusText(http.StatusRequestEntityTooLarge), http.StatusRequestEntityTooLarge)
+				return
+			}
+			if r.Body != nil && r.Body != http.NoBody {
+				r.Body = http.MaxBytesReader(w, r.Body, n)
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
@@ -0,0 +1,79 @@
+// Package middleware provides composable middlewares of net/http handlers, such as request id, access log,
+// panic recovery, timeouts, body size limit, gzip compression and rate limiting.
+//
+//	h := middleware.Chain(mux,
+//		middleware.RequestID(""),
+//		middleware.AccessLog(nil),
+//		middleware.Recover(nil),
+//		middleware.Gzip(0),
+//	)
+package middleware
+
+import (
+	"bufio"
+	"errors"
+	"net"
+	"net/http"
+)
+
+// Middleware wraps an http.Handler.
+type Middleware func(next http.Handler) http.Handler
+
+// Chain wraps h with mws. The first middleware is the outermost, which handles requests first.
+func Chain(h http.Handler, mws ...Middleware) http.Handler {
+	for i := len(mws) - 1; i >= 0; i-- {
+		if mws[i] == nil {
+			continue
+		}
+		h = mws[i](h)
+	}
+	return h
+}
+
+// responseWriter records the status and size of a response.
+type responseWriter struct {
+	http.ResponseWriter
+	status int
+	size   int64
+}
+
+func (w *responseWriter) WriteHeader(code int) {
+	if w.status == 0 {
+		w.status = code
+	}
+	w.ResponseWriter.WriteHeader(code)
+}
+
+func (w *responseWriter) Write(b []byte) (int, error) {
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+	n, err := w.ResponseWriter.Write(b)
+	w.size += int64(n)
+	return n, err
+}
+
+// Status returns the status written, which is 200 if only the body is written, or 0 if nothing is written.
+func (w *responseWriter) Status() int {
+	return w.status
+}
+
+func (w *responseWriter) Flush() {
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+	_ = http.NewResponseController(w.ResponseWriter).Flush()
+}
+
+func (w *responseWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	h, ok := w.ResponseWriter.(http.Hijacker)
+	if !ok {
+		return nil, nil, errors.New("middleware: hijacking is not supported")
+	}
+	return h.Hijack()
+}
+
+// Unwrap returns the wrapped http.ResponseWriter for http.ResponseController.
+func (w *responseWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
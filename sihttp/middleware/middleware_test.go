@@ -0,0 +1,137 @@
+package middleware
+
+import (
+	"bytes"
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestChain(t *testing.T) {
+	var order []string
+	mw := func(name string) Middleware {
+		return func(next http.Handler) http.Handler {
+			return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				order = append(order, name)
+				next.ServeHTTP(w, r)
+			})
+		}
+	}
+	h := Chain(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		order = append(order, "handler")
+	}), mw("a"), nil, mw("b"))
+
+	h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+	assert.EqualValues(t, []string{"a", "b", "handler"}, order)
+}
+
+func TestRequestID(t *testing.T) {
+	var id string
+	h := RequestID("")(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id = RequestIDFromContext(r.Context())
+	}))
+
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+	r.Header.Set(RequestIDHeader, "abc-1")
+	h.ServeHTTP(w, r)
+	assert.EqualValues(t, "abc-1", id)
+	assert.EqualValues(t, "abc-1", w.Header().Get(RequestIDHeader))
+
+	// generated
+	for _, v := range []string{"", "has space", strings.Repeat("a", maxRequestIDLength+1)} {
+		w = httptest.NewRecorder()
+		r = httptest.NewRequest(http.MethodGet, "/", nil)
+		r.Header.Set(RequestIDHeader, v)
+		h.ServeHTTP(w, r)
+		assert.Len(t, id, 36)
+		assert.EqualValues(t, id, w.Header().Get(RequestIDHeader))
+		assert.EqualValues(t, id, r.Header.Get(RequestIDHeader))
+	}
+}
+
+func TestAccessLog(t *testing.T) {
+	buf := &bytes.Buffer{}
+	h := Chain(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusCreated)
+		w.Write([]byte("hello"))
+	}), RequestID(""), AccessLog(log.New(buf, "", 0)))
+
+	r := httptest.NewRequest(http.MethodPost, "/books?token=secret", nil)
+	r.Header.Set(RequestIDHeader, "id-1")
+	h.ServeHTTP(httptest.NewRecorder(), r)
+	assert.Regexp(t, `^POST /books status=201 size=5 elapsed=\S+ remote=192.0.2.1:1234 request_id=id-1\n$`, buf.String())
+}
+
+func TestRecover(t *testing.T) {
+	buf := &bytes.Buffer{}
+	logger := log.New(buf, "", 0)
+	h := Chain(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		panic("boom")
+	}), AccessLog(logger), Recover(logger))
+
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/", nil))
+	assert.EqualValues(t, http.StatusInternalServerError, w.Code)
+	assert.Contains(t, buf.String(), "GET / panic=boom")
+	assert.Contains(t, buf.String(), "GET / status=500")
+
+	// the response is kept once written
+	h = Recover(logger)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusAccepted)
+		panic("boom")
+	}))
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/", nil))
+	assert.EqualValues(t, http.StatusAccepted, w.Code)
+
+	h = Recover(logger)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		panic(http.ErrAbortHandler)
+	}))
+	assert.PanicsWithValue(t, http.ErrAbortHandler, func() {
+		h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+	})
+}
+
+func TestTimeout(t *testing.T) {
+	h := Timeout(20 * time.Millisecond)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-r.Context().Done():
+		case <-time.After(time.Second):
+			w.Write([]byte("late"))
+		}
+	}))
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/", nil))
+	assert.EqualValues(t, http.StatusServiceUnavailable, w.Code)
+}
+
+func TestBodyLimit(t *testing.T) {
+	h := BodyLimit(4)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		b, err := io.ReadAll(r.Body)
+		var mbe *http.MaxBytesError
+		if assert.ErrorAs(t, err, &mbe) {
+			http.Error(w, string(b), http.StatusRequestEntityTooLarge)
+			return
+		}
+		w.Write(b)
+	}))
+
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/", strings.NewReader("hello")))
+	assert.EqualValues(t, http.StatusRequestEntityTooLarge, w.Code)
+
+	// unknown length
+	r := httptest.NewRequest(http.MethodPost, "/", io.NopCloser(strings.NewReader("hello")))
+	r.ContentLength = -1
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+	assert.EqualValues(t, http.StatusRequestEntityTooLarge, w.Code)
+	assert.EqualValues(t, "hell\n", w.Body.String())
+}
@@ -0,0 +1,337 @@
+package middleware
+
+import (
+	"context"
+	"log"
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// RateLimitResult is the result of Limiter.Allow.
+type RateLimitResult struct {
+	Allowed bool
+	// Limit is the number of requests allowed in a burst or a window.
+	Limit int
+	// Remaining is the number of requests allowed right now after this one.
+	Remaining int
+	// Reset is the time until the limit is restored, which is when a token bucket is full,
+	// or the current window of a sliding window ends.
+	Reset time.Duration
+	// RetryAfter is the time until a request is allowed again, which is zero if allowed.
+	RetryAfter time.Duration
+}
+
+// Limiter limits requests by key.
+type Limiter interface {
+	Allow(ctx context.Context, key string) (RateLimitResult, error)
+}
+
+// RateLimitStore stores states of limiters by key. Update replaces the state of key with the one returned by fn,
+// which is called with nil if there is no state, and expires it after ttl.
+// It must be atomic for a key, which a shared store can implement with optimistic locking calling fn again
+// on conflicts. fn has no side effects, so it's safe to be called more than once.
+type RateLimitStore interface {
+	Update(ctx context.Context, key string, ttl time.Duration, fn func(state []byte) []byte) error
+}
+
+// MemoryStore is a RateLimitStore in memory. Expired states are removed as the store grows.
+type MemoryStore struct {
+	mu      sync.Mutex
+	states  map[string]memoryState
+	sweepAt int
+	now     func() time.Time
+}
+
+type memoryState struct {
+	state   []byte
+	expires time.Time
+}
+
+const memoryStoreSweepSize = 1024
+
+// NewMemoryStore returns MemoryStore.
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{
+		states:  make(map[string]memoryState),
+		sweepAt: memoryStoreSweepSize,
+		now:     time.Now,
+	}
+}
+
+// Update implements RateLimitStore's Update method.
+func (s *MemoryStore) Update(_ context.Context, key string, ttl time.Duration, fn func(state []byte) []byte) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := s.now()
+	var state []byte
+	if ms, ok := s.states[key]; ok && now.Before(ms.expires) {
+		state = ms.state
+	}
+	s.states[key] = memoryState{state: fn(state), expires: now.Add(ttl)}
+
+	if len(s.states) >= s.sweepAt {
+		for k, ms := range s.states {
+			if !now.Before(ms.expires) {
+				delete(s.states, k)
+			}
+		}
+		s.sweepAt = max(2*len(s.states), memoryStoreSweepSize)
+	}
+	return nil
+}
+
+// Len returns the number of states including expired ones not removed yet.
+func (s *MemoryStore) Len() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.states)
+}
+
+// TokenBucket is a Limiter of token buckets, which allow bursts of requests up to their capacity and are refilled
+// at a constant rate.
+type TokenBucket struct {
+	rate  float64 // tokens per second
+	burst int
+	store RateLimitStore
+	now   func() time.Time
+}
+
+// NewTokenBucket returns TokenBucket refilling limit tokens per duration up to burst, storing buckets in store,
+// which is a MemoryStore if nil. burst is limit if it is not positive. It panics if limit or per is not positive.
+func NewTokenBucket(limit int, per time.Duration, burst int, store RateLimitStore) *TokenBucket {
+	if limit <= 0 || per <= 0 {
+		panic("middleware: limit and per of token bucket must be positive")
+	}
+	if burst <= 0 {
+		burst = limit
+	}
+	if store == nil {
+		store = NewMemoryStore()
+	}
+	return &TokenBucket{
+		rate:  float64(limit) / per.Seconds(),
+		burst: burst,
+		store: store,
+		now:   time.Now,
+	}
+}
+
+// Allow implements Limiter's Allow method.
+func (tb *TokenBucket) Allow(ctx context.Context, key string) (RateLimitResult, error) {
+	now := tb.now()
+	full := time.Duration(float64(tb.burst) / tb.rate * float64(time.Second))
+
+	var res RateLimitResult
+	err := tb.store.Update(ctx, "tb:"+key, full, func(state []byte) []byte {
+		tokens := float64(tb.burst)
+		if s := splitState(state, 2); s != nil {
+			t, err1 := strconv.ParseFloat(s[0], 64)
+			last, err2 := strconv.ParseInt(s[1], 10, 64)
+			if err1 == nil && err2 == nil {
+				tokens = math.Min(tokens, t+now.Sub(time.Unix(0, last)).Seconds()*tb.rate)
+			}
+		}
+
+		res = RateLimitResult{Limit: tb.burst}
+		if tokens >= 1 {
+			tokens--
+			res.Allowed = true
+		} else {
+			res.RetryAfter = seconds((1 - tokens) / tb.rate)
+		}
+		res.Remaining = int(tokens)
+		res.Reset = seconds((float64(tb.burst) - tokens) / tb.rate)
+		return []byte(strconv.FormatFloat(tokens, 'f', -1, 64) + "," + strconv.FormatInt(now.UnixNano(), 10))
+	})
+	return res, err
+}
+
+// SlidingWindow is a Limiter of sliding windows, which allow limit requests in any window. The count of a window
+// is estimated from the counts of the current and the previous fixed windows, weighted by their overlap.
+type SlidingWindow struct {
+	limit  int
+	window time.Duration
+	store  RateLimitStore
+	now    func() time.Time
+}
+
+// NewSlidingWindow returns SlidingWindow allowing limit requests in window, storing counts in store,
+// which is a MemoryStore if nil. It panics if limit or window is not positive.
+func NewSlidingWindow(limit int, window time.Duration, store RateLimitStore) *SlidingWindow {
+	if limit <= 0 || window <= 0 {
+		panic("middleware: limit and window of sliding window must be positive")
+	}
+	if store == nil {
+		store = NewMemoryStore()
+	}
+	return &SlidingWindow{
+		limit:  limit,
+		window: window,
+		store:  store,
+		now:    time.Now,
+	}
+}
+
+// Allow implements Limiter's Allow method.
+func (sw *SlidingWindow) Allow(ctx context.Context, key string) (RateLimitResult, error) {
+	now := sw.now()
+	start := now.Truncate(sw.window)
+	elapsed := now.Sub(start)
+
+	var res RateLimitResult
+	err := sw.store.Update(ctx, "sw:"+key, 2*sw.window, func(state []byte) []byte {
+		var prev, curr int
+		if s := splitState(state, 3); s != nil {
+			at, err1 := strconv.ParseInt(s[0], 10, 64)
+			p, err2 := strconv.Atoi(s[1])
+			c, err3 := strconv.Atoi(s[2])
+			if err1 == nil && err2 == nil && err3 == nil {
+				switch at {
+				case start.UnixNano():
+					prev, curr = p, c
+				case start.Add(-sw.window).UnixNano():
+					prev = c
+				}
+			}
+		}
+
+		weight := 1 - float64(elapsed)/float64(sw.window)
+		count := float64(prev)*weight + float64(curr)
+		res = RateLimitResult{Limit: sw.limit, Reset: sw.window - elapsed}
+		if count < float64(sw.limit) {
+			curr++
+			count++
+			res.Allowed = true
+		} else if curr >= sw.limit {
+			res.RetryAfter = sw.window - elapsed
+		} else {
+			// until the previous window slides out enough
+			res.RetryAfter = time.Duration((1-float64(sw.limit-curr)/float64(prev))*float64(sw.window)) - elapsed
+		}
+		res.Remaining = max(int(float64(sw.limit)-count), 0)
+		return []byte(strconv.FormatInt(start.UnixNano(), 10) + "," + strconv.Itoa(prev) + "," + strconv.Itoa(curr))
+	})
+	return res, err
+}
+
+func seconds(s float64) time.Duration {
+	return time.Duration(math.Ceil(s * float64(time.Second)))
+}
+
+// splitState splits state into n fields, and returns nil if it is malformed.
+func splitState(state []byte, n int) []string {
+	if state == nil {
+		return nil
+	}
+	fields := strings.Split(string(state), ",")
+	if len(fields) != n {
+		return nil
+	}
+	return fields
+}
+
+// KeyFunc returns the key to limit r by.
+type KeyFunc func(r *http.Request) string
+
+// KeyByIP keys requests by the ip of their remote address. Put a middleware that sets RemoteAddr from
+// headers of trusted proxies before it, if there are any.
+func KeyByIP() KeyFunc {
+	return func(r *http.Request) string {
+		host, _, err := net.SplitHostPort(r.RemoteAddr)
+		if err != nil {
+			return r.RemoteAddr
+		}
+		return host
+	}
+}
+
+// KeyByHeader keys requests by header, such as an api key. Requests without it share the empty key.
+func KeyByHeader(header string) KeyFunc {
+	return func(r *http.Request) string {
+		return r.Header.Get(header)
+	}
+}
+
+// RateLimitOption is an option of RateLimit.
+type RateLimitOption interface {
+	apply(c *rateLimitConfig)
+}
+
+// RateLimitOptionFunc wraps a function to conforms to RateLimitOption interface.
+type RateLimitOptionFunc func(c *rateLimitConfig)
+
+func (o RateLimitOptionFunc) apply(c *rateLimitConfig) {
+	o(c)
+}
+
+type rateLimitConfig struct {
+	failClosed bool
+	logger     *log.Logger
+}
+
+// WithFailClosed responds with 503 when the limiter fails, instead of allowing the request.
+func WithFailClosed() RateLimitOptionFunc {
+	return RateLimitOptionFunc(func(c *rateLimitConfig) {
+		c.failClosed = true
+	})
+}
+
+// WithRateLimitLogger logs errors of the limiter to logger, which is log.Default() by default.
+func WithRateLimitLogger(logger *log.Logger) RateLimitOptionFunc {
+	return RateLimitOptionFunc(func(c *rateLimitConfig) {
+		if logger != nil {
+			c.logger = logger
+		}
+	})
+}
+
+// RateLimit limits requests by their key of keyFunc, which is KeyByIP if nil. It sets RateLimit-Limit,
+// RateLimit-Remaining and RateLimit-Reset headers, and responds to requests over the limit with 429 and Retry-After.
+// Errors of limiter are logged, and the requests are allowed unless WithFailClosed is set.
+func RateLimit(limiter Limiter, keyFunc KeyFunc, opts ...RateLimitOption) Middleware {
+	if keyFunc == nil {
+		keyFunc = KeyByIP()
+	}
+	conf := rateLimitConfig{logger: log.Default()}
+	for _, o := range opts {
+		if o == nil {
+			continue
+		}
+		o.apply(&conf)
+	}
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			res, err := limiter.Allow(r.Context(), keyFunc(r))
+			if err != nil {
+				conf.logger.Printf("%s %s rate limit error=%v request_id=%s", r.Method, r.URL.Path, err, RequestIDFromContext(r.Context()))
+				if conf.failClosed {
+					http.Error(w, http.StatusText(http.StatusServiceUnavailable), http.StatusServiceUnavailable)
+					return
+				}
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			h := w.Header()
+			h.Set("RateLimit-Limit", strconv.Itoa(res.Limit))
+			h.Set("RateLimit-Remaining", strconv.Itoa(res.Remaining))
+			h.Set("RateLimit-Reset", ceilSeconds(res.Reset))
+			if !res.Allowed {
+				h.Set("Retry-After", ceilSeconds(res.RetryAfter))
+				http.Error(w, http.StatusText(http.StatusTooManyRequests), http.StatusTooManyRequests)
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+func ceilSeconds(d time.Duration) string {
+	return strconv.FormatInt(int64(math.Ceil(d.Seconds())), 10)
+}
@@ -0,0 +1,190 @@
+package middleware
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type _clock struct {
+	t time.Time
+}
+
+func (c *_clock) now() time.Time {
+	return c.t
+}
+
+func TestTokenBucket(t *testing.T) {
+	clock := &_clock{t: time.Unix(1700000000, 0)}
+	store := NewMemoryStore()
+	store.now = clock.now
+	// 2 tokens per second up to 4
+	tb := NewTokenBucket(2, time.Second, 4, store)
+	tb.now = clock.now
+	ctx := context.Background()
+
+	for i := 3; i >= 0; i-- {
+		res, err := tb.Allow(ctx, "a")
+		require.Nil(t, err)
+		assert.True(t, res.Allowed)
+		assert.EqualValues(t, i, res.Remaining)
+		assert.EqualValues(t, 4, res.Limit)
+	}
+	res, _ := tb.Allow(ctx, "a")
+	assert.False(t, res.Allowed)
+	assert.EqualValues(t, 500*time.Millisecond, res.RetryAfter)
+	assert.EqualValues(t, 2*time.Second, res.Reset)
+
+	// other keys have their own buckets
+	res, _ = tb.Allow(ctx, "b")
+	assert.True(t, res.Allowed)
+
+	clock.t = clock.t.Add(500 * time.Millisecond)
+	res, _ = tb.Allow(ctx, "a")
+	assert.True(t, res.Allowed)
+	assert.EqualValues(t, 0, res.Remaining)
+
+	// refilled up to the burst
+	clock.t = clock.t.Add(time.Hour)
+	res, _ = tb.Allow(ctx, "a")
+	assert.EqualValues(t, 3, res.Remaining)
+}
+
+func TestSlidingWindow(t *testing.T) {
+	// start of a minute
+	clock := &_clock{t: time.Unix(1699999980, 0)}
+	store := NewMemoryStore()
+	store.now = clock.now
+	sw := NewSlidingWindow(4, time.Minute, store)
+	sw.now = clock.now
+	ctx := context.Background()
+
+	clock.t = clock.t.Add(30 * time.Second)
+	for i := 3; i >= 0; i-- {
+		res, err := sw.Allow(ctx, "a")
+		require.Nil(t, err)
+		assert.True(t, res.Allowed)
+		assert.EqualValues(t, i, res.Remaining)
+	}
+	res, _ := sw.Allow(ctx, "a")
+	assert.False(t, res.Allowed)
+	assert.EqualValues(t, 30*time.Second, res.RetryAfter)
+	assert.EqualValues(t, 30*time.Second, res.Reset)
+
+	// 4 requests of the previous window are weighted by 3/4
+	clock.t = clock.t.Add(45 * time.Second)
+	res, _ = sw.Allow(ctx, "a")
+	assert.True(t, res.Allowed)
+	assert.EqualValues(t, 0, res.Remaining)
+	res, _ = sw.Allow(ctx, "a")
+	assert.False(t, res.Allowed)
+	// 4*(1-t/60)+1 < 4 from t=15s, 0s from now
+	assert.EqualValues(t, 0, res.RetryAfter)
+	clock.t = clock.t.Add(time.Second)
+	res, _ = sw.Allow(ctx, "a")
+	assert.True(t, res.Allowed)
+
+	// the previous window is forgotten after two windows
+	clock.t = clock.t.Add(2 * time.Minute)
+	res, _ = sw.Allow(ctx, "a")
+	assert.EqualValues(t, 3, res.Remaining)
+}
+
+func TestMemoryStore_Sweep(t *testing.T) {
+	clock := &_clock{t: time.Unix(1700000000, 0)}
+	store := NewMemoryStore()
+	store.now = clock.now
+	set := func(key string, ttl time.Duration) {
+		store.Update(context.Background(), key, ttl, func([]byte) []byte { return []byte("1") })
+	}
+	for i := 0; i < memoryStoreSweepSize-1; i++ {
+		set(strconv.Itoa(i), time.Second)
+	}
+	clock.t = clock.t.Add(2 * time.Second)
+	set("live", time.Minute)
+	assert.EqualValues(t, 1, store.Len())
+
+	var got []byte
+	store.Update(context.Background(), "live", time.Minute, func(state []byte) []byte {
+		got = state
+		return state
+	})
+	assert.EqualValues(t, "1", string(got))
+}
+
+func TestRateLimit(t *testing.T) {
+	h := RateLimit(NewTokenBucket(1, time.Minute, 2, nil), KeyByHeader("X-Api-Key"))(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("ok"))
+	}))
+	do := func(key string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		r := httptest.NewRequest(http.MethodGet, "/", nil)
+		r.Header.Set("X-Api-Key", key)
+		h.ServeHTTP(w, r)
+		return w
+	}
+
+	w := do("a")
+	assert.EqualValues(t, http.StatusOK, w.Code)
+	assert.EqualValues(t, "2", w.Header().Get("RateLimit-Limit"))
+	assert.EqualValues(t, "1", w.Header().Get("RateLimit-Remaining"))
+	assert.EqualValues(t, "60", w.Header().Get("RateLimit-Reset"))
+	do("a")
+
+	w = do("a")
+	assert.EqualValues(t, http.StatusTooManyRequests, w.Code)
+	assert.EqualValues(t, "0", w.Header().Get("RateLimit-Remaining"))
+	assert.EqualValues(t, "60", w.Header().Get("Retry-After"))
+
+	assert.EqualValues(t, http.StatusOK, do("b").Code)
+}
+
+type _failingStore struct{}
+
+func (_failingStore) Update(context.Context, string, time.Duration, func([]byte) []byte) error {
+	return errors.New("store down")
+}
+
+func TestRateLimit_LimiterError(t *testing.T) {
+	var buf bytes.Buffer
+	logger := log.New(&buf, "", 0)
+	limiter := NewSlidingWindow(1, time.Minute, _failingStore{})
+	ok := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("ok"))
+	})
+
+	w := httptest.NewRecorder()
+	RateLimit(limiter, nil, WithRateLimitLogger(logger))(ok).ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/", nil))
+	assert.EqualValues(t, http.StatusOK, w.Code)
+	assert.Contains(t, buf.String(), "store down")
+
+	w = httptest.NewRecorder()
+	RateLimit(limiter, nil, WithRateLimitLogger(logger), WithFailClosed())(ok).ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/", nil))
+	assert.EqualValues(t, http.StatusServiceUnavailable, w.Code)
+}
+
+func TestNewLimiter_Invalid(t *testing.T) {
+	assert.Panics(t, func() { NewTokenBucket(0, time.Second, 1, nil) })
+	assert.Panics(t, func() { NewTokenBucket(1, 0, 1, nil) })
+	assert.Panics(t, func() { NewSlidingWindow(0, time.Second, nil) })
+	assert.Panics(t, func() { NewSlidingWindow(1, -time.Second, nil) })
+}
+
+func TestKeyByIP(t *testing.T) {
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+	r.RemoteAddr = "10.0.0.1:1234"
+	assert.EqualValues(t, "10.0.0.1", KeyByIP()(r))
+	r.RemoteAddr = "[::1]:1234"
+	assert.EqualValues(t, "::1", KeyByIP()(r))
+	r.RemoteAddr = "pipe"
+	assert.EqualValues(t, "pipe", KeyByIP()(r))
+}
@@ -0,0 +1,194 @@
+package sihttp
+
+import (
+	"context"
+	"net/http"
+	"sync"
+	"time"
+
+	"golang.org/x/oauth2"
+	"golang.org/x/oauth2/clientcredentials"
+)
+
+const defaultTokenExpirySkew = 10 * time.Second
+
+// WithOAuth2ClientCredentials authorizes requests with tokens of OAuth2 client credentials flow.
+// See WithOAuth2TokenSource for how tokens are cached and refreshed.
+func WithOAuth2ClientCredentials(conf *clientcredentials.Config, expirySkew time.Duration) ClientOptionFunc {
+	return ClientOptionFunc(func(c *Client) error {
+		tc := newTokenCache(nil, expirySkew, c.client, func(ctx context.Context, _ *oauth2.Token) (*oauth2.Token, error) {
+			return conf.Token(ctx)
+		})
+		c.appendMiddleware(tc.middleware)
+		return nil
+	})
+}
+
+// WithOAuth2RefreshToken authorizes requests with token, and refreshes it with its refresh token when it expires.
+// See WithOAuth2TokenSource for how tokens are cached and refreshed.
+func WithOAuth2RefreshToken(conf *oauth2.Config, token *oauth2.Token, expirySkew time.Duration) ClientOptionFunc {
+	return ClientOptionFunc(func(c *Client) error {
+		tc := newTokenCache(token, expirySkew, c.client, func(ctx context.Context, prev *oauth2.Token) (*oauth2.Token, error) {
+			// a token without access token makes the source refresh it right away
+			return conf.TokenSource(ctx, &oauth2.Token{RefreshToken: prev.RefreshToken}).Token()
+		})
+		c.appendMiddleware(tc.middleware)
+		return nil
+	})
+}
+
+// WithOAuth2TokenSource authorizes requests with tokens from ts.
+//
+// A token is cached until expirySkew before it expires, which is defaultTokenExpirySkew if zero or negative.
+// Concurrent requests wait for a single refresh. When a response is 401 Unauthorized, the token is refreshed
+// and the request is sent once more if its body can be rewound. Requests with Authorization header are sent as they are.
+//
+// ts should return a new token on each call to be refreshed on 401, which oauth2.ReuseTokenSource doesn't.
+func WithOAuth2TokenSource(ts oauth2.TokenSource, expirySkew time.Duration) ClientOptionFunc {
+	return ClientOptionFunc(func(c *Client) error {
+		tc := newTokenCache(nil, expirySkew, c.client, func(_ context.Context, _ *oauth2.Token) (*oauth2.Token, error) {
+			return ts.Token()
+		})
+		c.appendMiddleware(tc.middleware)
+		return nil
+	})
+}
+
+type tokenFetcher func(ctx context.Context, prev *oauth2.Token) (*oauth2.Token, error)
+
+// tokenCache caches a token and refreshes it one at a time.
+type tokenCache struct {
+	fetch  tokenFetcher
+	skew   time.Duration
+	client *http.Client
+
+	mu       sync.Mutex
+	token    *oauth2.Token
+	stale    bool
+	gen      uint64      // incremented whenever token is refreshed
+	fetching *tokenFetch // the refresh in flight, nil if none
+}
+
+// tokenFetch is a refresh shared by callers of tokenCache.get. Its results are set before done is closed.
+type tokenFetch struct {
+	done  chan struct{}
+	token *oauth2.Token
+	gen   uint64
+	err   error
+}
+
+func newTokenCache(token *oauth2.Token, skew time.Duration, client *http.Client, fetch tokenFetcher) *tokenCache {
+	if skew <= 0 {
+		skew = defaultTokenExpirySkew
+	}
+	return &tokenCache{
+		fetch:  fetch,
+		skew:   skew,
+		client: client,
+		token:  token,
+	}
+}
+
+// get returns the cached token and its generation, refreshing it if it is stale or about to expire.
+// Concurrent callers wait for a single refresh, each until its ctx is done. The refresh is not canceled
+// by ctx of the caller starting it, so that it is not failed for the others.
+func (tc *tokenCache) get(ctx context.Context) (*oauth2.Token, uint64, error) {
+	tc.mu.Lock()
+	if !tc.stale && tc.valid(tc.token) {
+		defer tc.mu.Unlock()
+		return tc.token, tc.gen, nil
+	}
+	f := tc.fetching
+	if f == nil {
+		f = &tokenFetch{done: make(chan struct{})}
+		tc.fetching = f
+		prev := tc.token
+		go tc.refresh(context.WithoutCancel(ctx), f, prev)
+	}
+	tc.mu.Unlock()
+
+	select {
+	case <-f.done:
+		return f.token, f.gen, f.err
+	case <-ctx.Done():
+		return nil, 0, ctx.Err()
+	}
+}
+
+// refresh fetches a token from prev, and completes f with it.
+func (tc *tokenCache) refresh(ctx context.Context, f *tokenFetch, prev *oauth2.Token) {
+	if tc.client != nil {
+		// token endpoints are requested with the same http.Client
+		ctx = context.WithValue(ctx, oauth2.HTTPClient, tc.client)
+	}
+	if prev == nil {
+		prev = &oauth2.Token{}
+	}
+	token, err := tc.fetch(ctx, prev)
+
+	tc.mu.Lock()
+	defer tc.mu.Unlock()
+	tc.fetching = nil
+	if err != nil {
+		f.err = err
+	} else {
+		tc.token = token
+		tc.stale = false
+		tc.gen++
+		f.token, f.gen = token, tc.gen
+	}
+	close(f.done)
+}
+
+func (tc *tokenCache) valid(token *oauth2.Token) bool {
+	if token == nil || token.AccessToken == "" {
+		return false
+	}
+	return token.Expiry.IsZero() || time.Now().Add(tc.skew).Before(token.Expiry)
+}
+
+// invalidate marks the token of generation gen stale, unless it has been refreshed since.
+func (tc *tokenCache) invalidate(gen uint64) {
+	tc.mu.Lock()
+	defer tc.mu.Unlock()
+	if tc.gen == gen {
+		tc.stale = true
+	}
+}
+
+func (tc *tokenCache) middleware(next Doer) Doer {
+	return DoerFunc(func(req *http.Request) (*http.Response, error) {
+		if _, ok := req.Header["Authorization"]; ok {
+			return next.Do(req)
+		}
+
+		token, gen, err := tc.get(req.Context())
+		if err != nil {
+			return nil, err
+		}
+		token.SetAuthHeader(req)
+
+		resp, err := next.Do(req)
+		if err != nil || resp.StatusCode != http.StatusUnauthorized {
+			return resp, err
+		}
+		retry, ok := rewindRequest(req)
+		if !ok {
+			return resp, nil
+		}
+
+		tc.invalidate(gen)
+		token, _, err = tc.get(req.Context())
+		if err != nil {
+			// the 401 response is more informative than the refresh error
+			return resp, nil
+		}
+		drainBody(resp)
+
+		if retry == req {
+			retry = req.Clone(req.Context())
+		}
+		token.SetAuthHeader(retry)
+		return next.Do(retry)
+	})
+}
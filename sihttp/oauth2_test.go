@@ -0,0 +1,230 @@
+package sihttp
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/oauth2"
+	"golang.org/x/oauth2/clientcredentials"
+)
+
+// _oauthServer issues tokens at /token and accepts only the latest one at other paths.
+type _oauthServer struct {
+	*httptest.Server
+	issued    atomic.Int32
+	expiresIn int
+	current   atomic.Value
+}
+
+func newOAuthServer(t *testing.T, expiresIn int) *_oauthServer {
+	s := &_oauthServer{expiresIn: expiresIn}
+	s.current.Store("")
+	s.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/token" {
+			r.ParseForm()
+			if r.Form.Get("grant_type") == "refresh_token" && r.Form.Get("refresh_token") != "refresh" {
+				http.Error(w, `{"error":"invalid_grant"}`, http.StatusBadRequest)
+				return
+			}
+			// slow down so that concurrent requests overlap
+			time.Sleep(20 * time.Millisecond)
+			token := fmt.Sprintf("token-%d", s.issued.Add(1))
+			s.current.Store(token)
+			w.Header().Set("Content-Type", "application/json")
+			fmt.Fprintf(w, `{"access_token":%q,"token_type":"Bearer","expires_in":%d}`, token, s.expiresIn)
+			return
+		}
+
+		if r.Header.Get("Authorization") != "Bearer "+s.current.Load().(string) {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		w.Write([]byte("ok"))
+	}))
+	return s
+}
+
+// revoke makes the current token invalid.
+func (s *_oauthServer) revoke() {
+	s.current.Store("revoked")
+}
+
+func Test_Client_WithOAuth2ClientCredentials(t *testing.T) {
+	svr := newOAuthServer(t, 3600)
+	defer svr.Close()
+
+	c := NewClient(_newStandardClient(), WithBaseUrl(svr.URL), WithOAuth2ClientCredentials(&clientcredentials.Config{
+		ClientID:     "id",
+		ClientSecret: "secret",
+		TokenURL:     svr.URL + "/token",
+	}, 0))
+
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			b, err := c.Get("/api", nil, nil)
+			assert.Nil(t, err)
+			assert.EqualValues(t, "ok", string(b))
+		}()
+	}
+	wg.Wait()
+	assert.EqualValues(t, 1, svr.issued.Load(), "concurrent requests must share a single token")
+
+	// refreshed and retried once on 401, the body is sent again
+	svr.revoke()
+	b, err := c.Post("/api", nil, []byte("body"))
+	require.Nil(t, err)
+	assert.EqualValues(t, "ok", string(b))
+	assert.EqualValues(t, 2, svr.issued.Load())
+
+	// explicit Authorization header is kept
+	_, err = c.Get("/api", http.Header{"Authorization": {"Bearer other"}}, nil)
+	var e *Error
+	require.True(t, errors.As(err, &e))
+	assert.EqualValues(t, http.StatusUnauthorized, e.GetStatusCode(0))
+	assert.EqualValues(t, 2, svr.issued.Load())
+}
+
+func Test_Client_WithOAuth2_ExpirySkew(t *testing.T) {
+	svr := newOAuthServer(t, 5)
+	defer svr.Close()
+
+	conf := &clientcredentials.Config{ClientID: "id", ClientSecret: "secret", TokenURL: svr.URL + "/token"}
+
+	// tokens expiring within the skew are refreshed on every request
+	c := NewClient(_newStandardClient(), WithBaseUrl(svr.URL), WithOAuth2ClientCredentials(conf, 10*time.Second))
+	for i := 0; i < 3; i++ {
+		_, err := c.Get("/api", nil, nil)
+		require.Nil(t, err)
+	}
+	assert.EqualValues(t, 3, svr.issued.Load())
+
+	c = NewClient(_newStandardClient(), WithBaseUrl(svr.URL), WithOAuth2ClientCredentials(conf, time.Second))
+	for i := 0; i < 3; i++ {
+		_, err := c.Get("/api", nil, nil)
+		require.Nil(t, err)
+	}
+	assert.EqualValues(t, 4, svr.issued.Load())
+}
+
+func Test_Client_WithOAuth2RefreshToken(t *testing.T) {
+	svr := newOAuthServer(t, 3600)
+	defer svr.Close()
+
+	conf := &oauth2.Config{ClientID: "id", Endpoint: oauth2.Endpoint{TokenURL: svr.URL + "/token"}}
+
+	// the initial token is used while it is valid
+	svr.current.Store("initial")
+	c := NewClient(_newStandardClient(), WithBaseUrl(svr.URL), WithOAuth2RefreshToken(conf, &oauth2.Token{
+		AccessToken:  "initial",
+		TokenType:    "Bearer",
+		RefreshToken: "refresh",
+		Expiry:       time.Now().Add(time.Hour),
+	}, 0))
+	_, err := c.Get("/api", nil, nil)
+	require.Nil(t, err)
+	assert.EqualValues(t, 0, svr.issued.Load())
+
+	svr.revoke()
+	_, err = c.Get("/api", nil, nil)
+	require.Nil(t, err)
+	assert.EqualValues(t, 1, svr.issued.Load())
+
+	// refresh token is kept when the response doesn't include one
+	svr.revoke()
+	_, err = c.Get("/api", nil, nil)
+	require.Nil(t, err)
+	assert.EqualValues(t, 2, svr.issued.Load())
+
+	// refresh failure returns the 401 response
+	c = NewClient(_newStandardClient(), WithBaseUrl(svr.URL), WithOAuth2RefreshToken(conf, &oauth2.Token{
+		AccessToken:  "initial",
+		RefreshToken: "invalid",
+	}, 0))
+	_, err = c.Get("/api", nil, nil)
+	var e *Error
+	require.True(t, errors.As(err, &e))
+	assert.EqualValues(t, http.StatusUnauthorized, e.GetStatusCode(0))
+}
+
+func Test_Client_WithOAuth2TokenSource_Error(t *testing.T) {
+	errToken := errors.New("no token")
+	c := NewClient(_newStandardClient(), WithOAuth2TokenSource(oauth2TokenSourceFunc(func() (*oauth2.Token, error) {
+		return nil, errToken
+	}), 0))
+	_, err := c.Get("http://localhost/api", nil, nil)
+	assert.ErrorIs(t, err, errToken)
+}
+
+func Test_Client_WithOAuth2TokenSource_SlowRefresh(t *testing.T) {
+	svr := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("ok"))
+	}))
+	defer svr.Close()
+
+	release := make(chan struct{})
+	closeRelease := sync.OnceFunc(func() { close(release) })
+	// releases the refresh anyway, so that callers waiting for it holding the lock don't hang
+	time.AfterFunc(time.Second, closeRelease)
+	var fetched atomic.Int32
+	c := NewClient(_newStandardClient(), WithOAuth2TokenSource(oauth2TokenSourceFunc(func() (*oauth2.Token, error) {
+		fetched.Add(1)
+		<-release
+		return &oauth2.Token{AccessToken: "t"}, nil
+	}), 0))
+
+	// callers give up by their own context while the refresh is in flight
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	_, err := c.GetContext(ctx, svr.URL, nil, nil)
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+	assert.Less(t, time.Since(start), time.Second)
+
+	// the refresh is shared, and not canceled by the caller that has given up
+	done := make(chan error, 1)
+	go func() {
+		_, err := c.Get(svr.URL, nil, nil)
+		done <- err
+	}()
+	closeRelease()
+	require.Nil(t, <-done)
+	assert.EqualValues(t, 1, fetched.Load())
+}
+
+func Test_Client_WithOAuth2TokenSource_RetryOnce(t *testing.T) {
+	var count atomic.Int32
+	svr := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		count.Add(1)
+		assert.True(t, strings.HasPrefix(r.Header.Get("Authorization"), "Bearer t"))
+		w.WriteHeader(http.StatusUnauthorized)
+	}))
+	defer svr.Close()
+
+	var n atomic.Int32
+	c := NewClient(_newStandardClient(), WithOAuth2TokenSource(oauth2TokenSourceFunc(func() (*oauth2.Token, error) {
+		return &oauth2.Token{AccessToken: fmt.Sprintf("t%d", n.Add(1))}, nil
+	}), 0))
+	_, err := c.Get(svr.URL, nil, nil)
+	require.NotNil(t, err)
+	assert.EqualValues(t, 2, count.Load())
+	assert.EqualValues(t, 2, n.Load())
+}
+
+type oauth2TokenSourceFunc func() (*oauth2.Token, error)
+
+func (f oauth2TokenSourceFunc) Token() (*oauth2.Token, error) {
+	return f()
+}
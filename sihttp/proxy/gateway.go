@@ -0,0 +1,297 @@
+// Package proxy provides an API gateway handler that routes requests to pools of upstream servers
+// on top of httputil.ReverseProxy.
+//
+//	users, _ := proxy.NewPool([]proxy.Target{{Url: "http://users-1:8080", Weight: 2}, {Url: "http://users-2:8080"}})
+//	gw, _ := proxy.NewGateway(
+//		proxy.Route{Prefix: "/users/", Pool: users, RequestOptions: []sihttp.RequestOption{
+//			sihttp.WithBearerToken(token),
+//		}},
+//	)
+//	s := sihttp.NewServerWithOptions(gw, sihttp.WithAddr(":8080"))
+package proxy
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"net/http/httputil"
+	"strings"
+
+	"github.com/wonksing/si/v2/sihttp"
+)
+
+// Route routes requests matching all of Prefix, Host and Headers to Pool.
+type Route struct {
+	// Prefix matches the path of requests. Every path matches if empty.
+	Prefix string
+	// StripPrefix removes Prefix from the path sent to upstreams.
+	StripPrefix bool
+	// Host matches the host of requests without port, case-insensitively. A leading "*." matches any subdomain.
+	// Every host matches if empty.
+	Host string
+	// Headers match requests having every header with the value.
+	Headers map[string]string
+
+	Pool *Pool
+
+	// RequestOptions are applied to requests sent to upstreams, such as sihttp.WithBearerToken and
+	// sihttp.WithHeaderHmac256. The body is buffered only if an option reads it with GetBody.
+	RequestOptions []sihttp.RequestOption
+	// Rewrite modifies requests sent to upstreams after their url is set to the upstream, before RequestOptions.
+	Rewrite func(r *httputil.ProxyRequest)
+	// ModifyResponse modifies responses from upstreams. Returning an error responds with 502.
+	ModifyResponse func(resp *http.Response) error
+}
+
+func (rt *Route) matches(r *http.Request) bool {
+	if rt.Prefix != "" && !strings.HasPrefix(r.URL.Path, rt.Prefix) {
+		return false
+	}
+	if rt.Host != "" && !matchHost(rt.Host, r.Host) {
+		return false
+	}
+	for k, v := range rt.Headers {
+		if r.Header.Get(k) != v {
+			return false
+		}
+	}
+	return true
+}
+
+func matchHost(pattern, host string) bool {
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+	host = strings.ToLower(host)
+	pattern = strings.ToLower(pattern)
+	if suffix, ok := strings.CutPrefix(pattern, "*"); ok {
+		return strings.HasSuffix(host, suffix) && len(host) > len(suffix)
+	}
+	return host == pattern
+}
+
+const defaultMaxBodyBytes = 10 << 20
+
+// Gateway is an http.Handler proxying requests to the upstreams of the first route they match.
+// It responds with 404 if no route matches, 503 if every upstream is ejected, and 502 or 504
+// if the upstream fails.
+type Gateway struct {
+	// Transport sends requests to upstreams. http.DefaultTransport is used if nil.
+	Transport http.RoundTripper
+	// ErrorLog logs errors proxying requests. The standard logger is used if nil.
+	ErrorLog *log.Logger
+	// MaxBodyBytes limits the size of bodies buffered for RequestOptions reading them.
+	// Larger requests are responded with 413. It is 10 MB if zero or negative.
+	MaxBodyBytes int64
+
+	routes []Route
+	proxy  *httputil.ReverseProxy
+}
+
+// NewGateway returns a Gateway of routes, which are matched in order.
+func NewGateway(routes ...Route) (*Gateway, error) {
+	for _, rt := range routes {
+		if rt.Pool == nil {
+			return nil, errors.New("proxy: route has no pool: " + rt.Prefix)
+		}
+	}
+	g := &Gateway{routes: routes}
+	g.proxy = &httputil.ReverseProxy{
+		Rewrite:        g.rewrite,
+		Transport:      roundTripperFunc(g.roundTrip),
+		ModifyResponse: g.modifyResponse,
+		ErrorHandler:   g.handleError,
+	}
+	return g, nil
+}
+
+type proxiedKey struct{}
+
+// proxied is the route and the upstream of a request.
+type proxied struct {
+	route    *Route
+	upstream *upstream
+}
+
+func proxiedFrom(ctx context.Context) *proxied {
+	p, _ := ctx.Value(proxiedKey{}).(*proxied)
+	return p
+}
+
+// ServeHTTP implements http.Handler.
+func (g *Gateway) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	var route *Route
+	for i := range g.routes {
+		if g.routes[i].matches(r) {
+			route = &g.routes[i]
+			break
+		}
+	}
+	if route == nil {
+		http.Error(w, http.StatusText(http.StatusNotFound), http.StatusNotFound)
+		return
+	}
+
+	u, err := route.Pool.next()
+	if err != nil {
+		http.Error(w, http.StatusText(http.StatusServiceUnavailable), http.StatusServiceUnavailable)
+		return
+	}
+	ctx := context.WithValue(r.Context(), proxiedKey{}, &proxied{route: route, upstream: u})
+	g.proxy.ServeHTTP(w, r.WithContext(ctx))
+}
+
+func (g *Gateway) rewrite(pr *httputil.ProxyRequest) {
+	p := proxiedFrom(pr.In.Context())
+	if p.route.StripPrefix && p.route.Prefix != "" {
+		pr.Out.URL.Path = ensureLeadingSlash(strings.TrimPrefix(pr.Out.URL.Path, p.route.Prefix))
+		if pr.Out.URL.RawPath != "" {
+			pr.Out.URL.RawPath = ensureLeadingSlash(strings.TrimPrefix(pr.Out.URL.RawPath, p.route.Prefix))
+		}
+	}
+	pr.SetURL(p.upstream.url)
+	pr.SetXForwarded()
+	if p.route.Rewrite != nil {
+		p.route.Rewrite(pr)
+	}
+}
+
+func ensureLeadingSlash(path string) string {
+	if !strings.HasPrefix(path, "/") {
+		return "/" + path
+	}
+	return path
+}
+
+// roundTrip applies the request options of the route, and reports the result to the pool.
+func (g *Gateway) roundTrip(req *http.Request) (*http.Response, error) {
+	p := proxiedFrom(req.Context())
+	if len(p.route.RequestOptions) > 0 {
+		maxBytes := g.MaxBodyBytes
+		if maxBytes <= 0 {
+			maxBytes = defaultMaxBodyBytes
+		}
+		out, err := withRequestOptions(req, p.route.RequestOptions, maxBytes)
+		if err != nil {
+			return nil, err
+		}
+		req = out
+	}
+
+	transport := g.Transport
+	if transport == nil {
+		transport = http.DefaultTransport
+	}
+	resp, err := transport.RoundTrip(req)
+	switch {
+	case errors.Is(err, context.Canceled):
+		// requests canceled by clients are not failures of the upstream
+	case err != nil:
+		p.route.Pool.report(p.upstream, false)
+	default:
+		p.route.Pool.report(p.upstream, resp.StatusCode != http.StatusBadGateway &&
+			resp.StatusCode != http.StatusServiceUnavailable && resp.StatusCode != http.StatusGatewayTimeout)
+	}
+	return resp, err
+}
+
+// withRequestOptions returns a clone of req with opts applied. Its body is buffered up to maxBytes
+// when an option reads it with GetBody, and streamed otherwise.
+func withRequestOptions(req *http.Request, opts []sihttp.RequestOption, maxBytes int64) (*http.Request, error) {
+	out := req.Clone(req.Context())
+	if out.Body == nil || out.Body == http.NoBody || out.GetBody != nil {
+		return out, sihttp.ApplyRequestOptions(out, opts...)
+	}
+
+	var buffered bool
+	var body []byte
+	var bufErr error
+	out.GetBody = func() (io.ReadCloser, error) {
+		if !buffered {
+			buffered = true
+			body, bufErr = readLimited(out.Body, maxBytes)
+			out.Body.Close()
+			if bufErr == nil {
+				out.Body = io.NopCloser(bytes.NewReader(body))
+				out.ContentLength = int64(len(body))
+			}
+		}
+		if bufErr != nil {
+			return nil, bufErr
+		}
+		return io.NopCloser(bytes.NewReader(body)), nil
+	}
+
+	err := sihttp.ApplyRequestOptions(out, opts...)
+	if bufErr != nil {
+		// the body has been consumed
+		return nil, bufErr
+	}
+	if err != nil {
+		return nil, err
+	}
+	if !buffered {
+		// the transport must not buffer the body while sending it
+		out.GetBody = nil
+	}
+	return out, nil
+}
+
+// readLimited reads r up to n bytes, and fails with *http.MaxBytesError if it is larger.
+func readLimited(r io.Reader, n int64) ([]byte, error) {
+	b, err := io.ReadAll(io.LimitReader(r, n+1))
+	if err != nil {
+		return nil, err
+	}
+	if int64(len(b)) > n {
+		return nil, &http.MaxBytesError{Limit: n}
+	}
+	return b, nil
+}
+
+func (g *Gateway) modifyResponse(resp *http.Response) error {
+	p := proxiedFrom(resp.Request.Context())
+	if p.route.ModifyResponse == nil {
+		return nil
+	}
+	return p.route.ModifyResponse(resp)
+}
+
+func (g *Gateway) handleError(w http.ResponseWriter, r *http.Request, err error) {
+	if errors.Is(err, context.Canceled) {
+		// the client has gone
+		w.WriteHeader(http.StatusBadGateway)
+		return
+	}
+
+	var me *http.MaxBytesError
+	if errors.As(err, &me) {
+		http.Error(w, http.StatusText(http.StatusRequestEntityTooLarge), http.StatusRequestEntityTooLarge)
+		return
+	}
+
+	g.logf("proxy: %s %s: %v", r.Method, r.URL.Path, err)
+	status := http.StatusBadGateway
+	if errors.Is(err, context.DeadlineExceeded) {
+		status = http.StatusGatewayTimeout
+	}
+	http.Error(w, http.StatusText(status), status)
+}
+
+func (g *Gateway) logf(format string, args ...any) {
+	if g.ErrorLog != nil {
+		g.ErrorLog.Printf(format, args...)
+		return
+	}
+	log.Printf(format, args...)
+}
+
+type roundTripperFunc func(req *http.Request) (*http.Response, error)
+
+func (f roundTripperFunc) RoundTrip(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
@@ -0,0 +1,161 @@
+package proxy
+
+import (
+	"bytes"
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"net/http/httputil"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/wonksing/si/v2/sihttp"
+)
+
+// _upstream responds with its name and the path it received.
+func _upstream(t *testing.T, name string) *httptest.Server {
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-Upstream", name)
+		w.Write([]byte(name + " " + r.URL.RequestURI()))
+	}))
+	t.Cleanup(s.Close)
+	return s
+}
+
+func _pool(t *testing.T, targets ...Target) *Pool {
+	p, err := NewPool(targets)
+	require.Nil(t, err)
+	return p
+}
+
+func _do(t *testing.T, h http.Handler, req *http.Request) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, req)
+	return w
+}
+
+func TestGateway_Route(t *testing.T) {
+	users := _upstream(t, "users")
+	admin := _upstream(t, "admin")
+	books := _upstream(t, "books")
+
+	gw, err := NewGateway(
+		Route{Prefix: "/users/", Headers: map[string]string{"X-Admin": "true"}, Pool: _pool(t, Target{Url: admin.URL + "/admin"})},
+		Route{Prefix: "/users/", StripPrefix: true, Pool: _pool(t, Target{Url: users.URL + "/v1"})},
+		Route{Host: "*.books.local", Pool: _pool(t, Target{Url: books.URL})},
+	)
+	require.Nil(t, err)
+
+	w := _do(t, gw, httptest.NewRequest(http.MethodGet, "/users/1?a=b", nil))
+	assert.EqualValues(t, "users /v1/1?a=b", w.Body.String())
+
+	req := httptest.NewRequest(http.MethodGet, "/users/1", nil)
+	req.Header.Set("X-Admin", "true")
+	w = _do(t, gw, req)
+	assert.EqualValues(t, "admin /admin/users/1", w.Body.String())
+
+	req = httptest.NewRequest(http.MethodGet, "http://api.books.local:8080/books/1", nil)
+	w = _do(t, gw, req)
+	assert.EqualValues(t, "books /books/1", w.Body.String())
+
+	w = _do(t, gw, httptest.NewRequest(http.MethodGet, "http://books.local/books/1", nil))
+	assert.EqualValues(t, http.StatusNotFound, w.Code)
+
+	_, err = NewGateway(Route{Prefix: "/"})
+	require.NotNil(t, err)
+}
+
+func TestGateway_Hooks(t *testing.T) {
+	secret := []byte("secret")
+	var auth string
+	var received []byte
+	upstream := httptest.NewServer(sihttp.VerifyHeaderHmac256("X-Signature", secret)(http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			auth = r.Header.Get("Authorization")
+			received, _ = io.ReadAll(r.Body)
+			w.Write([]byte(r.Header.Get("X-Rewritten")))
+		})))
+	defer upstream.Close()
+
+	gw, err := NewGateway(Route{
+		Pool: _pool(t, Target{Url: upstream.URL}),
+		RequestOptions: []sihttp.RequestOption{
+			sihttp.WithBearerToken("token"),
+			sihttp.WithHeaderHmac256("X-Signature", secret),
+		},
+		Rewrite: func(r *httputil.ProxyRequest) {
+			r.Out.Header.Set("X-Rewritten", "yes")
+		},
+		ModifyResponse: func(resp *http.Response) error {
+			resp.Header.Set("X-Modified", "yes")
+			return nil
+		},
+	})
+	require.Nil(t, err)
+
+	w := _do(t, gw, httptest.NewRequest(http.MethodPost, "/books", bytes.NewBufferString(`{"title":"go"}`)))
+	require.EqualValues(t, http.StatusOK, w.Code)
+	assert.EqualValues(t, "yes", w.Body.String())
+	assert.EqualValues(t, "yes", w.Header().Get("X-Modified"))
+	assert.EqualValues(t, "Bearer token", auth)
+	assert.EqualValues(t, `{"title":"go"}`, string(received))
+}
+
+func TestGateway_RequestOptionsBody(t *testing.T) {
+	var buffered bool
+	var received []byte
+	transport := roundTripperFunc(func(req *http.Request) (*http.Response, error) {
+		buffered = req.GetBody != nil
+		received, _ = io.ReadAll(req.Body)
+		return &http.Response{StatusCode: http.StatusOK, Header: http.Header{}, Body: http.NoBody, Request: req}, nil
+	})
+	pool := _pool(t, Target{Url: "http://upstream"})
+
+	// the body is streamed to options that don't read it
+	gw, err := NewGateway(Route{Pool: pool, RequestOptions: []sihttp.RequestOption{sihttp.WithBearerToken("token")}})
+	require.Nil(t, err)
+	gw.Transport = transport
+	w := _do(t, gw, httptest.NewRequest(http.MethodPost, "/", bytes.NewBufferString("hello")))
+	assert.EqualValues(t, http.StatusOK, w.Code)
+	assert.False(t, buffered)
+	assert.EqualValues(t, "hello", string(received))
+
+	gw, err = NewGateway(Route{Pool: pool, RequestOptions: []sihttp.RequestOption{sihttp.WithHeaderHmac256("X-Signature", []byte("secret"))}})
+	require.Nil(t, err)
+	gw.Transport = transport
+	gw.MaxBodyBytes = 5
+	w = _do(t, gw, httptest.NewRequest(http.MethodPost, "/", bytes.NewBufferString("hello")))
+	assert.EqualValues(t, http.StatusOK, w.Code)
+	assert.True(t, buffered)
+	assert.EqualValues(t, "hello", string(received))
+
+	received = nil
+	w = _do(t, gw, httptest.NewRequest(http.MethodPost, "/", bytes.NewBufferString("hello world")))
+	assert.EqualValues(t, http.StatusRequestEntityTooLarge, w.Code)
+	assert.Nil(t, received)
+}
+
+func TestGateway_Pool(t *testing.T) {
+	a := _upstream(t, "a")
+	b := _upstream(t, "b")
+	down := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	down.Close()
+
+	pool := _pool(t, Target{Url: a.URL, Weight: 3}, Target{Url: b.URL}, Target{Url: down.URL})
+	gw, err := NewGateway(Route{Pool: pool})
+	require.Nil(t, err)
+	gw.ErrorLog = log.New(io.Discard, "", 0)
+
+	// the upstream that is down is ejected after 3 failures
+	counts := map[string]int{}
+	for i := 0; i < 20; i++ {
+		w := _do(t, gw, httptest.NewRequest(http.MethodGet, "/", nil))
+		counts[w.Header().Get("X-Upstream")+w.Result().Status]++
+	}
+	assert.EqualValues(t, 3, counts["502 Bad Gateway"])
+	assert.EqualValues(t, 2, pool.Healthy())
+	assert.EqualValues(t, 13, counts["a200 OK"])
+	assert.EqualValues(t, 4, counts["b200 OK"])
+}
@@ -0,0 +1,162 @@
+package proxy
+
+import (
+	"context"
+	"errors"
+	"net/url"
+	"sync"
+	"time"
+)
+
+const (
+	defaultMaxFails = 3
+	defaultEjectFor = 30 * time.Second
+)
+
+// ErrNoUpstream is returned when every upstream of a Pool is ejected.
+var ErrNoUpstream = errors.New("proxy: no healthy upstream")
+
+// Target is an upstream server of a Pool.
+type Target struct {
+	// Url is the base url of the upstream, whose path is prepended to paths of requests.
+	Url string
+	// Weight is the relative share of requests sent to the upstream. It is 1 if zero.
+	Weight int
+}
+
+// PoolOption is an option of NewPool.
+type PoolOption interface {
+	apply(p *Pool)
+}
+
+// PoolOptionFunc wraps a function to conforms to PoolOption interface.
+type PoolOptionFunc func(p *Pool)
+
+func (o PoolOptionFunc) apply(p *Pool) {
+	o(p)
+}
+
+// WithEjection ejects an upstream for d after maxFails consecutive failures, which are 3 and 30 seconds
+// by default. A failure is a transport error or a response of 502, 503 or 504.
+func WithEjection(maxFails int, d time.Duration) PoolOptionFunc {
+	return PoolOptionFunc(func(p *Pool) {
+		if maxFails > 0 {
+			p.maxFails = maxFails
+		}
+		if d > 0 {
+			p.ejectFor = d
+		}
+	})
+}
+
+type upstream struct {
+	url     *url.URL
+	weight  int
+	current int
+
+	fails        int
+	ejectedUntil time.Time
+}
+
+// Pool balances requests over upstreams by smooth weighted round robin, skipping ones ejected by
+// passive health checks on the responses proxied to them.
+type Pool struct {
+	mu        sync.Mutex
+	upstreams []*upstream
+	maxFails  int
+	ejectFor  time.Duration
+	now       func() time.Time
+}
+
+// NewPool returns a Pool of targets.
+func NewPool(targets []Target, opts ...PoolOption) (*Pool, error) {
+	if len(targets) == 0 {
+		return nil, errors.New("proxy: no target")
+	}
+	p := &Pool{maxFails: defaultMaxFails, ejectFor: defaultEjectFor, now: time.Now}
+	for _, t := range targets {
+		u, err := url.Parse(t.Url)
+		if err != nil {
+			return nil, err
+		}
+		if u.Scheme == "" || u.Host == "" {
+			return nil, errors.New("proxy: target url must be absolute: " + t.Url)
+		}
+		weight := t.Weight
+		if weight <= 0 {
+			weight = 1
+		}
+		p.upstreams = append(p.upstreams, &upstream{url: u, weight: weight})
+	}
+	for _, o := range opts {
+		if o == nil {
+			continue
+		}
+		o.apply(p)
+	}
+	return p, nil
+}
+
+// next picks an upstream among the healthy ones.
+func (p *Pool) next() (*upstream, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	now := p.now()
+	var best *upstream
+	total := 0
+	for _, u := range p.upstreams {
+		if now.Before(u.ejectedUntil) {
+			continue
+		}
+		u.current += u.weight
+		total += u.weight
+		if best == nil || u.current > best.current {
+			best = u
+		}
+	}
+	if best == nil {
+		return nil, ErrNoUpstream
+	}
+	best.current -= total
+	return best, nil
+}
+
+// report records the result of a request proxied to u.
+func (p *Pool) report(u *upstream, ok bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if ok {
+		u.fails = 0
+		return
+	}
+	u.fails++
+	if u.fails >= p.maxFails {
+		u.fails = 0
+		u.ejectedUntil = p.now().Add(p.ejectFor)
+	}
+}
+
+// Healthy returns the number of upstreams that are not ejected.
+func (p *Pool) Healthy() int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	now := p.now()
+	n := 0
+	for _, u := range p.upstreams {
+		if !now.Before(u.ejectedUntil) {
+			n++
+		}
+	}
+	return n
+}
+
+// HealthCheck implements sihttp.HealthChecker. It fails with ErrNoUpstream when every upstream is ejected.
+func (p *Pool) HealthCheck(_ context.Context) error {
+	if p.Healthy() == 0 {
+		return ErrNoUpstream
+	}
+	return nil
+}
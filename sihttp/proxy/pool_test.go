@@ -0,0 +1,53 @@
+package proxy
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPool(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	p, err := NewPool([]Target{{Url: "http://a", Weight: 2}, {Url: "http://b"}}, WithEjection(2, time.Minute))
+	require.Nil(t, err)
+	p.now = func() time.Time { return now }
+
+	// smooth weighted round robin
+	var hosts string
+	for i := 0; i < 6; i++ {
+		u, err := p.next()
+		require.Nil(t, err)
+		hosts += u.url.Host
+	}
+	assert.EqualValues(t, "abaaba", hosts)
+
+	a := p.upstreams[0]
+	b := p.upstreams[1]
+	p.report(b, false)
+	p.report(b, true)
+	p.report(b, false)
+	assert.EqualValues(t, 2, p.Healthy())
+	p.report(b, false)
+	assert.EqualValues(t, 1, p.Healthy())
+
+	p.report(a, false)
+	p.report(a, false)
+	_, err = p.next()
+	assert.ErrorIs(t, err, ErrNoUpstream)
+	assert.ErrorIs(t, p.HealthCheck(context.Background()), ErrNoUpstream)
+
+	// ejected upstreams come back
+	now = now.Add(time.Minute)
+	assert.EqualValues(t, 2, p.Healthy())
+	assert.Nil(t, p.HealthCheck(context.Background()))
+}
+
+func TestNewPool(t *testing.T) {
+	_, err := NewPool(nil)
+	require.NotNil(t, err)
+	_, err = NewPool([]Target{{Url: "/relative"}})
+	require.NotNil(t, err)
+}
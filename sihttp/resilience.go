@@ -0,0 +1,206 @@
+package sihttp
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/eapache/go-resiliency/breaker"
+)
+
+var (
+	// ErrCircuitOpen is returned without sending a request while the circuit breaker of its host is open.
+	ErrCircuitOpen = errors.New("circuit breaker is open")
+	// ErrBulkheadFull is returned when a request could not acquire a slot of the bulkhead of its host in time.
+	ErrBulkheadFull = errors.New("bulkhead is full")
+
+	errCircuitFailure = errors.New("circuit breaker failure")
+)
+
+// CircuitState is a state of a circuit breaker.
+type CircuitState uint32
+
+const (
+	CircuitClosed CircuitState = CircuitState(breaker.Closed)
+	CircuitOpen   CircuitState = CircuitState(breaker.Open)
+	// CircuitHalfOpen lets requests through to probe the host.
+	CircuitHalfOpen CircuitState = CircuitState(breaker.HalfOpen)
+)
+
+func (s CircuitState) String() string {
+	switch s {
+	case CircuitClosed:
+		return "closed"
+	case CircuitOpen:
+		return "open"
+	case CircuitHalfOpen:
+		return "half-open"
+	}
+	return "unknown"
+}
+
+// CircuitBreakerConfig configures circuit breakers that are created per host.
+type CircuitBreakerConfig struct {
+	// FailureThreshold is the number of failures, each within Timeout of the previous one, that opens the breaker.
+	FailureThreshold int
+	// SuccessThreshold is the number of consecutive successes in half-open state that closes the breaker.
+	// A single failure in half-open state opens it again.
+	SuccessThreshold int
+	// Timeout is how long the breaker stays open before it becomes half-open.
+	Timeout time.Duration
+	// IsFailure reports whether a result counts as a failure. Errors and 5xx responses are failures if nil.
+	IsFailure func(resp *http.Response, err error) bool
+	// OnStateChange is called when the state of the breaker of host changes.
+	// The change from open to half-open is reported by the first request after it.
+	OnStateChange func(host string, from, to CircuitState)
+}
+
+func defaultIsFailure(resp *http.Response, err error) bool {
+	return err != nil || (resp != nil && resp.StatusCode >= 500)
+}
+
+type hostBreaker struct {
+	host  string
+	b     *breaker.Breaker
+	state atomic.Uint32
+}
+
+type circuitBreakers struct {
+	conf     CircuitBreakerConfig
+	breakers sync.Map
+}
+
+func newCircuitBreakers(conf CircuitBreakerConfig) *circuitBreakers {
+	if conf.FailureThreshold <= 0 {
+		conf.FailureThreshold = 1
+	}
+	if conf.SuccessThreshold <= 0 {
+		conf.SuccessThreshold = 1
+	}
+	if conf.IsFailure == nil {
+		conf.IsFailure = defaultIsFailure
+	}
+	return &circuitBreakers{conf: conf}
+}
+
+func (cb *circuitBreakers) get(host string) *hostBreaker {
+	if hb, ok := cb.breakers.Load(host); ok {
+		return hb.(*hostBreaker)
+	}
+	hb, _ := cb.breakers.LoadOrStore(host, &hostBreaker{
+		host: host,
+		b:    breaker.New(cb.conf.FailureThreshold, cb.conf.SuccessThreshold, cb.conf.Timeout),
+	})
+	return hb.(*hostBreaker)
+}
+
+// State returns the state of the breaker of host.
+func (cb *circuitBreakers) State(host string) CircuitState {
+	return CircuitState(cb.get(host).b.GetState())
+}
+
+func (cb *circuitBreakers) do(req *http.Request, send func(*http.Request) (*http.Response, error)) (*http.Response, error) {
+	hb := cb.get(req.URL.Host)
+	cb.observe(hb)
+	defer cb.observe(hb)
+
+	var resp *http.Response
+	var err error
+	berr := hb.b.Run(func() error {
+		resp, err = send(req)
+		if cb.conf.IsFailure(resp, err) {
+			return errCircuitFailure
+		}
+		return nil
+	})
+	if errors.Is(berr, breaker.ErrBreakerOpen) {
+		return nil, ErrCircuitOpen
+	}
+	return resp, err
+}
+
+// observe reports a state change of hb since it was last observed.
+func (cb *circuitBreakers) observe(hb *hostBreaker) {
+	to := uint32(hb.b.GetState())
+	from := hb.state.Swap(to)
+	if from != to && cb.conf.OnStateChange != nil {
+		cb.conf.OnStateChange(hb.host, CircuitState(from), CircuitState(to))
+	}
+}
+
+// bulkheads limits the number of concurrent requests per host.
+type bulkheads struct {
+	maxConcurrent int
+	maxWait       time.Duration
+	slots         sync.Map
+}
+
+func newBulkheads(maxConcurrent int, maxWait time.Duration) *bulkheads {
+	return &bulkheads{maxConcurrent: maxConcurrent, maxWait: maxWait}
+}
+
+func (bh *bulkheads) get(host string) chan struct{} {
+	if s, ok := bh.slots.Load(host); ok {
+		return s.(chan struct{})
+	}
+	s, _ := bh.slots.LoadOrStore(host, make(chan struct{}, bh.maxConcurrent))
+	return s.(chan struct{})
+}
+
+// acquire waits up to maxWait for a slot of the host of req and returns a function to release it.
+func (bh *bulkheads) acquire(req *http.Request) (func(), error) {
+	slots := bh.get(req.URL.Host)
+	release := func() { <-slots }
+
+	select {
+	case slots <- struct{}{}:
+		return release, nil
+	default:
+	}
+	if bh.maxWait <= 0 {
+		return nil, ErrBulkheadFull
+	}
+
+	t := time.NewTimer(bh.maxWait)
+	defer t.Stop()
+	select {
+	case slots <- struct{}{}:
+		return release, nil
+	case <-t.C:
+		return nil, ErrBulkheadFull
+	case <-req.Context().Done():
+		return nil, req.Context().Err()
+	}
+}
+
+func (bh *bulkheads) do(req *http.Request, send func(*http.Request) (*http.Response, error)) (*http.Response, error) {
+	release, err := bh.acquire(req)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := send(req)
+	if err != nil || resp == nil || resp.Body == nil {
+		release()
+		return resp, err
+	}
+
+	// the slot is held until the body is closed
+	resp.Body = &releaseOnClose{ReadCloser: resp.Body, release: release}
+	return resp, nil
+}
+
+type releaseOnClose struct {
+	io.ReadCloser
+	once    sync.Once
+	release func()
+}
+
+func (r *releaseOnClose) Close() error {
+	err := r.ReadCloser.Close()
+	r.once.Do(r.release)
+	return err
+}
@@ -0,0 +1,115 @@
+package sihttp
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCircuitState_String(t *testing.T) {
+	assert.EqualValues(t, "closed", CircuitClosed.String())
+	assert.EqualValues(t, "open", CircuitOpen.String())
+	assert.EqualValues(t, "half-open", CircuitHalfOpen.String())
+	assert.EqualValues(t, "unknown", CircuitState(10).String())
+}
+
+func Test_Client_WithCircuitBreaker(t *testing.T) {
+	var failing atomic.Bool
+	failing.Store(true)
+	var count int32
+	svr := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&count, 1)
+		if failing.Load() {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		w.Write([]byte("ok"))
+	}))
+	defer svr.Close()
+	u, _ := url.Parse(svr.URL)
+
+	var mu sync.Mutex
+	var changes []string
+	c := NewClient(_newStandardClient(), WithCircuitBreaker(CircuitBreakerConfig{
+		FailureThreshold: 2,
+		SuccessThreshold: 1,
+		Timeout:          50 * time.Millisecond,
+		OnStateChange: func(host string, from, to CircuitState) {
+			mu.Lock()
+			defer mu.Unlock()
+			assert.EqualValues(t, u.Host, host)
+			changes = append(changes, from.String()+"->"+to.String())
+		},
+	}))
+
+	for i := 0; i < 2; i++ {
+		_, err := c.Get(svr.URL, nil, nil)
+		require.NotNil(t, err)
+		assert.NotErrorIs(t, err, ErrCircuitOpen)
+	}
+	assert.EqualValues(t, CircuitOpen, c.CircuitState(u.Host))
+
+	_, err := c.Get(svr.URL, nil, nil)
+	require.ErrorIs(t, err, ErrCircuitOpen)
+	assert.EqualValues(t, 2, atomic.LoadInt32(&count))
+
+	// half-open after timeout, then closed by a successful probe
+	failing.Store(false)
+	time.Sleep(100 * time.Millisecond)
+	assert.EqualValues(t, CircuitHalfOpen, c.CircuitState(u.Host))
+	res, err := c.Get(svr.URL, nil, nil)
+	require.Nil(t, err)
+	assert.EqualValues(t, "ok", string(res))
+	assert.EqualValues(t, CircuitClosed, c.CircuitState(u.Host))
+
+	mu.Lock()
+	assert.EqualValues(t, []string{"closed->open", "open->half-open", "half-open->closed"}, changes)
+	mu.Unlock()
+
+	assert.EqualValues(t, CircuitClosed, NewClient(nil).CircuitState(u.Host))
+}
+
+func Test_Client_WithBulkhead(t *testing.T) {
+	release := make(chan struct{})
+	svr := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-release
+		w.Write([]byte("ok"))
+	}))
+	defer svr.Close()
+
+	c := NewClient(_newStandardClient(), WithBulkhead(1, 20*time.Millisecond))
+
+	done := make(chan error)
+	go func() {
+		_, err := c.Get(svr.URL, nil, nil)
+		done <- err
+	}()
+	time.Sleep(50 * time.Millisecond)
+
+	_, err := c.Get(svr.URL, nil, nil)
+	require.ErrorIs(t, err, ErrBulkheadFull)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err = c.GetContext(ctx, svr.URL, nil, nil)
+	require.NotNil(t, err)
+
+	close(release)
+	require.Nil(t, <-done)
+
+	// slot is released after the body is closed
+	res, err := c.Get(svr.URL, nil, nil)
+	require.Nil(t, err)
+	assert.EqualValues(t, "ok", string(res))
+
+	c = NewClient(_newStandardClient(), WithBulkhead(0, 0))
+	require.Nil(t, c.bulkheads)
+}
@@ -0,0 +1,116 @@
+package sihttp
+
+import (
+	"encoding/xml"
+	"errors"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/wonksing/si/v2/sio"
+)
+
+var (
+	_jsonWriterOpts = []sio.WriterOption{sio.SetJsonEncoder()}
+	_xmlWriterOpts  = []sio.WriterOption{sio.WriterOptionFunc(func(w *sio.Writer) {
+		w.SetEncoder(xml.NewEncoder(w))
+	})}
+)
+
+// Respond writes v with status in json or xml, whichever r accepts, preferring json.
+// v is encoded with a pooled sio.Writer before anything is written, so that it can respond with 500
+// when v fails to encode, returning the error. Nothing but status is written if v is nil.
+func Respond(w http.ResponseWriter, r *http.Request, status int, v any) error {
+	if acceptsXml(r) {
+		return respond(w, status, "application/xml; charset=utf-8", _xmlWriterOpts, v)
+	}
+	return respond(w, status, "application/json; charset=utf-8", _jsonWriterOpts, v)
+}
+
+// RespondError writes err as problem details of RFC 7807 in json or xml, whichever r accepts.
+// Its status is 400 for *BindError, 415 for ErrUnsupportedMediaType, 413 for *http.MaxBytesError and
+// 422 for *sio.ValidationError, whose field errors are in "errors" member, or errors element of i elements
+// in xml. It is 500 otherwise, and err is not exposed.
+func RespondError(w http.ResponseWriter, r *http.Request, err error) error {
+	p := problemOf(err)
+	if acceptsXml(r) {
+		return respond(w, p.Status, "application/problem+xml; charset=utf-8", _xmlWriterOpts, p)
+	}
+	return respond(w, p.Status, "application/problem+json; charset=utf-8", _jsonWriterOpts, p)
+}
+
+func respond(w http.ResponseWriter, status int, contentType string, opts []sio.WriterOption, v any) error {
+	if v == nil {
+		w.WriteHeader(status)
+		return nil
+	}
+
+	sw, buf := sio.GetWriterAndBuffer(opts...)
+	defer sio.PutWriterAndBuffer(sw, buf)
+	if err := sw.EncodeFlush(v); err != nil {
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return err
+	}
+
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("Content-Length", strconv.Itoa(buf.Len()))
+	w.WriteHeader(status)
+	_, err := w.Write(buf.Bytes())
+	return err
+}
+
+func problemOf(err error) *Problem {
+	p := &Problem{Status: http.StatusInternalServerError}
+
+	var ve *sio.ValidationError
+	var be *BindError
+	var me *http.MaxBytesError
+	switch {
+	case errors.As(err, &ve):
+		p.Status = http.StatusUnprocessableEntity
+		p.Extensions = map[string]any{"errors": ve.Errors}
+	case errors.Is(err, ErrUnsupportedMediaType):
+		p.Status = http.StatusUnsupportedMediaType
+	case errors.As(err, &me):
+		p.Status = http.StatusRequestEntityTooLarge
+	case errors.As(err, &be):
+		p.Status = http.StatusBadRequest
+	}
+	p.Title = http.StatusText(p.Status)
+	if p.Status < http.StatusInternalServerError {
+		p.Detail = err.Error()
+	}
+	return p
+}
+
+// acceptsXml reports whether Accept header of r prefers xml to json.
+func acceptsXml(r *http.Request) bool {
+	if r == nil {
+		return false
+	}
+	var jsonQ, xmlQ float64 = -1, -1
+	for _, accept := range r.Header.Values("Accept") {
+		for _, mediaRange := range strings.Split(accept, ",") {
+			mediaType, params, err := mime.ParseMediaType(mediaRange)
+			if err != nil {
+				continue
+			}
+			q := 1.0
+			if s, ok := params["q"]; ok {
+				if q, err = strconv.ParseFloat(s, 64); err != nil {
+					continue
+				}
+			}
+
+			switch {
+			case mediaType == "application/xml" || mediaType == "text/xml" || strings.HasSuffix(mediaType, "+xml"):
+				xmlQ = max(xmlQ, q)
+			case mediaType == "application/json" || strings.HasSuffix(mediaType, "+json") ||
+				mediaType == "application/*" || mediaType == "*/*":
+				jsonQ = max(jsonQ, q)
+			}
+		}
+	}
+	return xmlQ > 0 && xmlQ > jsonQ
+}
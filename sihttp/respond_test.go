@@ -0,0 +1,93 @@
+package sihttp
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/wonksing/si/v2/codec"
+	"github.com/wonksing/si/v2/sio"
+)
+
+type _respondBook struct {
+	Id    int    `json:"id" xml:"id"`
+	Title string `json:"title" xml:"title"`
+}
+
+func TestRespond(t *testing.T) {
+	book := _respondBook{Id: 1, Title: "go"}
+	tests := []struct {
+		accept      string
+		contentType string
+		body        string
+	}{
+		{"", "application/json; charset=utf-8", `{"id":1,"title":"go"}` + "\n"},
+		{"*/*", "application/json; charset=utf-8", `{"id":1,"title":"go"}` + "\n"},
+		{"application/xml", "application/xml; charset=utf-8", `<_respondBook><id>1</id><title>go</title></_respondBook>`},
+		{"text/xml;q=0.9, application/json;q=0.8", "application/xml; charset=utf-8", `<_respondBook><id>1</id><title>go</title></_respondBook>`},
+		{"application/xml;q=0.5, */*", "application/json; charset=utf-8", `{"id":1,"title":"go"}` + "\n"},
+		{"text/html", "application/json; charset=utf-8", `{"id":1,"title":"go"}` + "\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.accept, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			req.Header.Set("Accept", tt.accept)
+			w := httptest.NewRecorder()
+			require.Nil(t, Respond(w, req, http.StatusCreated, book))
+			assert.EqualValues(t, http.StatusCreated, w.Code)
+			assert.EqualValues(t, tt.contentType, w.Header().Get("Content-Type"))
+			assert.EqualValues(t, tt.body, w.Body.String())
+		})
+	}
+
+	w := httptest.NewRecorder()
+	require.Nil(t, Respond(w, nil, http.StatusNoContent, nil))
+	assert.EqualValues(t, http.StatusNoContent, w.Code)
+	assert.Empty(t, w.Body.String())
+
+	w = httptest.NewRecorder()
+	require.NotNil(t, Respond(w, nil, http.StatusOK, make(chan int)))
+	assert.EqualValues(t, http.StatusInternalServerError, w.Code)
+}
+
+func TestRespondError(t *testing.T) {
+	req := httptest.NewRequest(http.MethodGet, "/books/1", nil)
+	_, err := Bind[_bindBook](req)
+	require.NotNil(t, err)
+
+	w := httptest.NewRecorder()
+	require.Nil(t, RespondError(w, req, err))
+	assert.EqualValues(t, http.StatusUnprocessableEntity, w.Code)
+	assert.EqualValues(t, "application/problem+json; charset=utf-8", w.Header().Get("Content-Type"))
+	p := decodeProblem(w.Result(), w.Body.Bytes())
+	require.NotNil(t, p)
+	assert.EqualValues(t, http.StatusUnprocessableEntity, p.Status)
+	assert.EqualValues(t, "Unprocessable Entity", p.Title)
+	fields := p.Extensions["errors"].([]any)
+	assert.EqualValues(t, "title", fields[0].(map[string]any)["field"])
+
+	w = httptest.NewRecorder()
+	require.Nil(t, RespondError(w, req, &BindError{Source: "query", Field: "page", Err: errors.New("invalid")}))
+	assert.EqualValues(t, http.StatusBadRequest, w.Code)
+	m, _ := codec.Unmarshal[map[string]any](codec.FormatJson, w.Body.Bytes())
+	assert.EqualValues(t, `bind query "page": invalid`, m["detail"])
+
+	// field errors in xml
+	req.Header.Set("Accept", "application/problem+xml")
+	w = httptest.NewRecorder()
+	require.Nil(t, RespondError(w, req, &sio.ValidationError{Errors: []sio.FieldError{{Field: "title", Tag: "required", Message: "title is required"}}}))
+	assert.EqualValues(t, http.StatusUnprocessableEntity, w.Code)
+	assert.EqualValues(t, `<problem xmlns="urn:ietf:rfc:7807"><title>Unprocessable Entity</title><status>422</status>`+
+		`<detail>validation failed: title is required</detail>`+
+		`<errors><i><field>title</field><tag>required</tag><message>title is required</message></i></errors></problem>`, w.Body.String())
+
+	// internal errors are not exposed
+	w = httptest.NewRecorder()
+	require.Nil(t, RespondError(w, req, errors.New("db is down")))
+	assert.EqualValues(t, http.StatusInternalServerError, w.Code)
+	assert.EqualValues(t, "application/problem+xml; charset=utf-8", w.Header().Get("Content-Type"))
+	assert.EqualValues(t, `<problem xmlns="urn:ietf:rfc:7807"><title>Internal Server Error</title><status>500</status></problem>`, w.Body.String())
+}
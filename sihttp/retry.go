@@ -0,0 +1,236 @@
+package sihttp
+
+import (
+	"context"
+	"errors"
+	"io"
+	"math"
+	"math/rand"
+	"net"
+	"net/http"
+	"strconv"
+	"syscall"
+	"time"
+)
+
+// RetryPolicy decides whether a request should be sent again and how long to wait before it.
+//
+// attempt is the number of attempts made so far, starting from 1, and elapsed is the time since the first attempt.
+// Either resp or err of the last attempt is nil.
+type RetryPolicy interface {
+	Retry(req *http.Request, resp *http.Response, err error, attempt int, elapsed time.Duration) (time.Duration, bool)
+}
+
+// RetryPolicyFunc wraps a function to conforms to RetryPolicy interface.
+type RetryPolicyFunc func(req *http.Request, resp *http.Response, err error, attempt int, elapsed time.Duration) (time.Duration, bool)
+
+// Retry implements RetryPolicy's Retry method.
+func (f RetryPolicyFunc) Retry(req *http.Request, resp *http.Response, err error, attempt int, elapsed time.Duration) (time.Duration, bool) {
+	return f(req, resp, err, attempt, elapsed)
+}
+
+// RetryClassifier reports whether the result of an attempt is retryable.
+type RetryClassifier func(resp *http.Response, err error) bool
+
+// DefaultRetryClassifier retries timeouts, refused or reset connections, unexpected EOFs,
+// and 429, 502, 503 and 504 responses.
+func DefaultRetryClassifier(resp *http.Response, err error) bool {
+	if err != nil {
+		if errors.Is(err, context.Canceled) {
+			return false
+		}
+		var ne net.Error
+		if errors.As(err, &ne) && ne.Timeout() {
+			return true
+		}
+		return errors.Is(err, syscall.ECONNRESET) ||
+			errors.Is(err, syscall.ECONNREFUSED) ||
+			errors.Is(err, io.EOF) ||
+			errors.Is(err, io.ErrUnexpectedEOF)
+	}
+
+	if resp == nil {
+		return false
+	}
+	switch resp.StatusCode {
+	case http.StatusTooManyRequests, http.StatusBadGateway,
+		http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return true
+	}
+	return false
+}
+
+const (
+	defaultRetryInitialInterval = 100 * time.Millisecond
+	defaultRetryMaxInterval     = 10 * time.Second
+	defaultRetryMultiplier      = 2
+	defaultRetryJitter          = 0.5
+)
+
+// BackoffRetryPolicy retries with exponentially growing intervals.
+//
+// The interval before n-th retry is InitialInterval * Multiplier^(n-1), capped by MaxInterval,
+// then randomized by ±Jitter. Retry-After header of 429 and 503 responses takes precedence over it,
+// capped by MaxInterval as well.
+type BackoffRetryPolicy struct {
+	// MaxRetries is the number of retries after the first attempt.
+	MaxRetries      int
+	InitialInterval time.Duration
+	MaxInterval     time.Duration
+	Multiplier      float64
+	// Jitter is a randomization factor between 0 and 1.
+	Jitter float64
+	// MaxElapsedTime stops retrying when the next attempt would start after it. Zero means no limit.
+	MaxElapsedTime time.Duration
+	// RetryNonIdempotent allows to retry POST and PATCH requests without Idempotency-Key header.
+	RetryNonIdempotent bool
+	// Classifier reports whether a result is retryable. DefaultRetryClassifier is used if nil.
+	Classifier RetryClassifier
+}
+
+// NewBackoffRetryPolicy returns BackoffRetryPolicy with maxRetries and default intervals.
+func NewBackoffRetryPolicy(maxRetries int) *BackoffRetryPolicy {
+	return &BackoffRetryPolicy{
+		MaxRetries:      maxRetries,
+		InitialInterval: defaultRetryInitialInterval,
+		MaxInterval:     defaultRetryMaxInterval,
+		Multiplier:      defaultRetryMultiplier,
+		Jitter:          defaultRetryJitter,
+		Classifier:      DefaultRetryClassifier,
+	}
+}
+
+// Retry implements RetryPolicy's Retry method.
+func (p *BackoffRetryPolicy) Retry(req *http.Request, resp *http.Response, err error, attempt int, elapsed time.Duration) (time.Duration, bool) {
+	if attempt > p.MaxRetries {
+		return 0, false
+	}
+	if !p.RetryNonIdempotent && !isIdempotent(req) {
+		return 0, false
+	}
+
+	classifier := p.Classifier
+	if classifier == nil {
+		classifier = DefaultRetryClassifier
+	}
+	if !classifier(resp, err) {
+		return 0, false
+	}
+
+	wait, ok := retryAfter(resp, time.Now())
+	if !ok {
+		wait = p.backoff(attempt)
+	} else if p.MaxInterval > 0 && wait > p.MaxInterval {
+		// a server must not stall the client longer than it is willing to wait
+		wait = p.MaxInterval
+	}
+	if p.MaxElapsedTime > 0 && elapsed+wait > p.MaxElapsedTime {
+		return 0, false
+	}
+	return wait, true
+}
+
+func (p *BackoffRetryPolicy) backoff(attempt int) time.Duration {
+	multiplier := p.Multiplier
+	if multiplier < 1 {
+		multiplier = 1
+	}
+
+	interval := float64(p.InitialInterval) * math.Pow(multiplier, float64(attempt-1))
+	if p.MaxInterval > 0 && interval > float64(p.MaxInterval) {
+		interval = float64(p.MaxInterval)
+	}
+
+	if p.Jitter > 0 {
+		delta := p.Jitter * interval
+		interval = interval - delta + rand.Float64()*(2*delta)
+	}
+	return time.Duration(interval)
+}
+
+// isIdempotent reports whether req can be sent more than once without side effects.
+func isIdempotent(req *http.Request) bool {
+	switch req.Method {
+	case "", http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodTrace,
+		http.MethodPut, http.MethodDelete:
+		return true
+	}
+	if _, ok := req.Header["Idempotency-Key"]; ok {
+		return true
+	}
+	if _, ok := req.Header["X-Idempotency-Key"]; ok {
+		return true
+	}
+	return false
+}
+
+// retryAfter parses Retry-After header of 429 and 503 responses, which is either seconds or an http date.
+func retryAfter(resp *http.Response, now time.Time) (time.Duration, bool) {
+	if resp == nil {
+		return 0, false
+	}
+	if resp.StatusCode != http.StatusTooManyRequests && resp.StatusCode != http.StatusServiceUnavailable {
+		return 0, false
+	}
+
+	v := resp.Header.Get("Retry-After")
+	if v == "" {
+		return 0, false
+	}
+	if sec, err := strconv.Atoi(v); err == nil {
+		if sec < 0 {
+			return 0, false
+		}
+		return time.Duration(sec) * time.Second, true
+	}
+	if t, err := http.ParseTime(v); err == nil {
+		d := t.Sub(now)
+		if d < 0 {
+			d = 0
+		}
+		return d, true
+	}
+	return 0, false
+}
+
+// rewindRequest returns a copy of req with a fresh body from req.GetBody so that it can be sent again.
+// It reports false if the body cannot be rebuilt.
+func rewindRequest(req *http.Request) (*http.Request, bool) {
+	if req.Body == nil || req.Body == http.NoBody {
+		return req, true
+	}
+	if req.GetBody == nil {
+		return nil, false
+	}
+
+	body, err := req.GetBody()
+	if err != nil {
+		return nil, false
+	}
+	r := req.Clone(req.Context())
+	r.Body = body
+	return r, true
+}
+
+// drainBody reads a bounded amount of the body so that the connection can be reused, then closes it.
+func drainBody(resp *http.Response) {
+	if resp == nil || resp.Body == nil {
+		return
+	}
+	_, _ = io.CopyN(io.Discard, resp.Body, 4096)
+	resp.Body.Close()
+}
+
+func sleepContext(ctx context.Context, d time.Duration) error {
+	if d <= 0 {
+		return ctx.Err()
+	}
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-t.C:
+		return nil
+	}
+}
@@ -0,0 +1,229 @@
+package sihttp
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type _timeoutError struct{}
+
+func (e _timeoutError) Error() string   { return "timeout" }
+func (e _timeoutError) Timeout() bool   { return true }
+func (e _timeoutError) Temporary() bool { return true }
+
+func Test_DefaultRetryClassifier(t *testing.T) {
+	assert.True(t, DefaultRetryClassifier(nil, _timeoutError{}))
+	assert.True(t, DefaultRetryClassifier(nil, syscall.ECONNRESET))
+	assert.True(t, DefaultRetryClassifier(nil, io.ErrUnexpectedEOF))
+	assert.False(t, DefaultRetryClassifier(nil, context.Canceled))
+	assert.False(t, DefaultRetryClassifier(nil, errors.New("just error")))
+	assert.False(t, DefaultRetryClassifier(nil, nil))
+
+	for _, code := range []int{429, 502, 503, 504} {
+		assert.True(t, DefaultRetryClassifier(&http.Response{StatusCode: code}, nil))
+	}
+	for _, code := range []int{200, 400, 401, 500} {
+		assert.False(t, DefaultRetryClassifier(&http.Response{StatusCode: code}, nil))
+	}
+}
+
+func TestBackoffRetryPolicy_Retry(t *testing.T) {
+	get, _ := http.NewRequest(http.MethodGet, "/", nil)
+	post, _ := http.NewRequest(http.MethodPost, "/", nil)
+	unavailable := &http.Response{StatusCode: http.StatusServiceUnavailable, Header: http.Header{}}
+
+	t.Run("max-retries", func(t *testing.T) {
+		p := NewBackoffRetryPolicy(2)
+		_, ok := p.Retry(get, unavailable, nil, 1, 0)
+		assert.True(t, ok)
+		_, ok = p.Retry(get, unavailable, nil, 2, 0)
+		assert.True(t, ok)
+		_, ok = p.Retry(get, unavailable, nil, 3, 0)
+		assert.False(t, ok)
+	})
+
+	t.Run("backoff", func(t *testing.T) {
+		p := NewBackoffRetryPolicy(10)
+		p.Jitter = 0
+		p.MaxInterval = 300 * time.Millisecond
+
+		wait, _ := p.Retry(get, unavailable, nil, 1, 0)
+		assert.EqualValues(t, 100*time.Millisecond, wait)
+		wait, _ = p.Retry(get, unavailable, nil, 2, 0)
+		assert.EqualValues(t, 200*time.Millisecond, wait)
+		wait, _ = p.Retry(get, unavailable, nil, 3, 0)
+		assert.EqualValues(t, 300*time.Millisecond, wait)
+	})
+
+	t.Run("jitter", func(t *testing.T) {
+		p := NewBackoffRetryPolicy(10)
+		for i := 0; i < 100; i++ {
+			wait, _ := p.Retry(get, unavailable, nil, 1, 0)
+			assert.GreaterOrEqual(t, wait, 50*time.Millisecond)
+			assert.LessOrEqual(t, wait, 150*time.Millisecond)
+		}
+	})
+
+	t.Run("retry-after", func(t *testing.T) {
+		p := NewBackoffRetryPolicy(1)
+		resp := &http.Response{StatusCode: http.StatusTooManyRequests, Header: http.Header{}}
+		resp.Header.Set("Retry-After", "3")
+		wait, ok := p.Retry(get, resp, nil, 1, 0)
+		assert.True(t, ok)
+		assert.EqualValues(t, 3*time.Second, wait)
+
+		resp.Header.Set("Retry-After", "3600")
+		wait, ok = p.Retry(get, resp, nil, 1, 0)
+		assert.True(t, ok)
+		assert.EqualValues(t, p.MaxInterval, wait)
+	})
+
+	t.Run("max-elapsed-time", func(t *testing.T) {
+		p := NewBackoffRetryPolicy(10)
+		p.Jitter = 0
+		p.MaxElapsedTime = time.Second
+		_, ok := p.Retry(get, unavailable, nil, 1, 800*time.Millisecond)
+		assert.True(t, ok)
+		_, ok = p.Retry(get, unavailable, nil, 1, 950*time.Millisecond)
+		assert.False(t, ok)
+	})
+
+	t.Run("idempotent", func(t *testing.T) {
+		p := NewBackoffRetryPolicy(1)
+		_, ok := p.Retry(post, unavailable, nil, 1, 0)
+		assert.False(t, ok)
+
+		post.Header.Set("Idempotency-Key", "1234")
+		_, ok = p.Retry(post, unavailable, nil, 1, 0)
+		assert.True(t, ok)
+		post.Header.Del("Idempotency-Key")
+
+		p.RetryNonIdempotent = true
+		_, ok = p.Retry(post, unavailable, nil, 1, 0)
+		assert.True(t, ok)
+	})
+
+	t.Run("classifier", func(t *testing.T) {
+		p := NewBackoffRetryPolicy(1)
+		p.Classifier = func(resp *http.Response, err error) bool {
+			return resp != nil && resp.StatusCode == http.StatusInternalServerError
+		}
+		_, ok := p.Retry(get, unavailable, nil, 1, 0)
+		assert.False(t, ok)
+		_, ok = p.Retry(get, &http.Response{StatusCode: http.StatusInternalServerError}, nil, 1, 0)
+		assert.True(t, ok)
+	})
+}
+
+func Test_retryAfter(t *testing.T) {
+	now := time.Date(2022, 5, 1, 0, 0, 0, 0, time.UTC)
+	resp := &http.Response{StatusCode: http.StatusServiceUnavailable, Header: http.Header{}}
+
+	_, ok := retryAfter(resp, now)
+	assert.False(t, ok)
+
+	resp.Header.Set("Retry-After", now.Add(5*time.Second).Format(http.TimeFormat))
+	d, ok := retryAfter(resp, now)
+	assert.True(t, ok)
+	assert.EqualValues(t, 5*time.Second, d)
+
+	resp.Header.Set("Retry-After", "asdf")
+	_, ok = retryAfter(resp, now)
+	assert.False(t, ok)
+
+	resp.StatusCode = http.StatusBadGateway
+	resp.Header.Set("Retry-After", "1")
+	_, ok = retryAfter(resp, now)
+	assert.False(t, ok)
+}
+
+func _fastRetryPolicy(maxRetries int) *BackoffRetryPolicy {
+	p := NewBackoffRetryPolicy(maxRetries)
+	p.InitialInterval = time.Millisecond
+	p.RetryNonIdempotent = true
+	return p
+}
+
+func Test_Client_Retry(t *testing.T) {
+	t.Run("rebuild-body", func(t *testing.T) {
+		var count int32
+		svr := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			b, _ := io.ReadAll(r.Body)
+			if atomic.AddInt32(&count, 1) < 3 {
+				w.WriteHeader(http.StatusServiceUnavailable)
+				return
+			}
+			w.Write(b)
+		}))
+		defer svr.Close()
+
+		c := NewClient(_newStandardClient(), WithRetryPolicy(_fastRetryPolicy(3)))
+		res, err := c.Post(svr.URL, nil, []byte("hello world"))
+		require.Nil(t, err)
+		assert.EqualValues(t, "hello world", string(res))
+		assert.EqualValues(t, 3, atomic.LoadInt32(&count))
+	})
+
+	t.Run("exhausted", func(t *testing.T) {
+		var count int32
+		svr := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			atomic.AddInt32(&count, 1)
+			w.WriteHeader(http.StatusBadGateway)
+		}))
+		defer svr.Close()
+
+		c := NewClient(_newStandardClient(), WithRetryPolicy(_fastRetryPolicy(2)))
+		_, err := c.Get(svr.URL, nil, nil)
+		require.NotNil(t, err)
+		assert.EqualValues(t, http.StatusBadGateway, err.(*Error).GetStatusCode(0))
+		assert.EqualValues(t, 3, atomic.LoadInt32(&count))
+	})
+
+	t.Run("unrewindable-body", func(t *testing.T) {
+		var count int32
+		svr := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			atomic.AddInt32(&count, 1)
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}))
+		defer svr.Close()
+
+		c := NewClient(_newStandardClient(), WithRetryPolicy(_fastRetryPolicy(2)))
+		_, err := c.Post(svr.URL, nil, io.NopCloser(strings.NewReader("hello world")))
+		require.NotNil(t, err)
+		assert.EqualValues(t, 1, atomic.LoadInt32(&count))
+	})
+
+	t.Run("context-canceled", func(t *testing.T) {
+		svr := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Retry-After", "10")
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}))
+		defer svr.Close()
+
+		c := NewClient(_newStandardClient(), WithRetryPolicy(_fastRetryPolicy(2)))
+		ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+		defer cancel()
+		_, err := c.GetContext(ctx, svr.URL, nil, nil)
+		require.ErrorIs(t, err, context.DeadlineExceeded)
+	})
+
+	t.Run("retry-attempts", func(t *testing.T) {
+		c := NewClient(_newStandardClient(), WithRetryAttempts(2))
+		require.NotNil(t, c.retryPolicy)
+		assert.EqualValues(t, 2, c.retryPolicy.(*BackoffRetryPolicy).MaxRetries)
+
+		c = NewClient(_newStandardClient(), WithRetryAttempts(0))
+		require.Nil(t, c.retryPolicy)
+	})
+}
@@ -3,26 +3,132 @@ package sihttp
 import (
 	"context"
 	"crypto/tls"
+	"errors"
 	"net/http"
+	"os"
+	"os/signal"
+	"sync"
+	"sync/atomic"
+	"syscall"
 	"time"
 
-	"github.com/gorilla/handlers"
+	"github.com/wonksing/si/v2/sihttp/middleware"
+	"golang.org/x/net/http2"
+	"golang.org/x/net/http2/h2c"
 )
 
+const defaultShutdownTimeout = 30 * time.Second
+
 type Server struct {
 	TLSConf *tls.Config
 	Server  *http.Server
 	pem     string
 	key     string
+
+	// DrainDelay is how long to keep serving after readiness is turned off on shutdown,
+	// so that load balancers stop sending new requests before the listeners are closed.
+	DrainDelay time.Duration
+	// ShutdownTimeout is how long to wait for in-flight requests on shutdown before connections are closed.
+	// It is 30 seconds if zero.
+	ShutdownTimeout time.Duration
+
+	middlewares []middleware.Middleware
+	health      *Health
+	metrics     *ServerMetrics
+
+	http2 bool
+	h2c   bool
+	// configErr is an error configuring the server, returned by Start.
+	configErr error
+
+	draining atomic.Bool
+	hooks    sync.WaitGroup
 }
 
+// NewServer returns Server of handler listening on addr. See NewServerWithOptions for other options.
 func NewServer(handler http.Handler, tlsConfig *tls.Config,
 	addr string, writeTimeout, readTimeout time.Duration) *Server {
 
-	return NewServerCors(handler, tlsConfig, addr, writeTimeout, readTimeout, "", "", nil, nil, nil)
+	return NewServerWithOptions(handler,
+		WithAddr(addr),
+		WithTLSConfig(tlsConfig),
+		WithWriteTimeout(writeTimeout),
+		WithReadTimeout(readTimeout),
+	)
+}
+
+// NewServerWithOptions returns Server of handler configured by opts. An error of opts is returned by Start.
+//
+//	s := sihttp.NewServerWithOptions(mux,
+//		sihttp.WithAddr(":8080"),
+//		sihttp.WithReadTimeout(15*time.Second),
+//		sihttp.WithServerMiddleware(middleware.RequestID(""), middleware.AccessLog(nil), middleware.Recover(nil)),
+//	)
+func NewServerWithOptions(handler http.Handler, opts ...ServerOption) *Server {
+	hs := &Server{}
+	hs.Server = &http.Server{
+		TLSNextProto: make(map[string]func(*http.Server, *tls.Conn, http.Handler), 0),
+	}
+	for _, o := range opts {
+		if o == nil {
+			continue
+		}
+		if err := o.apply(hs); err != nil && hs.configErr == nil {
+			hs.configErr = err
+		}
+	}
+	handler = hs.probes(middleware.Chain(handler, hs.middlewares...))
+
+	if hs.http2 {
+		// ConfigureServer also makes Shutdown send GOAWAY to h2c connections served by h2s
+		h2s := &http2.Server{}
+		hs.Server.TLSNextProto = nil
+		if err := http2.ConfigureServer(hs.Server, h2s); err != nil && hs.configErr == nil {
+			hs.configErr = err
+		}
+		if hs.h2c {
+			handler = h2c.NewHandler(handler, h2s)
+		}
+	}
+	hs.Server.Handler = handler
+
+	return hs
+}
 
+// probes serves HealthzPath, ReadyzPath and MetricsPath if they are enabled, and next otherwise.
+// Probes are not handled by the middlewares of hs.
+func (hs *Server) probes(next http.Handler) http.Handler {
+	if hs.metrics != nil {
+		next = hs.metrics.middleware(next)
+	}
+	if hs.health == nil && hs.metrics == nil {
+		return next
+	}
+
+	var healthz, readyz http.Handler
+	if hs.health != nil {
+		healthz = hs.health.Handler()
+		readyz = hs.health.ReadinessHandler(hs.Ready)
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodGet || r.Method == http.MethodHead {
+			switch {
+			case r.URL.Path == HealthzPath && healthz != nil:
+				healthz.ServeHTTP(w, r)
+				return
+			case r.URL.Path == ReadyzPath && readyz != nil:
+				readyz.ServeHTTP(w, r)
+				return
+			case r.URL.Path == MetricsPath && hs.metrics != nil:
+				hs.metrics.ServeHTTP(w, r)
+				return
+			}
+		}
+		next.ServeHTTP(w, r)
+	})
 }
 
+// Deprecated: use NewServerWithOptions with WithTLSConfig and WithCertificate.
 func NewServerTls(handler http.Handler, tlsConfig *tls.Config,
 	addr string, writeTimeout, readTimeout time.Duration,
 	pem string, key string) *Server {
@@ -30,42 +136,32 @@ func NewServerTls(handler http.Handler, tlsConfig *tls.Config,
 	return NewServerCors(handler, tlsConfig, addr, writeTimeout, readTimeout, pem, key, nil, nil, nil)
 
 }
+
+// Deprecated: use NewServerWithOptions with WithCors.
 func NewServerCors(handler http.Handler, tlsConfig *tls.Config,
 	addr string, writeTimeout, readTimeout time.Duration,
 	pem string, key string,
 	allowedOrigins, allowedHeaders, allowedMethods []string) *Server {
 
-	var hs Server
-	hs.TLSConf = tlsConfig
-	hs.Server = &http.Server{
-		Addr:         addr,
-		TLSConfig:    hs.TLSConf,
-		TLSNextProto: make(map[string]func(*http.Server, *tls.Conn, http.Handler), 0),
-		WriteTimeout: writeTimeout,
-		ReadTimeout:  readTimeout,
-	}
-	hs.pem = pem
-	hs.key = key
-
-	if len(allowedOrigins) == 0 && len(allowedHeaders) == 0 && len(allowedMethods) == 0 {
-		hs.Server.Handler = handler
-	} else {
-		corsOrigin := handlers.AllowedOrigins(allowedOrigins)
-		corsHeaders := handlers.AllowedHeaders(allowedHeaders)
-		corsMethods := handlers.AllowedMethods(allowedMethods)
-		// nr := handlers.CORS(corsOrigin, corsHeaders, corsMethods)(hs.Router)
-		cors := handlers.CORS(corsOrigin, corsHeaders, corsMethods)
-		corsHandler := cors(handler)
-
-		hs.Server.Handler = corsHandler
-	}
-
-	return &hs
+	return NewServerWithOptions(handler,
+		WithAddr(addr),
+		WithTLSConfig(tlsConfig),
+		WithCertificate(pem, key),
+		WithWriteTimeout(writeTimeout),
+		WithReadTimeout(readTimeout),
+		WithCors(allowedOrigins, allowedHeaders, allowedMethods),
+	)
 }
 
+// Start listens and serves. It serves TLS if the certificate files are set by WithCertificate,
+// or the tls.Config of WithTLSConfig has certificates, for example of sitls.WithReloadingCertificate.
 func (hs *Server) Start() error {
+	if hs.configErr != nil {
+		return hs.configErr
+	}
+
 	var err error
-	if len(hs.pem) > 0 || len(hs.key) > 0 {
+	if len(hs.pem) > 0 || len(hs.key) > 0 || hasCertificate(hs.TLSConf) {
 		err = hs.Server.ListenAndServeTLS(hs.pem, hs.key)
 	} else {
 		err = hs.Server.ListenAndServe()
@@ -73,10 +169,96 @@ func (hs *Server) Start() error {
 	return err
 }
 
+func hasCertificate(c *tls.Config) bool {
+	return c != nil && (len(c.Certificates) > 0 || c.GetCertificate != nil || c.GetConfigForClient != nil)
+}
+
+// Run starts the server and shuts it down gracefully when ctx is done or the process receives SIGTERM or SIGINT.
+// See Stop for how it shuts down. It returns nil if the server has shut down gracefully.
+func (hs *Server) Run(ctx context.Context) error {
+	ctx, stop := signal.NotifyContext(ctx, syscall.SIGTERM, os.Interrupt)
+	defer stop()
+
+	errc := make(chan error, 1)
+	go func() {
+		errc <- hs.Start()
+	}()
+
+	select {
+	case err := <-errc:
+		if errors.Is(err, http.ErrServerClosed) {
+			return nil
+		}
+		return err
+	case <-ctx.Done():
+	}
+
+	err := hs.Stop()
+	if serr := <-errc; !errors.Is(serr, http.ErrServerClosed) && err == nil {
+		err = serr
+	}
+	return err
+}
+
+// Stop turns readiness off, keeps serving for DrainDelay, then shuts the server down waiting for in-flight
+// requests up to ShutdownTimeout. Connections still active after the timeout are closed,
+// and the error of the timeout is returned. It waits for hooks registered by RegisterOnShutdown as well.
 func (hs *Server) Stop() error {
-	return hs.Server.Shutdown(context.Background())
+	hs.draining.Store(true)
+	if hs.DrainDelay > 0 {
+		time.Sleep(hs.DrainDelay)
+	}
+
+	timeout := hs.ShutdownTimeout
+	if timeout <= 0 {
+		timeout = defaultShutdownTimeout
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	err := hs.Server.Shutdown(ctx)
+	if err != nil {
+		hs.Server.Close()
+	}
+
+	done := make(chan struct{})
+	go func() {
+		hs.hooks.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-ctx.Done():
+		if err == nil {
+			err = ctx.Err()
+		}
+	}
+	return err
+}
+
+// Ready reports whether the server accepts new requests, which is false once it starts shutting down.
+func (hs *Server) Ready() bool {
+	return !hs.draining.Load()
+}
+
+// RegisterOnShutdown registers f to be called when the server starts shutting down, to clean up
+// such as hijacked connections. f is called in its own goroutine, and Stop waits for it up to ShutdownTimeout.
+func (hs *Server) RegisterOnShutdown(f func()) {
+	hs.hooks.Add(1)
+	var once sync.Once
+	hs.Server.RegisterOnShutdown(func() {
+		// http.Server calls hooks on every Shutdown
+		once.Do(func() {
+			defer hs.hooks.Done()
+			f()
+		})
+	})
 }
 
+// CreateTLSConfigMinTls returns a tls.Config of minTlsVersion that enables legacy cipher suites, including
+// insecure RC4 and 3DES ones.
+//
+// Deprecated: use sitls.NewConfig, which loads certificates and configures mutual TLS as well.
 func CreateTLSConfigMinTls(minTlsVersion uint16) *tls.Config {
 	conf := &tls.Config{
 		// MinVersion: tls.VersionTLS12,
@@ -0,0 +1,73 @@
+package sihttp
+
+import (
+	"fmt"
+	"net/http"
+	"runtime"
+	"sync/atomic"
+	"time"
+
+	"github.com/wonksing/si/v2/sihttp/middleware"
+)
+
+// ServerMetrics counts requests handled by Server with WithMetrics, and serves them in the text format of
+// Prometheus.
+type ServerMetrics struct {
+	meter    requestMeter
+	inFlight atomic.Int64
+}
+
+// NewServerMetrics returns ServerMetrics.
+func NewServerMetrics() *ServerMetrics {
+	return &ServerMetrics{}
+}
+
+// Total returns metrics of all requests handled.
+func (m *ServerMetrics) Total() RequestMetrics {
+	return m.meter.snapshot()
+}
+
+// InFlight returns the number of requests being handled.
+func (m *ServerMetrics) InFlight() int64 {
+	return m.inFlight.Load()
+}
+
+// middleware records requests handled by next.
+func (m *ServerMetrics) middleware(next http.Handler) http.Handler {
+	observed := middleware.Observe(func(r *http.Request, status int, size int64, elapsed time.Duration) {
+		m.meter.record(status, nil, elapsed)
+	})(next)
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		m.inFlight.Add(1)
+		defer m.inFlight.Add(-1)
+		observed.ServeHTTP(w, r)
+	})
+}
+
+// ServeHTTP writes the metrics.
+func (m *ServerMetrics) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	t := m.Total()
+	var ms runtime.MemStats
+	runtime.ReadMemStats(&ms)
+
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	fmt.Fprintln(w, "# HELP http_server_requests_total Number of requests handled by status class.")
+	fmt.Fprintln(w, "# TYPE http_server_requests_total counter")
+	for i, n := range []uint64{t.Status1xx, t.Status2xx, t.Status3xx, t.Status4xx, t.Status5xx} {
+		fmt.Fprintf(w, "http_server_requests_total{code=\"%dxx\"} %d\n", i+1, n)
+	}
+	fmt.Fprintln(w, "# HELP http_server_request_duration_seconds Time taken to handle requests.")
+	fmt.Fprintln(w, "# TYPE http_server_request_duration_seconds summary")
+	fmt.Fprintf(w, "http_server_request_duration_seconds_sum %g\n", t.Elapsed.Seconds())
+	fmt.Fprintf(w, "http_server_request_duration_seconds_count %d\n", t.Requests)
+	fmt.Fprintln(w, "# HELP http_server_requests_in_flight Number of requests being handled.")
+	fmt.Fprintln(w, "# TYPE http_server_requests_in_flight gauge")
+	fmt.Fprintf(w, "http_server_requests_in_flight %d\n", m.InFlight())
+	fmt.Fprintln(w, "# HELP go_goroutines Number of goroutines.")
+	fmt.Fprintln(w, "# TYPE go_goroutines gauge")
+	fmt.Fprintf(w, "go_goroutines %d\n", runtime.NumGoroutine())
+	fmt.Fprintln(w, "# HELP go_memstats_heap_alloc_bytes Bytes of allocated heap objects.")
+	fmt.Fprintln(w, "# TYPE go_memstats_heap_alloc_bytes gauge")
+	fmt.Fprintf(w, "go_memstats_heap_alloc_bytes %d\n", ms.HeapAlloc)
+}
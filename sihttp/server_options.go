@@ -0,0 +1,148 @@
+package sihttp
+
+import (
+	"crypto/tls"
+	"time"
+
+	"github.com/gorilla/handlers"
+	"github.com/wonksing/si/v2/sihttp/middleware"
+)
+
+type ServerOption interface {
+	apply(s *Server) error
+}
+
+type ServerOptionFunc func(s *Server) error
+
+func (o ServerOptionFunc) apply(s *Server) error {
+	return o(s)
+}
+
+// WithAddr sets the address to listen on, which is ":http" if empty.
+func WithAddr(addr string) ServerOptionFunc {
+	return ServerOptionFunc(func(s *Server) error {
+		s.Server.Addr = addr
+		return nil
+	})
+}
+
+func WithTLSConfig(conf *tls.Config) ServerOptionFunc {
+	return ServerOptionFunc(func(s *Server) error {
+		s.TLSConf = conf
+		s.Server.TLSConfig = conf
+		return nil
+	})
+}
+
+// WithCertificate sets the certificate and key files to serve TLS with. Server.Start serves plain http
+// if both are empty.
+func WithCertificate(pem, key string) ServerOptionFunc {
+	return ServerOptionFunc(func(s *Server) error {
+		s.pem = pem
+		s.key = key
+		return nil
+	})
+}
+
+func WithReadTimeout(d time.Duration) ServerOptionFunc {
+	return ServerOptionFunc(func(s *Server) error {
+		s.Server.ReadTimeout = d
+		return nil
+	})
+}
+
+func WithReadHeaderTimeout(d time.Duration) ServerOptionFunc {
+	return ServerOptionFunc(func(s *Server) error {
+		s.Server.ReadHeaderTimeout = d
+		return nil
+	})
+}
+
+func WithWriteTimeout(d time.Duration) ServerOptionFunc {
+	return ServerOptionFunc(func(s *Server) error {
+		s.Server.WriteTimeout = d
+		return nil
+	})
+}
+
+func WithIdleTimeout(d time.Duration) ServerOptionFunc {
+	return ServerOptionFunc(func(s *Server) error {
+		s.Server.IdleTimeout = d
+		return nil
+	})
+}
+
+// WithDrainDelay sets Server.DrainDelay.
+func WithDrainDelay(d time.Duration) ServerOptionFunc {
+	return ServerOptionFunc(func(s *Server) error {
+		s.DrainDelay = d
+		return nil
+	})
+}
+
+// WithShutdownTimeout sets Server.ShutdownTimeout.
+func WithShutdownTimeout(d time.Duration) ServerOptionFunc {
+	return ServerOptionFunc(func(s *Server) error {
+		s.ShutdownTimeout = d
+		return nil
+	})
+}
+
+// WithServerMiddleware appends mws wrapping the handler of Server. The first one is the outermost.
+func WithServerMiddleware(mws ...middleware.Middleware) ServerOptionFunc {
+	return ServerOptionFunc(func(s *Server) error {
+		s.middlewares = append(s.middlewares, mws...)
+		return nil
+	})
+}
+
+// WithCors appends a CORS middleware of gorilla/handlers. It is not appended if all of the arguments are empty.
+func WithCors(allowedOrigins, allowedHeaders, allowedMethods []string) ServerOptionFunc {
+	return ServerOptionFunc(func(s *Server) error {
+		if len(allowedOrigins) == 0 && len(allowedHeaders) == 0 && len(allowedMethods) == 0 {
+			return nil
+		}
+		cors := handlers.CORS(
+			handlers.AllowedOrigins(allowedOrigins),
+			handlers.AllowedHeaders(allowedHeaders),
+			handlers.AllowedMethods(allowedMethods),
+		)
+		s.middlewares = append(s.middlewares, cors)
+		return nil
+	})
+}
+
+// WithHealth serves HealthzPath with the checks of health, and ReadyzPath with them as well
+// unless the server is shutting down.
+func WithHealth(health *Health) ServerOptionFunc {
+	return ServerOptionFunc(func(s *Server) error {
+		s.health = health
+		return nil
+	})
+}
+
+// WithMetrics records requests to metrics, and serves them at MetricsPath.
+func WithMetrics(metrics *ServerMetrics) ServerOptionFunc {
+	return ServerOptionFunc(func(s *Server) error {
+		s.metrics = metrics
+		return nil
+	})
+}
+
+// WithHTTP2 serves HTTP/2 over TLS, which is disabled by default. Clients negotiate it by ALPN.
+func WithHTTP2() ServerOptionFunc {
+	return ServerOptionFunc(func(s *Server) error {
+		s.http2 = true
+		return nil
+	})
+}
+
+// WithH2C serves HTTP/2 over cleartext(h2c) for internal traffic, to clients with prior knowledge
+// or upgrading from HTTP/1.1. It enables HTTP/2 over TLS as well.
+func WithH2C() ServerOptionFunc {
+	return ServerOptionFunc(func(s *Server) error {
+		s.http2 = true
+		s.h2c = true
+		return nil
+	})
+}
@@ -1,14 +1,24 @@
 package sihttp
 
 import (
+	"context"
 	"crypto/tls"
+	"errors"
 	"fmt"
 	"io"
+	"log"
+	"net"
 	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"syscall"
 	"testing"
 	"time"
 
+	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+	"github.com/wonksing/si/v2/sihttp/middleware"
+	"github.com/wonksing/si/v2/sitls"
 )
 
 func TestServer_NewServer(t *testing.T) {
@@ -53,12 +63,160 @@ func TestServer_NewServerTls(t *testing.T) {
 
 }
 
+func TestServer_NewServer_Middleware(t *testing.T) {
+	// the upstream echoes the request id it receives
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(r.Header.Get(middleware.RequestIDHeader)))
+	}))
+	defer upstream.Close()
+	c := NewClient(_newStandardClient(), WithBaseUrl(upstream.URL), WithRequestOpt(WithRequestID("")))
+
+	h := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/panic" {
+			panic("boom")
+		}
+		b, err := c.GetContext(r.Context(), "/", nil, nil)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadGateway)
+			return
+		}
+		w.Write(b)
+	})
+	s := NewServerWithOptions(h,
+		WithCors([]string{"http://localhost"}, nil, nil),
+		WithServerMiddleware(middleware.RequestID(""), middleware.Recover(log.New(io.Discard, "", 0))),
+	)
+	svr := httptest.NewServer(s.Server.Handler)
+	defer svr.Close()
+
+	req, _ := http.NewRequest(http.MethodGet, svr.URL+"/", nil)
+	req.Header.Set(middleware.RequestIDHeader, "id-1")
+	req.Header.Set("Origin", "http://localhost")
+	resp, err := http.DefaultClient.Do(req)
+	require.Nil(t, err)
+	defer resp.Body.Close()
+	b, _ := io.ReadAll(resp.Body)
+	require.EqualValues(t, "id-1", string(b))
+	require.EqualValues(t, "id-1", resp.Header.Get(middleware.RequestIDHeader))
+	require.EqualValues(t, "http://localhost", resp.Header.Get("Access-Control-Allow-Origin"))
+
+	resp, err = http.Get(svr.URL + "/panic")
+	require.Nil(t, err)
+	resp.Body.Close()
+	require.EqualValues(t, http.StatusInternalServerError, resp.StatusCode)
+}
+
 func TestServer_CreateTLSConfigMinTls(t *testing.T) {
 	c := CreateTLSConfigMinTls(tls.VersionTLS13)
 	require.NotNil(t, c)
 	require.EqualValues(t, tls.VersionTLS13, c.MinVersion)
 }
 
+func TestServer_Start_TLS(t *testing.T) {
+	serverConf, err := sitls.NewConfig(
+		sitls.WithReloadingCertificate("./tests/data/certs/server.crt", "./tests/data/certs/server.key", time.Minute),
+	)
+	require.Nil(t, err)
+	addr := _freeAddr(t)
+	s := NewServerWithOptions(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(r.Proto))
+	}), WithAddr(addr), WithTLSConfig(serverConf))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	runErr := make(chan error, 1)
+	go func() {
+		runErr <- s.Run(ctx)
+	}()
+
+	clientConf, err := sitls.NewConfig(sitls.WithRootCAFile("./tests/data/certs/rootCA.crt"))
+	require.Nil(t, err)
+	c := NewClient(DefaultStandardClient(clientConf))
+	require.Eventually(t, func() bool {
+		b, err := c.Get("https://"+addr+"/", nil, nil)
+		return err == nil && string(b) == "HTTP/1.1"
+	}, time.Second, 10*time.Millisecond)
+
+	_, err = c.Get("http://"+addr+"/", nil, nil)
+	require.NotNil(t, err)
+
+	cancel()
+	require.Nil(t, <-runErr)
+}
+
+func TestServer_HTTP2(t *testing.T) {
+	serverConf, err := sitls.NewConfig(
+		sitls.WithReloadingCertificate("./tests/data/certs/server.crt", "./tests/data/certs/server.key", time.Minute),
+	)
+	require.Nil(t, err)
+	addr := _freeAddr(t)
+	s := NewServerWithOptions(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(r.Proto))
+	}), WithAddr(addr), WithTLSConfig(serverConf), WithH2C())
+
+	ctx, cancel := context.WithCancel(context.Background())
+	runErr := make(chan error, 1)
+	go func() {
+		runErr <- s.Run(ctx)
+	}()
+
+	clientConf, err := sitls.NewConfig(sitls.WithRootCAFile("./tests/data/certs/rootCA.crt"))
+	require.Nil(t, err)
+	h2 := NewClient(&http.Client{Transport: NewTransport(WithTransportTLSConfig(clientConf), WithTransportHTTP2())})
+	require.Eventually(t, func() bool {
+		b, err := h2.Get("https://"+addr+"/", nil, nil)
+		return err == nil && string(b) == "HTTP/2.0"
+	}, time.Second, 10*time.Millisecond)
+
+	// HTTP/1.1 is still served
+	h1 := NewClient(DefaultStandardClient(clientConf))
+	b, err := h1.Get("https://"+addr+"/", nil, nil)
+	require.Nil(t, err)
+	assert.EqualValues(t, "HTTP/1.1", string(b))
+
+	cancel()
+	require.Nil(t, <-runErr)
+}
+
+func TestServer_H2C(t *testing.T) {
+	addr := _freeAddr(t)
+	s := NewServerWithOptions(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(r.Proto))
+	}), WithAddr(addr), WithH2C())
+
+	ctx, cancel := context.WithCancel(context.Background())
+	runErr := make(chan error, 1)
+	go func() {
+		runErr <- s.Run(ctx)
+	}()
+
+	h2c := NewClient(&http.Client{Transport: NewTransport(WithTransportH2C(), WithMaxConnsPerHost(1))})
+	require.Eventually(t, func() bool {
+		b, err := h2c.Get("http://"+addr+"/", nil, nil)
+		return err == nil && string(b) == "HTTP/2.0"
+	}, time.Second, 10*time.Millisecond)
+
+	h1 := NewClient(DefaultStandardClient(nil))
+	b, err := h1.Get("http://"+addr+"/", nil, nil)
+	require.Nil(t, err)
+	assert.EqualValues(t, "HTTP/1.1", string(b))
+
+	// HTTP/2 is disabled by default
+	plain := NewServerWithOptions(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	assert.Len(t, plain.Server.TLSNextProto, 0)
+	assert.NotNil(t, plain.Server.TLSNextProto)
+
+	cancel()
+	require.Nil(t, <-runErr)
+}
+
+func TestServer_NewServerWithOptions_Error(t *testing.T) {
+	errOpt := errors.New("bad option")
+	s := NewServerWithOptions(http.NotFoundHandler(), ServerOptionFunc(func(s *Server) error {
+		return errOpt
+	}), WithAddr(":0"))
+	assert.ErrorIs(t, s.Start(), errOpt)
+}
+
 func _get(url string) ([]byte, error) {
 	req, err := http.NewRequest(http.MethodGet, url, nil)
 	if err != nil {
@@ -75,3 +233,94 @@ func _get(url string) ([]byte, error) {
 	return io.ReadAll(resp.Body)
 
 }
+
+func _freeAddr(t *testing.T) string {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	require.Nil(t, err)
+	defer l.Close()
+	return l.Addr().String()
+}
+
+func TestServer_Run(t *testing.T) {
+	started := make(chan struct{})
+	h := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/slow" {
+			close(started)
+			time.Sleep(200 * time.Millisecond)
+		}
+		w.Write([]byte("done"))
+	})
+	addr := _freeAddr(t)
+	s := NewServerWithOptions(h, WithAddr(addr), WithDrainDelay(100*time.Millisecond))
+
+	var cleaned atomic.Bool
+	s.RegisterOnShutdown(func() {
+		cleaned.Store(true)
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	runErr := make(chan error, 1)
+	go func() {
+		runErr <- s.Run(ctx)
+	}()
+	require.Eventually(t, func() bool {
+		_, err := _get("http://" + addr + "/")
+		return err == nil
+	}, time.Second, 10*time.Millisecond)
+	require.True(t, s.Ready())
+
+	resBody := make(chan []byte, 1)
+	go func() {
+		b, _ := _get("http://" + addr + "/slow")
+		resBody <- b
+	}()
+	<-started
+	cancel()
+
+	// new requests are served while draining
+	require.Eventually(t, func() bool { return !s.Ready() }, time.Second, time.Millisecond)
+	b, err := _get("http://" + addr + "/")
+	require.Nil(t, err)
+	require.EqualValues(t, "done", string(b))
+
+	require.Nil(t, <-runErr)
+	require.EqualValues(t, "done", string(<-resBody))
+	require.True(t, cleaned.Load())
+
+	_, err = _get("http://" + addr + "/")
+	require.NotNil(t, err)
+}
+
+func TestServer_Stop_Timeout(t *testing.T) {
+	started := make(chan struct{})
+	release := make(chan struct{})
+	defer close(release)
+	h := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		close(started)
+		<-release
+	})
+	addr := _freeAddr(t)
+	s := NewServerWithOptions(h, WithAddr(addr), WithShutdownTimeout(50*time.Millisecond))
+
+	runErr := make(chan error, 1)
+	go func() {
+		runErr <- s.Run(context.Background())
+	}()
+
+	resErr := make(chan error, 1)
+	go func() {
+		var err error
+		for i := 0; i < 100; i++ {
+			if _, err = _get("http://" + addr + "/"); !errors.Is(err, syscall.ECONNREFUSED) {
+				break
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+		resErr <- err
+	}()
+	<-started
+
+	require.ErrorIs(t, s.Stop(), context.DeadlineExceeded)
+	require.NotNil(t, <-resErr)
+	require.Nil(t, <-runErr)
+}
@@ -0,0 +1,145 @@
+package sihttp
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"net/http"
+
+	"github.com/wonksing/si/v2/codec/sign"
+)
+
+// DefaultMaxVerifyBodyBytes is the maximum size of a body that verifying middlewares read.
+const DefaultMaxVerifyBodyBytes = 10 << 20
+
+// WithHeaderSignature signs the request body with signer and sets the signature encoded with enc to header key.
+// A request without body is signed with an empty message.
+func WithHeaderSignature(key string, signer sign.Signer, enc sign.Encoding) RequestOptionFunc {
+	return RequestOptionFunc(func(req *http.Request) error {
+		body, err := getRequestBody(req)
+		if err != nil {
+			return err
+		}
+
+		sig, err := sign.SignToString(signer, enc, body)
+		if err != nil {
+			return err
+		}
+		req.Header[key] = []string{sig}
+		return nil
+	})
+}
+
+// VerifyOption is an option of VerifySignature.
+type VerifyOption interface {
+	apply(c *verifyConfig)
+}
+
+// VerifyOptionFunc wraps a function to conforms to VerifyOption interface.
+type VerifyOptionFunc func(c *verifyConfig)
+
+func (o VerifyOptionFunc) apply(c *verifyConfig) {
+	o(c)
+}
+
+type verifyConfig struct {
+	maxBodyBytes int64
+}
+
+// WithVerifyMaxBodyBytes limits the size of bodies to verify, which is DefaultMaxVerifyBodyBytes by default.
+func WithVerifyMaxBodyBytes(n int64) VerifyOptionFunc {
+	return VerifyOptionFunc(func(c *verifyConfig) {
+		if n > 0 {
+			c.maxBodyBytes = n
+		}
+	})
+}
+
+// VerifySignature returns a middleware that verifies the signature in header key against the request body.
+// It responds with 401 when the signature is missing or invalid, and 413 when the body is too large.
+// The body is restored for next handler.
+func VerifySignature(key string, verifier sign.Verifier, enc sign.Encoding, opts ...VerifyOption) func(http.Handler) http.Handler {
+	conf := verifyConfig{maxBodyBytes: DefaultMaxVerifyBodyBytes}
+	for _, o := range opts {
+		if o == nil {
+			continue
+		}
+		o.apply(&conf)
+	}
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			sig := r.Header.Get(key)
+			if sig == "" {
+				http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+				return
+			}
+
+			body, ok := readVerifiedBody(w, r, conf.maxBodyBytes)
+			if !ok {
+				return
+			}
+
+			if err := sign.VerifyString(verifier, enc, body, sig); err != nil {
+				http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// getRequestBody returns the body of an outgoing request without consuming it.
+// If req.GetBody is not set, req.Body is read and replaced.
+func getRequestBody(req *http.Request) ([]byte, error) {
+	if req.Body == nil || req.Body == http.NoBody {
+		return nil, nil
+	}
+	if req.GetBody == nil {
+		return readRequestBody(req)
+	}
+
+	r, err := req.GetBody()
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+	return io.ReadAll(r)
+}
+
+// readVerifiedBody reads the body of r up to maxBytes with readRequestBody for a verifying middleware.
+// It responds with 413 if the body is larger, or 400 if it fails to read, and reports whether the body is read.
+func readVerifiedBody(w http.ResponseWriter, r *http.Request, maxBytes int64) ([]byte, bool) {
+	if r.Body != nil && r.Body != http.NoBody {
+		r.Body = http.MaxBytesReader(w, r.Body, maxBytes)
+	}
+	body, err := readRequestBody(r)
+	if err != nil {
+		var me *http.MaxBytesError
+		if errors.As(err, &me) {
+			http.Error(w, http.StatusText(http.StatusRequestEntityTooLarge), http.StatusRequestEntityTooLarge)
+			return nil, false
+		}
+		http.Error(w, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+		return nil, false
+	}
+	return body, true
+}
+
+// readRequestBody reads all of req.Body then restores it so that it can be read again.
+func readRequestBody(req *http.Request) ([]byte, error) {
+	if req.Body == nil || req.Body == http.NoBody {
+		return nil, nil
+	}
+
+	b, err := io.ReadAll(req.Body)
+	req.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+
+	req.Body = io.NopCloser(bytes.NewReader(b))
+	req.GetBody = func() (io.ReadCloser, error) {
+		return io.NopCloser(bytes.NewReader(b)), nil
+	}
+	return b, nil
+}
@@ -0,0 +1,103 @@
+package sihttp
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/wonksing/si/v2/codec/sign"
+)
+
+func Test_WithHeaderSignature(t *testing.T) {
+	s := sign.NewHmacSha256([]byte("asdf"))
+
+	t.Run("bytes-body", func(t *testing.T) {
+		req, err := http.NewRequest(http.MethodPost, "/", bytes.NewBufferString("hello world"))
+		require.Nil(t, err)
+
+		err = WithHeaderSignature("X-Signature", s, sign.EncodingHex).apply(req)
+		require.Nil(t, err)
+		assert.EqualValues(t, "2c78fedf60d1f955bf0c9e14ed6b332a6efb6e5668fc6aa067257558cdbb7d6d", req.Header.Get("X-Signature"))
+
+		b, err := io.ReadAll(req.Body)
+		require.Nil(t, err)
+		assert.EqualValues(t, "hello world", string(b))
+	})
+
+	t.Run("reader-body", func(t *testing.T) {
+		req, err := http.NewRequest(http.MethodPost, "/", io.NopCloser(strings.NewReader("hello world")))
+		require.Nil(t, err)
+		require.Nil(t, req.GetBody)
+
+		err = WithHeaderSignature("X-Signature", s, sign.EncodingHex).apply(req)
+		require.Nil(t, err)
+		assert.EqualValues(t, "2c78fedf60d1f955bf0c9e14ed6b332a6efb6e5668fc6aa067257558cdbb7d6d", req.Header.Get("X-Signature"))
+
+		b, err := io.ReadAll(req.Body)
+		require.Nil(t, err)
+		assert.EqualValues(t, "hello world", string(b))
+	})
+
+	t.Run("no-body", func(t *testing.T) {
+		req, err := http.NewRequest(http.MethodGet, "/", nil)
+		require.Nil(t, err)
+
+		err = WithHeaderSignature("X-Signature", s, sign.EncodingBase64).apply(req)
+		require.Nil(t, err)
+		expected, _ := sign.SignToString(s, sign.EncodingBase64, nil)
+		assert.EqualValues(t, expected, req.Header.Get("X-Signature"))
+	})
+}
+
+func Test_VerifySignature(t *testing.T) {
+	s := sign.NewHmacSha512([]byte("asdf"))
+
+	h := VerifySignature("X-Signature", s, sign.EncodingBase64)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		b, _ := io.ReadAll(r.Body)
+		w.Write(b)
+	}))
+	svr := httptest.NewServer(h)
+	defer svr.Close()
+
+	c := NewClient(_newStandardClient(), WithRequestOpt(WithHeaderSignature("X-Signature", s, sign.EncodingBase64)))
+	res, err := c.Post(svr.URL, nil, []byte("hello world"))
+	require.Nil(t, err)
+	assert.EqualValues(t, "hello world", string(res))
+
+	c = NewClient(_newStandardClient(), WithRequestOpt(WithHeaderSignature("X-Signature", sign.NewHmacSha512([]byte("qwer")), sign.EncodingBase64)))
+	_, err = c.Post(svr.URL, nil, []byte("hello world"))
+	require.NotNil(t, err)
+	assert.EqualValues(t, http.StatusUnauthorized, err.(*Error).GetStatusCode(0))
+
+	c = NewClient(_newStandardClient())
+	_, err = c.Post(svr.URL, nil, []byte("hello world"))
+	require.NotNil(t, err)
+	assert.EqualValues(t, http.StatusUnauthorized, err.(*Error).GetStatusCode(0))
+}
+
+func Test_VerifyMiddlewares_MaxBodyBytes(t *testing.T) {
+	s := sign.NewHmacSha256([]byte("asdf"))
+	ok := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})
+	handlers := map[string]http.Handler{
+		"signature": VerifySignature("X-Signature", s, sign.EncodingHex, WithVerifyMaxBodyBytes(8))(ok),
+		"canonical": VerifyCanonicalSignature(func(string) (sign.Verifier, error) { return s, nil },
+			WithCanonicalMaxBodyBytes(8))(ok),
+		"message": VerifyMessageSignature(func(string, string) (sign.Verifier, error) { return s, nil },
+			WithMessageMaxBodyBytes(8))(ok),
+		"hmac": VerifyHeaderHmac256("X-Signature", []byte("asdf"), WithHmacMaxBodyBytes(8))(ok),
+	}
+	for name, h := range handlers {
+		t.Run(name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodPost, "/", strings.NewReader("larger than 8 bytes"))
+			r.Header.Set("X-Signature", "00")
+			w := httptest.NewRecorder()
+			h.ServeHTTP(w, r)
+			assert.EqualValues(t, http.StatusRequestEntityTooLarge, w.Code)
+		})
+	}
+}
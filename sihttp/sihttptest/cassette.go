@@ -0,0 +1,360 @@
+package sihttptest
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"unicode/utf8"
+)
+
+// Mode is a mode of Recorder.
+type Mode int
+
+const (
+	// ModeReplay replays interactions of the cassette, and fails requests that are not recorded.
+	ModeReplay Mode = iota
+	// ModeRecord sends requests and records interactions, which are saved to the cassette by Stop.
+	ModeRecord
+	// ModeReplayOrRecord replays if the cassette exists, and records otherwise.
+	ModeReplayOrRecord
+)
+
+// ErrInteractionNotFound is returned by Recorder in replay mode for a request that is not in the cassette.
+var ErrInteractionNotFound = errors.New("sihttptest: interaction not found")
+
+// BodyEncodingBase64 is the BodyEncoding of bodies that are not valid UTF-8, which are recorded in base64.
+const BodyEncodingBase64 = "base64"
+
+// RecordedRequest is a request of an Interaction.
+type RecordedRequest struct {
+	Method string      `json:"method"`
+	Url    string      `json:"url"`
+	Header http.Header `json:"header,omitempty"`
+	Body   string      `json:"body,omitempty"`
+	// BodyEncoding is BodyEncodingBase64 if Body is encoded, and empty if it is as it is.
+	BodyEncoding string `json:"body_encoding,omitempty"`
+}
+
+// BodyBytes returns the body decoded by BodyEncoding.
+func (r RecordedRequest) BodyBytes() ([]byte, error) {
+	return decodeBody(r.Body, r.BodyEncoding)
+}
+
+// RecordedResponse is a response of an Interaction.
+type RecordedResponse struct {
+	Status int         `json:"status"`
+	Header http.Header `json:"header,omitempty"`
+	Body   string      `json:"body,omitempty"`
+	// BodyEncoding is BodyEncodingBase64 if Body is encoded, and empty if it is as it is.
+	BodyEncoding string `json:"body_encoding,omitempty"`
+}
+
+// BodyBytes returns the body decoded by BodyEncoding.
+func (r RecordedResponse) BodyBytes() ([]byte, error) {
+	return decodeBody(r.Body, r.BodyEncoding)
+}
+
+// encodeBody returns b as it is if it is valid UTF-8, and encoded in base64 otherwise, with its encoding.
+func encodeBody(b []byte) (string, string) {
+	if utf8.Valid(b) {
+		return string(b), ""
+	}
+	return base64.StdEncoding.EncodeToString(b), BodyEncodingBase64
+}
+
+func decodeBody(body, encoding string) ([]byte, error) {
+	switch encoding {
+	case "":
+		return []byte(body), nil
+	case BodyEncodingBase64:
+		return base64.StdEncoding.DecodeString(body)
+	default:
+		return nil, fmt.Errorf("sihttptest: unknown body encoding %q", encoding)
+	}
+}
+
+// Interaction is a pair of a request and its response.
+type Interaction struct {
+	Request  RecordedRequest  `json:"request"`
+	Response RecordedResponse `json:"response"`
+}
+
+// Cassette is a file of recorded interactions.
+type Cassette struct {
+	Interactions []Interaction `json:"interactions"`
+}
+
+// LoadCassette reads a cassette from path.
+func LoadCassette(path string) (*Cassette, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	c := &Cassette{}
+	if err := json.Unmarshal(b, c); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// Save writes c to path, creating its directory if needed.
+func (c *Cassette) Save(path string) error {
+	b, err := json.MarshalIndent(c, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	return os.WriteFile(path, b, 0644)
+}
+
+// Matcher reports whether a request matches a recorded one. Use RecordedRequest.BodyBytes to compare bodies,
+// which may be encoded.
+type Matcher func(r *http.Request, body []byte, recorded RecordedRequest) bool
+
+// DefaultMatcher matches requests by method and url, redacting the default secret query parameters of the url
+// as Recorder does.
+func DefaultMatcher(r *http.Request, _ []byte, recorded RecordedRequest) bool {
+	return r.Method == recorded.Method && redactUrl(r.URL, _defaultRedactQueryParams) == recorded.Url
+}
+
+// Recorder is an http.RoundTripper that records interactions to a cassette or replays them from it.
+type Recorder struct {
+	path string
+	mode Mode
+	next http.RoundTripper
+
+	// Matcher matches requests to recorded ones in replay mode. If nil, requests are matched by method and url
+	// with RedactQueryParams redacted, as DefaultMatcher does.
+	Matcher Matcher
+	// RedactHeaders are request and response headers that are recorded as "***".
+	// Authorization, Proxy-Authorization, Cookie and Set-Cookie are redacted if nil.
+	RedactHeaders []string
+	// RedactQueryParams are query parameters whose values are recorded as "***", compared case-insensitively.
+	// access_token, api_key, apikey, client_secret, key, password, secret, sig, signature and token are redacted
+	// if nil. The password of the url is always redacted.
+	RedactQueryParams []string
+
+	mu       sync.Mutex
+	cassette *Cassette
+	used     []bool
+}
+
+// NewRecorder returns a Recorder of the cassette at path. next sends requests in record mode,
+// which is http.DefaultTransport if nil.
+func NewRecorder(path string, mode Mode, next http.RoundTripper) (*Recorder, error) {
+	if next == nil {
+		next = http.DefaultTransport
+	}
+	r := &Recorder{path: path, mode: mode, next: next, cassette: &Cassette{}}
+
+	if mode == ModeReplayOrRecord {
+		if _, err := os.Stat(path); err == nil {
+			r.mode = ModeReplay
+		} else if errors.Is(err, os.ErrNotExist) {
+			r.mode = ModeRecord
+		} else {
+			return nil, err
+		}
+	}
+	if r.mode == ModeReplay {
+		c, err := LoadCassette(path)
+		if err != nil {
+			return nil, err
+		}
+		r.cassette = c
+		r.used = make([]bool, len(c.Interactions))
+	}
+	return r, nil
+}
+
+// Mode returns the mode the recorder is running in, which is either ModeReplay or ModeRecord.
+func (rec *Recorder) Mode() Mode {
+	return rec.mode
+}
+
+// Client returns an http.Client using rec as its transport.
+func (rec *Recorder) Client() *http.Client {
+	return &http.Client{Transport: rec}
+}
+
+// Stop saves the cassette in record mode.
+func (rec *Recorder) Stop() error {
+	if rec.mode != ModeRecord {
+		return nil
+	}
+	rec.mu.Lock()
+	defer rec.mu.Unlock()
+	return rec.cassette.Save(rec.path)
+}
+
+// RoundTrip implements http.RoundTripper.
+func (rec *Recorder) RoundTrip(req *http.Request) (*http.Response, error) {
+	var body []byte
+	if req.Body != nil && req.Body != http.NoBody {
+		var err error
+		body, err = io.ReadAll(req.Body)
+		req.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if rec.mode == ModeReplay {
+		return rec.replay(req, body)
+	}
+	return rec.record(req, body)
+}
+
+func (rec *Recorder) replay(req *http.Request, body []byte) (*http.Response, error) {
+	matcher := rec.Matcher
+	if matcher == nil {
+		matcher = func(r *http.Request, _ []byte, recorded RecordedRequest) bool {
+			return r.Method == recorded.Method && rec.redactUrl(r.URL) == recorded.Url
+		}
+	}
+
+	rec.mu.Lock()
+	defer rec.mu.Unlock()
+	for i, it := range rec.cassette.Interactions {
+		if rec.used[i] || !matcher(req, body, it.Request) {
+			continue
+		}
+		rec.used[i] = true
+
+		res := it.Response
+		resBody, err := res.BodyBytes()
+		if err != nil {
+			return nil, err
+		}
+		header := res.Header.Clone()
+		if header == nil {
+			header = make(http.Header)
+		}
+		return &http.Response{
+			Status:        strconv.Itoa(res.Status) + " " + http.StatusText(res.Status),
+			StatusCode:    res.Status,
+			Proto:         "HTTP/1.1",
+			ProtoMajor:    1,
+			ProtoMinor:    1,
+			Header:        header,
+			Body:          io.NopCloser(bytes.NewReader(resBody)),
+			ContentLength: int64(len(resBody)),
+			Request:       req,
+		}, nil
+	}
+	return nil, fmt.Errorf("%w: %s %s", ErrInteractionNotFound, req.Method, req.URL)
+}
+
+func (rec *Recorder) record(req *http.Request, body []byte) (*http.Response, error) {
+	out := req.Clone(req.Context())
+	if body != nil {
+		out.Body = io.NopCloser(bytes.NewReader(body))
+		out.ContentLength = int64(len(body))
+	}
+	resp, err := rec.next.RoundTrip(out)
+	if err != nil {
+		return nil, err
+	}
+
+	resBody, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	resp.Body = io.NopCloser(bytes.NewReader(resBody))
+
+	recorded := Interaction{
+		Request: RecordedRequest{
+			Method: req.Method,
+			Url:    rec.redactUrl(req.URL),
+			Header: rec.redact(req.Header),
+		},
+		Response: RecordedResponse{
+			Status: resp.StatusCode,
+			Header: rec.redact(resp.Header),
+		},
+	}
+	recorded.Request.Body, recorded.Request.BodyEncoding = encodeBody(body)
+	recorded.Response.Body, recorded.Response.BodyEncoding = encodeBody(resBody)
+
+	rec.mu.Lock()
+	rec.cassette.Interactions = append(rec.cassette.Interactions, recorded)
+	rec.mu.Unlock()
+	return resp, nil
+}
+
+var _defaultRedactHeaders = []string{"Authorization", "Proxy-Authorization", "Cookie", "Set-Cookie"}
+
+func (rec *Recorder) redact(h http.Header) http.Header {
+	redacted := h.Clone()
+	headers := rec.RedactHeaders
+	if headers == nil {
+		headers = _defaultRedactHeaders
+	}
+	for _, k := range headers {
+		if _, ok := redacted[http.CanonicalHeaderKey(k)]; ok {
+			redacted[http.CanonicalHeaderKey(k)] = []string{"***"}
+		}
+	}
+	return redacted
+}
+
+var _defaultRedactQueryParams = []string{
+	"access_token", "api_key", "apikey", "client_secret", "key", "password", "secret", "sig", "signature", "token",
+}
+
+func (rec *Recorder) redactUrl(u *url.URL) string {
+	params := rec.RedactQueryParams
+	if params == nil {
+		params = _defaultRedactQueryParams
+	}
+	return redactUrl(u, params)
+}
+
+// redactUrl returns u as a string, replacing its password and the values of params in its query with "***".
+func redactUrl(u *url.URL, params []string) string {
+	ru := *u
+	if _, ok := ru.User.Password(); ok {
+		ru.User = url.UserPassword(ru.User.Username(), "***")
+	}
+	if ru.RawQuery == "" {
+		return ru.String()
+	}
+
+	q := ru.Query()
+	redacted := false
+	for k, v := range q {
+		if !containsFold(params, k) {
+			continue
+		}
+		for i := range v {
+			v[i] = "***"
+		}
+		redacted = true
+	}
+	if redacted {
+		ru.RawQuery = q.Encode()
+	}
+	return ru.String()
+}
+
+func containsFold(s []string, v string) bool {
+	for _, e := range s {
+		if strings.EqualFold(e, v) {
+			return true
+		}
+	}
+	return false
+}
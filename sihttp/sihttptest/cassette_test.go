@@ -0,0 +1,165 @@
+package sihttptest
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/wonksing/si/v2/sihttp"
+)
+
+func TestRecorder(t *testing.T) {
+	s := NewServer(t)
+	s.Expect(http.MethodGet, "/books/1").Respond(http.StatusOK, `{"id":1}`).Times(1)
+	s.Expect(http.MethodPost, "/books").Respond(http.StatusCreated, `{"id":2}`).Times(1)
+
+	path := filepath.Join(t.TempDir(), "cassettes", "books.json")
+
+	// record
+	rec, err := NewRecorder(path, ModeReplayOrRecord, nil)
+	require.Nil(t, err)
+	assert.EqualValues(t, ModeRecord, rec.Mode())
+
+	c := sihttp.NewClient(rec.Client(), sihttp.WithBaseUrl(s.URL))
+	b, err := c.Get("/books/1", http.Header{"Authorization": {"Bearer secret"}}, nil)
+	require.Nil(t, err)
+	assert.EqualValues(t, `{"id":1}`, string(b))
+	b, err = c.Post("/books", nil, []byte(`{"title":"go"}`))
+	require.Nil(t, err)
+	assert.EqualValues(t, `{"id":2}`, string(b))
+	require.Nil(t, rec.Stop())
+	s.AssertExpectations(t)
+
+	cassette, err := LoadCassette(path)
+	require.Nil(t, err)
+	require.Len(t, cassette.Interactions, 2)
+	assert.EqualValues(t, "***", cassette.Interactions[0].Request.Header.Get("Authorization"))
+	assert.EqualValues(t, `{"title":"go"}`, cassette.Interactions[1].Request.Body)
+	assert.EqualValues(t, http.StatusCreated, cassette.Interactions[1].Response.Status)
+
+	// replay without the server, whose expectations would fail on more calls
+	rec, err = NewRecorder(path, ModeReplayOrRecord, nil)
+	require.Nil(t, err)
+	assert.EqualValues(t, ModeReplay, rec.Mode())
+
+	c = sihttp.NewClient(rec.Client(), sihttp.WithBaseUrl(s.URL))
+	b, err = c.Post("/books", nil, []byte(`{"title":"go"}`))
+	require.Nil(t, err)
+	assert.EqualValues(t, `{"id":2}`, string(b))
+	b, err = c.Get("/books/1", nil, nil)
+	require.Nil(t, err)
+	assert.EqualValues(t, `{"id":1}`, string(b))
+
+	// each interaction is replayed once
+	_, err = c.Get("/books/1", nil, nil)
+	assert.ErrorIs(t, err, ErrInteractionNotFound)
+	require.Nil(t, rec.Stop())
+	s.AssertExpectations(t)
+}
+
+func TestRecorder_BinaryBody(t *testing.T) {
+	png := []byte{0x89, 'P', 'N', 'G', 0x0d, 0x0a, 0x1a, 0x0a, 0xff, 0x00}
+	s := NewServer(t)
+	s.Expect(http.MethodPost, "/images").
+		Respond(http.StatusOK, png).
+		WithResponseHeader("Set-Cookie", "session=secret").
+		Times(1)
+
+	path := filepath.Join(t.TempDir(), "cassette.json")
+	rec, err := NewRecorder(path, ModeRecord, nil)
+	require.Nil(t, err)
+	c := sihttp.NewClient(rec.Client(), sihttp.WithBaseUrl(s.URL))
+	b, err := c.Post("/images", nil, png)
+	require.Nil(t, err)
+	assert.EqualValues(t, png, b)
+	require.Nil(t, rec.Stop())
+	s.AssertExpectations(t)
+
+	cassette, err := LoadCassette(path)
+	require.Nil(t, err)
+	require.Len(t, cassette.Interactions, 1)
+	it := cassette.Interactions[0]
+	assert.EqualValues(t, BodyEncodingBase64, it.Request.BodyEncoding)
+	assert.EqualValues(t, BodyEncodingBase64, it.Response.BodyEncoding)
+	body, err := it.Request.BodyBytes()
+	require.Nil(t, err)
+	assert.EqualValues(t, png, body)
+	assert.EqualValues(t, "***", it.Response.Header.Get("Set-Cookie"))
+
+	rec, err = NewRecorder(path, ModeReplay, nil)
+	require.Nil(t, err)
+	c = sihttp.NewClient(rec.Client(), sihttp.WithBaseUrl(s.URL))
+	b, err = c.Post("/images", nil, png)
+	require.Nil(t, err)
+	assert.EqualValues(t, png, b)
+	require.Nil(t, rec.Stop())
+}
+
+func TestRecorder_RedactQuery(t *testing.T) {
+	s := NewServer(t)
+	s.Expect(http.MethodGet, "/books").Respond(http.StatusOK, `[]`).Times(1)
+
+	path := filepath.Join(t.TempDir(), "cassette.json")
+	rec, err := NewRecorder(path, ModeRecord, nil)
+	require.Nil(t, err)
+	resp, err := rec.Client().Get(s.URL + "/books?page=1&Token=secret")
+	require.Nil(t, err)
+	resp.Body.Close()
+	require.Nil(t, rec.Stop())
+	s.AssertExpectations(t)
+
+	b, err := os.ReadFile(path)
+	require.Nil(t, err)
+	assert.NotContains(t, string(b), "secret")
+
+	rec, err = NewRecorder(path, ModeReplay, nil)
+	require.Nil(t, err)
+	resp, err = rec.Client().Get(s.URL + "/books?page=1&Token=secret")
+	require.Nil(t, err)
+	b, _ = io.ReadAll(resp.Body)
+	resp.Body.Close()
+	assert.EqualValues(t, `[]`, string(b))
+
+	_, err = rec.Client().Get(s.URL + "/books?page=2&Token=secret")
+	assert.ErrorIs(t, err, ErrInteractionNotFound)
+}
+
+func TestRecorder_Matcher(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "cassette.json")
+	cassette := &Cassette{Interactions: []Interaction{
+		{
+			Request:  RecordedRequest{Method: http.MethodPost, Url: "http://localhost/echo", Body: "a"},
+			Response: RecordedResponse{Status: http.StatusOK, Body: "A"},
+		},
+		{
+			Request:  RecordedRequest{Method: http.MethodPost, Url: "http://localhost/echo", Body: "b"},
+			Response: RecordedResponse{Status: http.StatusOK, Body: "B"},
+		},
+	}}
+	require.Nil(t, cassette.Save(path))
+
+	rec, err := NewRecorder(path, ModeReplay, nil)
+	require.Nil(t, err)
+	rec.Matcher = func(r *http.Request, body []byte, recorded RecordedRequest) bool {
+		return DefaultMatcher(r, body, recorded) && string(body) == recorded.Body
+	}
+
+	resp, err := rec.Client().Post("http://localhost/echo", "text/plain", bytes.NewBufferString("b"))
+	require.Nil(t, err)
+	b, _ := io.ReadAll(resp.Body)
+	assert.EqualValues(t, "B", string(b))
+
+	_, err = rec.Client().Post("http://localhost/echo", "text/plain", bytes.NewBufferString("c"))
+	assert.True(t, errors.Is(err, ErrInteractionNotFound))
+}
+
+func TestNewRecorder_NotFound(t *testing.T) {
+	_, err := NewRecorder(filepath.Join(t.TempDir(), "none.json"), ModeReplay, nil)
+	require.NotNil(t, err)
+}
@@ -0,0 +1,318 @@
+// Package sihttptest provides a mock http server with expectations, and a recorder of http interactions for tests.
+package sihttptest
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/wonksing/si/v2/codec"
+)
+
+// Server is a mock http server that responds to requests matching its expectations.
+// Requests that match no expectation are responded with 501 and reported by ExpectationsWereMet.
+type Server struct {
+	*httptest.Server
+
+	mu           sync.Mutex
+	expectations []*Expectation
+	unmatched    []string
+}
+
+// NewServer starts a Server, which is closed when t and its subtests complete.
+func NewServer(t testing.TB) *Server {
+	s := &Server{}
+	s.Server = httptest.NewServer(http.HandlerFunc(s.serveHTTP))
+	t.Cleanup(s.Close)
+	return s
+}
+
+// Expect adds an expectation of a request with method and path.
+// Expectations are matched in the order they are added.
+func (s *Server) Expect(method, path string) *Expectation {
+	e := &Expectation{
+		method: method,
+		path:   path,
+		query:  make(map[string]string),
+		header: make(map[string]string),
+		times:  -1,
+	}
+	s.mu.Lock()
+	s.expectations = append(s.expectations, e)
+	s.mu.Unlock()
+	return e
+}
+
+// ExpectationsWereMet returns an error describing expectations that were not called as many times as expected,
+// and requests that matched no expectation.
+func (s *Server) ExpectationsWereMet() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var msgs []string
+	for _, e := range s.expectations {
+		if e.times > 0 && e.calls != e.times {
+			msgs = append(msgs, fmt.Sprintf("%s expected %d calls, got %d", e, e.times, e.calls))
+		} else if e.times < 0 && e.calls == 0 {
+			msgs = append(msgs, fmt.Sprintf("%s was not called", e))
+		}
+	}
+	for _, u := range s.unmatched {
+		msgs = append(msgs, "unexpected request "+u)
+	}
+	if len(msgs) == 0 {
+		return nil
+	}
+	return fmt.Errorf("sihttptest: %s", strings.Join(msgs, "; "))
+}
+
+// AssertExpectations fails t if ExpectationsWereMet returns an error.
+func (s *Server) AssertExpectations(t testing.TB) {
+	t.Helper()
+	if err := s.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
+
+func (s *Server) serveHTTP(w http.ResponseWriter, r *http.Request) {
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	r.Body = io.NopCloser(bytes.NewReader(body))
+
+	s.mu.Lock()
+	var matched *Expectation
+	for _, e := range s.expectations {
+		if e.matches(r, body) {
+			matched = e
+			break
+		}
+	}
+	if matched == nil {
+		s.unmatched = append(s.unmatched, r.Method+" "+r.URL.RequestURI())
+		s.mu.Unlock()
+		http.Error(w, "sihttptest: no expectation matched "+r.Method+" "+r.URL.RequestURI(), http.StatusNotImplemented)
+		return
+	}
+	res := matched.next()
+	s.mu.Unlock()
+
+	if res.Delay > 0 {
+		select {
+		case <-time.After(res.Delay):
+		case <-r.Context().Done():
+			return
+		}
+	}
+	for k, v := range res.Header {
+		w.Header()[k] = v
+	}
+	status := res.Status
+	if status == 0 {
+		status = http.StatusOK
+	}
+	w.WriteHeader(status)
+	w.Write(res.Body)
+}
+
+// Response is a canned response of an Expectation.
+type Response struct {
+	Status int
+	Header http.Header
+	Body   []byte
+	Delay  time.Duration
+}
+
+// Expectation matches requests and responds with canned responses.
+// Its methods are not safe to be called while the server is handling requests.
+type Expectation struct {
+	method   string
+	path     string
+	query    map[string]string
+	header   map[string]string
+	jsonBody any
+
+	responses []Response
+	times     int
+	calls     int
+}
+
+func (e *Expectation) String() string {
+	return e.method + " " + e.path
+}
+
+// WithQuery matches requests with query parameter key of value.
+func (e *Expectation) WithQuery(key, value string) *Expectation {
+	e.query[key] = value
+	return e
+}
+
+// WithHeader matches requests with header key of value.
+func (e *Expectation) WithHeader(key, value string) *Expectation {
+	e.header[key] = value
+	return e
+}
+
+// WithJsonBody matches requests whose json body contains v. Objects match if they have every field of v
+// with matching values, and arrays match if they have the same length with matching elements.
+// v can be any value that encodes to json, including a json string in []byte.
+func (e *Expectation) WithJsonBody(v any) *Expectation {
+	b, ok := v.([]byte)
+	if !ok {
+		var err error
+		if b, err = codec.Marshal(codec.FormatJson, v); err != nil {
+			panic("sihttptest: " + err.Error())
+		}
+	}
+	expected, err := codec.Unmarshal[any](codec.FormatJson, b)
+	if err != nil {
+		panic("sihttptest: " + err.Error())
+	}
+	e.jsonBody = expected
+	return e
+}
+
+// Times limits how many requests the expectation matches, and makes ExpectationsWereMet require exactly n calls.
+// Without it, the expectation matches any number of requests and requires at least one.
+func (e *Expectation) Times(n int) *Expectation {
+	e.times = n
+	return e
+}
+
+// Respond appends a response of status and body, which can be []byte, string, or a value encoded to json.
+// Responses are sent in the order they are appended, and the last one is repeated.
+func (e *Expectation) Respond(status int, body any) *Expectation {
+	res := Response{Status: status, Header: make(http.Header)}
+	switch b := body.(type) {
+	case nil:
+	case []byte:
+		res.Body = b
+	case string:
+		res.Body = []byte(b)
+	default:
+		encoded, err := codec.Marshal(codec.FormatJson, b)
+		if err != nil {
+			panic("sihttptest: " + err.Error())
+		}
+		res.Body = encoded
+		res.Header.Set("Content-Type", "application/json")
+	}
+	return e.RespondWith(res)
+}
+
+// RespondWith appends res. See Respond.
+func (e *Expectation) RespondWith(res Response) *Expectation {
+	if res.Header == nil {
+		res.Header = make(http.Header)
+	}
+	e.responses = append(e.responses, res)
+	return e
+}
+
+// WithResponseHeader sets header key of the last response.
+func (e *Expectation) WithResponseHeader(key, value string) *Expectation {
+	e.last().Header.Set(key, value)
+	return e
+}
+
+// WithDelay delays the last response by d.
+func (e *Expectation) WithDelay(d time.Duration) *Expectation {
+	e.last().Delay = d
+	return e
+}
+
+// Calls returns the number of requests matched.
+func (e *Expectation) Calls() int {
+	return e.calls
+}
+
+func (e *Expectation) last() *Response {
+	if len(e.responses) == 0 {
+		e.responses = append(e.responses, Response{Status: http.StatusOK, Header: make(http.Header)})
+	}
+	return &e.responses[len(e.responses)-1]
+}
+
+// next counts a call and returns its response.
+func (e *Expectation) next() Response {
+	e.calls++
+	if len(e.responses) == 0 {
+		return Response{Status: http.StatusOK}
+	}
+	i := e.calls - 1
+	if i >= len(e.responses) {
+		i = len(e.responses) - 1
+	}
+	return e.responses[i]
+}
+
+func (e *Expectation) matches(r *http.Request, body []byte) bool {
+	if e.times >= 0 && e.calls >= e.times {
+		return false
+	}
+	if e.method != "" && r.Method != e.method {
+		return false
+	}
+	if e.path != "" && r.URL.Path != e.path {
+		return false
+	}
+
+	q := r.URL.Query()
+	for k, v := range e.query {
+		if q.Get(k) != v {
+			return false
+		}
+	}
+	for k, v := range e.header {
+		if r.Header.Get(k) != v {
+			return false
+		}
+	}
+
+	if e.jsonBody != nil {
+		actual, err := codec.Unmarshal[any](codec.FormatJson, body)
+		if err != nil || !jsonContains(actual, e.jsonBody) {
+			return false
+		}
+	}
+	return true
+}
+
+// jsonContains reports whether actual contains expected, both of which are decoded from json.
+func jsonContains(actual, expected any) bool {
+	switch ev := expected.(type) {
+	case map[string]any:
+		av, ok := actual.(map[string]any)
+		if !ok {
+			return false
+		}
+		for k, v := range ev {
+			a, ok := av[k]
+			if !ok || !jsonContains(a, v) {
+				return false
+			}
+		}
+		return true
+	case []any:
+		av, ok := actual.([]any)
+		if !ok || len(av) != len(ev) {
+			return false
+		}
+		for i := range ev {
+			if !jsonContains(av[i], ev[i]) {
+				return false
+			}
+		}
+		return true
+	}
+	return reflect.DeepEqual(actual, expected)
+}
@@ -0,0 +1,154 @@
+package sihttptest
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/wonksing/si/v2/sihttp"
+	"github.com/wonksing/si/v2/sio"
+)
+
+type _book struct {
+	Id    int      `json:"id"`
+	Title string   `json:"title"`
+	Tags  []string `json:"tags,omitempty"`
+}
+
+func newClient(baseUrl string) *sihttp.Client {
+	return sihttp.NewClient(&http.Client{Timeout: 5 * time.Second}, sihttp.WithBaseUrl(baseUrl),
+		sihttp.WithWriterOpt(sio.SetJsonEncoder()),
+		sihttp.WithReaderOpt(sio.SetJsonDecoder()),
+	)
+}
+
+func TestServer_Expect(t *testing.T) {
+	s := NewServer(t)
+	s.Expect(http.MethodGet, "/books/1").
+		WithQuery("detail", "true").
+		WithHeader("X-Api-Key", "key").
+		Respond(http.StatusOK, _book{Id: 1, Title: "go"}).
+		WithResponseHeader("X-Request-Id", "abc")
+
+	c := newClient(s.URL)
+	req, _ := http.NewRequest(http.MethodGet, s.URL+"/books/1?detail=true", nil)
+	req.Header.Set("X-Api-Key", "key")
+	resp, err := c.Do(req)
+	require.Nil(t, err)
+	resp.Body.Close()
+	assert.EqualValues(t, "abc", resp.Header.Get("X-Request-Id"))
+	assert.EqualValues(t, "application/json", resp.Header.Get("Content-Type"))
+
+	book, err := sihttp.Get[_book](context.Background(), c, "/books/1?detail=true", sihttp.WithHeaderSet("X-Api-Key", "key"))
+	require.Nil(t, err)
+	assert.EqualValues(t, _book{Id: 1, Title: "go"}, book)
+
+	s.AssertExpectations(t)
+}
+
+func TestServer_Unmatched(t *testing.T) {
+	s := NewServer(t)
+	s.Expect(http.MethodGet, "/books/1").WithHeader("X-Api-Key", "key").Respond(http.StatusOK, "ok")
+	s.Expect(http.MethodDelete, "/books/1")
+
+	c := newClient(s.URL)
+	_, err := c.Get("/books/1", nil, nil)
+	require.NotNil(t, err)
+	assert.EqualValues(t, http.StatusNotImplemented, err.(*sihttp.Error).GetStatusCode(0))
+
+	err = s.ExpectationsWereMet()
+	require.NotNil(t, err)
+	assert.Contains(t, err.Error(), "GET /books/1 was not called")
+	assert.Contains(t, err.Error(), "DELETE /books/1 was not called")
+	assert.Contains(t, err.Error(), "unexpected request GET /books/1")
+}
+
+func TestServer_JsonBody(t *testing.T) {
+	s := NewServer(t)
+	create := s.Expect(http.MethodPost, "/books").
+		WithJsonBody(map[string]any{"title": "go", "tags": []string{"a", "b"}}).
+		Respond(http.StatusCreated, `{"id":1}`)
+	other := s.Expect(http.MethodPost, "/books").
+		WithJsonBody([]byte(`{"title":"other"}`)).
+		Respond(http.StatusCreated, `{"id":2}`)
+
+	c := newClient(s.URL)
+	res, err := sihttp.Post[_book, _book](context.Background(), c, "/books", _book{Id: 9, Title: "go", Tags: []string{"a", "b"}})
+	require.Nil(t, err)
+	assert.EqualValues(t, 1, res.Id)
+
+	res, err = sihttp.Post[_book, _book](context.Background(), c, "/books", _book{Title: "other"})
+	require.Nil(t, err)
+	assert.EqualValues(t, 2, res.Id)
+
+	// tags differ in length
+	_, err = sihttp.Post[_book, _book](context.Background(), c, "/books", _book{Title: "go", Tags: []string{"a"}})
+	require.NotNil(t, err)
+
+	assert.EqualValues(t, 1, create.Calls())
+	assert.EqualValues(t, 1, other.Calls())
+}
+
+func TestServer_Sequence(t *testing.T) {
+	s := NewServer(t)
+	e := s.Expect(http.MethodGet, "/flaky").
+		Respond(http.StatusServiceUnavailable, "down").
+		Respond(http.StatusServiceUnavailable, "down").
+		Respond(http.StatusOK, "up").
+		Times(4)
+
+	c := sihttp.NewClient(&http.Client{}, sihttp.WithBaseUrl(s.URL), sihttp.WithRetryPolicy(&sihttp.BackoffRetryPolicy{
+		MaxRetries:      3,
+		InitialInterval: time.Millisecond,
+	}))
+	b, err := c.Get("/flaky", nil, nil)
+	require.Nil(t, err)
+	assert.EqualValues(t, "up", string(b))
+	assert.EqualValues(t, 3, e.Calls())
+
+	// the last response is repeated
+	b, err = c.Get("/flaky", nil, nil)
+	require.Nil(t, err)
+	assert.EqualValues(t, "up", string(b))
+
+	s.AssertExpectations(t)
+
+	// Times limits matches
+	_, err = c.Get("/flaky", nil, nil)
+	require.NotNil(t, err)
+	assert.NotNil(t, s.ExpectationsWereMet())
+}
+
+func TestServer_Delay(t *testing.T) {
+	s := NewServer(t)
+	s.Expect(http.MethodGet, "/slow").Respond(http.StatusOK, "slow").WithDelay(200 * time.Millisecond)
+	s.Expect(http.MethodGet, "/fast").RespondWith(_textResponse("fast"))
+
+	c := newClient(s.URL)
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	_, err := c.GetContext(ctx, "/slow", nil, nil)
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+
+	b, err := c.Get("/fast", nil, nil)
+	require.Nil(t, err)
+	assert.EqualValues(t, "fast", string(b))
+}
+
+func _textResponse(body string) Response {
+	return Response{Status: http.StatusOK, Body: []byte(body)}
+}
+
+func Test_jsonContains(t *testing.T) {
+	actual := map[string]any{"a": 1.0, "b": map[string]any{"c": "d", "e": []any{1.0, 2.0}}}
+	assert.True(t, jsonContains(actual, map[string]any{}))
+	assert.True(t, jsonContains(actual, map[string]any{"b": map[string]any{"c": "d"}}))
+	assert.True(t, jsonContains(actual, map[string]any{"b": map[string]any{"e": []any{1.0, 2.0}}}))
+	assert.False(t, jsonContains(actual, map[string]any{"b": map[string]any{"e": []any{1.0}}}))
+	assert.False(t, jsonContains(actual, map[string]any{"x": nil}))
+	assert.False(t, jsonContains(actual, map[string]any{"a": "1"}))
+	assert.False(t, jsonContains("a", map[string]any{}))
+}
@@ -0,0 +1,344 @@
+package sihttp
+
+import (
+	"bytes"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/wonksing/si/v2/sio"
+)
+
+// ErrInvalidEvent is returned by EventWriter.Send for an event it can't write.
+var ErrInvalidEvent = errors.New("sihttp: id or event name of an event has a new line")
+
+// Event is an event of server-sent events.
+type Event struct {
+	// Id is sent back by clients in Last-Event-ID header when they reconnect.
+	Id string
+	// Event is the name of the event. Clients take it as "message" if empty.
+	Event string
+	// Data is the payload. EventWriter writes []byte and string as they are, and encodes others.
+	// It is a string in events read by EventStream.
+	Data any
+	// Retry is how long clients wait before reconnecting.
+	Retry time.Duration
+}
+
+// ReplayBuffer keeps events, so that EventWriter can replay ones a client missed while reconnecting.
+type ReplayBuffer interface {
+	// Add keeps ev, and returns it with an id assigned if it has none.
+	Add(ev Event) (Event, error)
+	// Since returns events added after the one of id in order.
+	Since(id string) ([]Event, error)
+}
+
+// MemoryReplayBuffer is a ReplayBuffer that keeps the latest events in memory.
+// Ids it assigns are sequence numbers.
+type MemoryReplayBuffer struct {
+	mu     sync.Mutex
+	size   int
+	seq    uint64
+	events []Event
+}
+
+// NewMemoryReplayBuffer returns a MemoryReplayBuffer keeping up to size events.
+func NewMemoryReplayBuffer(size int) *MemoryReplayBuffer {
+	if size <= 0 {
+		size = 1
+	}
+	return &MemoryReplayBuffer{size: size}
+}
+
+// Add implements ReplayBuffer. The oldest event is dropped when the buffer is full.
+func (b *MemoryReplayBuffer) Add(ev Event) (Event, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.seq++
+	if ev.Id == "" {
+		ev.Id = strconv.FormatUint(b.seq, 10)
+	}
+	b.events = append(b.events, ev)
+	if len(b.events) > b.size {
+		b.events = b.events[len(b.events)-b.size:]
+	}
+	return ev, nil
+}
+
+// Since implements ReplayBuffer. It returns every event it keeps if id has been dropped or is unknown.
+func (b *MemoryReplayBuffer) Since(id string) ([]Event, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	start := 0
+	for i := len(b.events) - 1; i >= 0; i-- {
+		if b.events[i].Id == id {
+			start = i + 1
+			break
+		}
+	}
+	events := make([]Event, len(b.events)-start)
+	copy(events, b.events[start:])
+	return events, nil
+}
+
+// EventWriterOption is an option of NewEventWriter.
+type EventWriterOption interface {
+	apply(c *eventWriterConfig)
+}
+
+// EventWriterOptionFunc wraps a function to conforms to EventWriterOption interface.
+type EventWriterOptionFunc func(c *eventWriterConfig)
+
+func (o EventWriterOptionFunc) apply(c *eventWriterConfig) {
+	o(c)
+}
+
+type eventWriterConfig struct {
+	keepAlive  time.Duration
+	retry      time.Duration
+	replay     ReplayBuffer
+	writerOpts []sio.WriterOption
+}
+
+// WithEventKeepAlive writes a comment every d, so that proxies don't close the connection while it is idle.
+func WithEventKeepAlive(d time.Duration) EventWriterOptionFunc {
+	return EventWriterOptionFunc(func(c *eventWriterConfig) {
+		c.keepAlive = d
+	})
+}
+
+// WithEventRetryHint tells clients to wait d before reconnecting.
+func WithEventRetryHint(d time.Duration) EventWriterOptionFunc {
+	return EventWriterOptionFunc(func(c *eventWriterConfig) {
+		c.retry = d
+	})
+}
+
+// WithEventReplay replays events of buf after the one of Last-Event-ID header of the request.
+func WithEventReplay(buf ReplayBuffer) EventWriterOptionFunc {
+	return EventWriterOptionFunc(func(c *eventWriterConfig) {
+		c.replay = buf
+	})
+}
+
+// WithEventWriterOpt sets an option of sio.Writer encoding Data of events, which is encoded to json by default.
+func WithEventWriterOpt(opt sio.WriterOption) EventWriterOptionFunc {
+	return EventWriterOptionFunc(func(c *eventWriterConfig) {
+		c.writerOpts = append(c.writerOpts, opt)
+	})
+}
+
+// EventWriter writes server-sent events to a response. It is safe for concurrent use.
+//
+//	ew, err := sihttp.NewEventWriter(w, r, sihttp.WithEventKeepAlive(15*time.Second))
+//	if err != nil {
+//		return
+//	}
+//	defer ew.Close()
+//	for ev := range events {
+//		if err := ew.Send(ev); err != nil {
+//			return
+//		}
+//	}
+type EventWriter struct {
+	w          http.ResponseWriter
+	rc         *http.ResponseController
+	writerOpts []sio.WriterOption
+
+	mu  sync.Mutex
+	err error
+
+	done      chan struct{}
+	closeOnce sync.Once
+	wg        sync.WaitGroup
+}
+
+// NewEventWriter writes the header of an event stream to w, and replays events the client missed if
+// WithEventReplay is set. It fails if w can't be flushed. The write deadline of the server is cleared
+// as the response lasts as long as the stream.
+//
+// Close must be called before the handler returns.
+func NewEventWriter(w http.ResponseWriter, r *http.Request, opts ...EventWriterOption) (*EventWriter, error) {
+	conf := eventWriterConfig{}
+	for _, o := range opts {
+		if o == nil {
+			continue
+		}
+		o.apply(&conf)
+	}
+	if len(conf.writerOpts) == 0 {
+		conf.writerOpts = _jsonWriterOpts
+	}
+
+	ew := &EventWriter{
+		w:          w,
+		rc:         http.NewResponseController(w),
+		writerOpts: conf.writerOpts,
+		done:       make(chan struct{}),
+	}
+	if err := ew.rc.SetWriteDeadline(time.Time{}); err != nil && !errors.Is(err, http.ErrNotSupported) {
+		return nil, err
+	}
+
+	h := w.Header()
+	h.Set("Content-Type", "text/event-stream")
+	h.Set("Cache-Control", "no-cache")
+	h.Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+	if err := ew.rc.Flush(); err != nil {
+		return nil, err
+	}
+
+	if conf.retry > 0 {
+		if err := ew.Send(Event{Retry: conf.retry}); err != nil {
+			return nil, err
+		}
+	}
+	if lastId := r.Header.Get("Last-Event-ID"); conf.replay != nil && lastId != "" {
+		events, err := conf.replay.Since(lastId)
+		if err != nil {
+			return nil, err
+		}
+		for _, ev := range events {
+			if err := ew.Send(ev); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	if conf.keepAlive > 0 {
+		ew.wg.Add(1)
+		go ew.keepAlive(r, conf.keepAlive)
+	}
+	return ew, nil
+}
+
+// Send writes ev and flushes it.
+func (ew *EventWriter) Send(ev Event) error {
+	if strings.ContainsAny(ev.Id, "\r\n") || strings.ContainsAny(ev.Event, "\r\n") {
+		return ErrInvalidEvent
+	}
+
+	buf := sio.GetBytesBuffer(nil)
+	defer sio.PutBytesBuffer(buf)
+
+	if ev.Id != "" {
+		buf.WriteString("id: " + ev.Id + "\n")
+	}
+	if ev.Event != "" {
+		buf.WriteString("event: " + ev.Event + "\n")
+	}
+	if ev.Retry > 0 {
+		buf.WriteString("retry: " + strconv.FormatInt(ev.Retry.Milliseconds(), 10) + "\n")
+	}
+	if ev.Data != nil {
+		data, err := ew.encode(ev.Data)
+		if err != nil {
+			return err
+		}
+		for _, line := range splitLines(data) {
+			buf.WriteString("data: ")
+			buf.Write(line)
+			buf.WriteByte('\n')
+		}
+	}
+	buf.WriteByte('\n')
+
+	return ew.write(buf.Bytes())
+}
+
+// encode returns data encoded by a pooled sio.Writer. The trailing new line of json is trimmed.
+func (ew *EventWriter) encode(data any) ([]byte, error) {
+	switch d := data.(type) {
+	case []byte:
+		return d, nil
+	case string:
+		return []byte(d), nil
+	}
+
+	sw, buf := sio.GetWriterAndBuffer(ew.writerOpts...)
+	defer sio.PutWriterAndBuffer(sw, buf)
+	if err := sw.EncodeFlush(data); err != nil {
+		return nil, err
+	}
+	encoded := bytes.TrimSuffix(buf.Bytes(), []byte{'\n'})
+	return append([]byte(nil), encoded...), nil
+}
+
+// Comment writes a comment, which clients ignore.
+func (ew *EventWriter) Comment(text string) error {
+	buf := sio.GetBytesBuffer(nil)
+	defer sio.PutBytesBuffer(buf)
+	for _, line := range splitLines([]byte(text)) {
+		buf.WriteString(": ")
+		buf.Write(line)
+		buf.WriteByte('\n')
+	}
+	buf.WriteByte('\n')
+	return ew.write(buf.Bytes())
+}
+
+// splitLines splits b at line ends of event streams, which are "\r\n", "\r" and "\n".
+func splitLines(b []byte) [][]byte {
+	var lines [][]byte
+	for {
+		i := bytes.IndexAny(b, "\r\n")
+		if i < 0 {
+			return append(lines, b)
+		}
+		lines = append(lines, b[:i])
+		if b[i] == '\r' && i+1 < len(b) && b[i+1] == '\n' {
+			i++
+		}
+		b = b[i+1:]
+	}
+}
+
+func (ew *EventWriter) write(b []byte) error {
+	ew.mu.Lock()
+	defer ew.mu.Unlock()
+	if ew.err != nil {
+		return ew.err
+	}
+	if _, err := ew.w.Write(b); err != nil {
+		ew.err = err
+		return err
+	}
+	if err := ew.rc.Flush(); err != nil {
+		ew.err = err
+		return err
+	}
+	return nil
+}
+
+func (ew *EventWriter) keepAlive(r *http.Request, d time.Duration) {
+	defer ew.wg.Done()
+	ticker := time.NewTicker(d)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			if err := ew.write([]byte(": keep-alive\n\n")); err != nil {
+				return
+			}
+		case <-r.Context().Done():
+			return
+		case <-ew.done:
+			return
+		}
+	}
+}
+
+// Close stops writing keep-alive comments. It doesn't close the connection, which is closed when the handler returns.
+func (ew *EventWriter) Close() error {
+	ew.closeOnce.Do(func() {
+		close(ew.done)
+	})
+	ew.wg.Wait()
+	return nil
+}
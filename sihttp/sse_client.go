@@ -0,0 +1,326 @@
+package sihttp
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/wonksing/si/v2/sio"
+)
+
+const defaultStreamRetry = 3 * time.Second
+
+// EventStreamOption is an option of Client.Events.
+type EventStreamOption interface {
+	apply(c *eventStreamConfig)
+}
+
+// EventStreamOptionFunc wraps a function to conforms to EventStreamOption interface.
+type EventStreamOptionFunc func(c *eventStreamConfig)
+
+func (o EventStreamOptionFunc) apply(c *eventStreamConfig) {
+	o(c)
+}
+
+type eventStreamConfig struct {
+	retry      time.Duration
+	maxRetries int
+	lastId     string
+	opts       []RequestOption
+}
+
+// WithStreamRetry sets how long EventStream waits before reconnecting until the server sends a retry hint.
+// It is 3 seconds by default.
+func WithStreamRetry(d time.Duration) EventStreamOptionFunc {
+	return EventStreamOptionFunc(func(c *eventStreamConfig) {
+		c.retry = d
+	})
+}
+
+// WithStreamMaxRetries limits consecutive reconnections that fail. EventStream reconnects forever by default.
+func WithStreamMaxRetries(n int) EventStreamOptionFunc {
+	return EventStreamOptionFunc(func(c *eventStreamConfig) {
+		c.maxRetries = n
+	})
+}
+
+// WithStreamLastEventId resumes the stream after the event of id.
+func WithStreamLastEventId(id string) EventStreamOptionFunc {
+	return EventStreamOptionFunc(func(c *eventStreamConfig) {
+		c.lastId = id
+	})
+}
+
+// WithStreamRequestOpt applies opt to every request of the stream.
+func WithStreamRequestOpt(opt RequestOption) EventStreamOptionFunc {
+	return EventStreamOptionFunc(func(c *eventStreamConfig) {
+		c.opts = append(c.opts, opt)
+	})
+}
+
+// EventStream reads server-sent events. When the connection is lost, it reconnects with Last-Event-ID header
+// of the last event it has read, waiting as long as the server hinted.
+//
+//	s := client.Events(ctx, "/events")
+//	defer s.Close()
+//	for s.Next() {
+//		var status Status
+//		if err := s.Decode(&status); err != nil {
+//			...
+//		}
+//	}
+//	if err := s.Err(); err != nil {
+//		...
+//	}
+type EventStream struct {
+	hc     *Client
+	url    string
+	conf   eventStreamConfig
+	ctx    context.Context
+	cancel context.CancelFunc
+
+	resp *http.Response
+	r    *sio.Reader
+
+	lastId  string
+	retry   time.Duration
+	retries int
+	event   Event
+	err     error
+}
+
+// Events returns an EventStream of url. It connects on the first call to Next.
+func (hc *Client) Events(ctx context.Context, url string, opts ...EventStreamOption) *EventStream {
+	conf := eventStreamConfig{retry: defaultStreamRetry}
+	for _, o := range opts {
+		if o == nil {
+			continue
+		}
+		o.apply(&conf)
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	return &EventStream{
+		hc:     hc,
+		url:    hc.baseUrl + url,
+		conf:   conf,
+		ctx:    ctx,
+		cancel: cancel,
+		lastId: conf.lastId,
+		retry:  conf.retry,
+	}
+}
+
+// Next reads the next event, reconnecting if needed. It returns false when the stream ends,
+// the server responds with 204, or it fails. Err returns the error then.
+func (s *EventStream) Next() bool {
+	for s.err == nil {
+		if s.r == nil {
+			err := s.connect()
+			if err == nil {
+				continue
+			}
+			if !s.wait(err) {
+				return false
+			}
+			continue
+		}
+
+		ev, err := s.read()
+		if err == nil {
+			s.event = ev
+			return true
+		}
+		s.disconnect()
+		if !s.wait(err) {
+			return false
+		}
+	}
+	return false
+}
+
+// Event returns the event read by Next. Its Data is a string.
+func (s *EventStream) Event() Event {
+	return s.event
+}
+
+// Decode decodes the data of the event read by Next into v with the reader options of the Client.
+func (s *EventStream) Decode(v any) error {
+	data, _ := s.event.Data.(string)
+	r := sio.GetReader(strings.NewReader(data), s.hc.readerOpts...)
+	defer sio.PutReader(r)
+	return r.Decode(v)
+}
+
+// LastEventId returns the id of the last event, which is sent when reconnecting.
+func (s *EventStream) LastEventId() string {
+	return s.lastId
+}
+
+// Err returns the error that ended the stream. It is nil if the server ended it with 204.
+func (s *EventStream) Err() error {
+	if errors.Is(s.err, io.EOF) {
+		return nil
+	}
+	return s.err
+}
+
+// Close closes the stream.
+func (s *EventStream) Close() error {
+	s.cancel()
+	s.disconnect()
+	if s.err == nil {
+		s.err = io.EOF
+	}
+	return nil
+}
+
+// errStreamEnded is a permanent error wrapping an error not to retry.
+type errStreamEnded struct {
+	err error
+}
+
+func (e *errStreamEnded) Error() string {
+	return e.err.Error()
+}
+
+func (e *errStreamEnded) Unwrap() error {
+	return e.err
+}
+
+func (s *EventStream) connect() error {
+	req, err := http.NewRequestWithContext(s.ctx, http.MethodGet, s.url, nil)
+	if err != nil {
+		return &errStreamEnded{err}
+	}
+	req.Header.Set("Accept", "text/event-stream")
+	// no-store keeps the response out of the cache of WithCache, which would read it to the end
+	req.Header.Set("Cache-Control", "no-store")
+	if s.lastId != "" {
+		req.Header.Set("Last-Event-ID", s.lastId)
+	}
+	if err := ApplyRequestOptions(req, s.hc.requestOpts...); err != nil {
+		return &errStreamEnded{err}
+	}
+	if err := ApplyRequestOptions(req, s.conf.opts...); err != nil {
+		return &errStreamEnded{err}
+	}
+
+	resp, tr, err := s.hc.doTrace(req)
+	if err != nil {
+		return err
+	}
+	switch {
+	case resp.StatusCode == http.StatusNoContent:
+		resp.Body.Close()
+		return &errStreamEnded{io.EOF}
+	case resp.StatusCode < 200 || resp.StatusCode > 299:
+		err := tr.readError(resp, s.hc.readerOpts)
+		resp.Body.Close()
+		if IsTemporary(err) {
+			return err
+		}
+		return &errStreamEnded{err}
+	}
+	if mediaType, _, _ := mime.ParseMediaType(resp.Header.Get("Content-Type")); mediaType != "text/event-stream" {
+		resp.Body.Close()
+		return &errStreamEnded{fmt.Errorf("unexpected Content-Type %q of event stream", resp.Header.Get("Content-Type"))}
+	}
+
+	s.resp = resp
+	s.r = sio.GetReader(resp.Body)
+	s.retries = 0
+	return nil
+}
+
+func (s *EventStream) disconnect() {
+	if s.r == nil {
+		return
+	}
+	sio.PutReader(s.r)
+	s.r = nil
+	s.resp.Body.Close()
+	s.resp = nil
+}
+
+// wait waits before reconnecting, and reports whether to reconnect. Otherwise, it sets err to s.err.
+func (s *EventStream) wait(err error) bool {
+	var ended *errStreamEnded
+	switch {
+	case s.ctx.Err() != nil:
+		if s.err == nil {
+			s.err = s.ctx.Err()
+		}
+		return false
+	case errors.As(err, &ended):
+		s.err = ended.err
+		return false
+	case s.conf.maxRetries > 0 && s.retries >= s.conf.maxRetries:
+		s.err = err
+		return false
+	}
+
+	s.retries++
+	if err := sleepContext(s.ctx, s.retry); err != nil {
+		s.err = err
+		return false
+	}
+	return true
+}
+
+// read reads lines until an event is dispatched as described in the HTML standard.
+func (s *EventStream) read() (Event, error) {
+	var ev Event
+	var data bytes.Buffer
+	hasData := false
+	for {
+		line, err := s.r.ReadBytes('\n')
+		if err != nil {
+			// an event without the last blank line is discarded
+			if err == io.EOF {
+				err = io.ErrUnexpectedEOF
+			}
+			return Event{}, err
+		}
+		line = bytes.TrimSuffix(bytes.TrimSuffix(line, []byte{'\n'}), []byte{'\r'})
+
+		if len(line) == 0 {
+			if !hasData {
+				ev = Event{}
+				continue
+			}
+			ev.Id = s.lastId
+			ev.Data = strings.TrimSuffix(data.String(), "\n")
+			return ev, nil
+		}
+		if line[0] == ':' {
+			continue
+		}
+
+		field, value, _ := bytes.Cut(line, []byte{':'})
+		value = bytes.TrimPrefix(value, []byte{' '})
+		switch string(field) {
+		case "event":
+			ev.Event = string(value)
+		case "data":
+			hasData = true
+			data.Write(value)
+			data.WriteByte('\n')
+		case "id":
+			if bytes.IndexByte(value, 0) < 0 {
+				s.lastId = string(value)
+			}
+		case "retry":
+			if ms, err := strconv.ParseUint(string(value), 10, 63); err == nil {
+				s.retry = time.Duration(ms) * time.Millisecond
+			}
+		}
+	}
+}
@@ -0,0 +1,165 @@
+package sihttp
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/wonksing/si/v2/sio"
+)
+
+type _status struct {
+	Name string `json:"name"`
+	Age  int    `json:"age"`
+}
+
+func TestEventWriter(t *testing.T) {
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest(http.MethodGet, "/events", nil)
+	ew, err := NewEventWriter(w, r, WithEventRetryHint(time.Second))
+	require.Nil(t, err)
+	defer ew.Close()
+
+	require.Nil(t, ew.Send(Event{Id: "1", Event: "status", Data: _status{Name: "wonk", Age: 20}}))
+	require.Nil(t, ew.Send(Event{Data: "line1\nline2"}))
+	require.Nil(t, ew.Comment("ping"))
+	assert.ErrorIs(t, ew.Send(Event{Id: "1\n2"}), ErrInvalidEvent)
+	// a bare carriage return ends a line as well, so that it can't inject fields
+	require.Nil(t, ew.Send(Event{Data: "x\rid: 9\r\ny"}))
+	require.Nil(t, ew.Comment("a\rid: 9"))
+
+	assert.EqualValues(t, "text/event-stream", w.Header().Get("Content-Type"))
+	assert.EqualValues(t, "retry: 1000\n\n"+
+		"id: 1\nevent: status\ndata: {\"name\":\"wonk\",\"age\":20}\n\n"+
+		"data: line1\ndata: line2\n\n"+
+		": ping\n\n"+
+		"data: x\ndata: id: 9\ndata: y\n\n"+
+		": a\n: id: 9\n\n", w.Body.String())
+}
+
+func TestMemoryReplayBuffer(t *testing.T) {
+	buf := NewMemoryReplayBuffer(3)
+	for i := 0; i < 5; i++ {
+		ev, err := buf.Add(Event{Data: i})
+		require.Nil(t, err)
+		assert.EqualValues(t, fmt.Sprint(i+1), ev.Id)
+	}
+
+	events, _ := buf.Since("4")
+	require.Len(t, events, 1)
+	assert.EqualValues(t, 4, events[0].Data)
+
+	// dropped
+	events, _ = buf.Since("1")
+	assert.Len(t, events, 3)
+	events, _ = buf.Since("5")
+	assert.Len(t, events, 0)
+}
+
+func TestClient_Events(t *testing.T) {
+	buf := NewMemoryReplayBuffer(10)
+	for i := 1; i <= 4; i++ {
+		buf.Add(Event{Event: "status", Data: _status{Name: "wonk", Age: i}})
+	}
+
+	var connections atomic.Int32
+	var lastIds []string
+	svr := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := connections.Add(1)
+		lastIds = append(lastIds, r.Header.Get("Last-Event-ID"))
+		if n == 3 {
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+		ew, err := NewEventWriter(w, r, WithEventReplay(buf), WithEventRetryHint(10*time.Millisecond),
+			WithEventKeepAlive(5*time.Millisecond))
+		require.Nil(t, err)
+		defer ew.Close()
+
+		time.Sleep(20 * time.Millisecond)
+		if r.Header.Get("Last-Event-ID") == "" {
+			// the connection is closed after two events
+			events, _ := buf.Since("")
+			ew.Send(events[0])
+			ew.Send(events[1])
+		}
+	}))
+	defer svr.Close()
+
+	c := NewClient(_newStandardClient(), WithBaseUrl(svr.URL), WithReaderOpt(sio.SetJsonDecoder()))
+	s := c.Events(context.Background(), "/events", WithStreamRetry(time.Hour))
+	defer s.Close()
+
+	var ages []int
+	for s.Next() {
+		assert.EqualValues(t, "status", s.Event().Event)
+		var v _status
+		require.Nil(t, s.Decode(&v))
+		ages = append(ages, v.Age)
+	}
+	require.Nil(t, s.Err())
+	assert.EqualValues(t, []int{1, 2, 3, 4}, ages)
+	assert.EqualValues(t, []string{"", "2", "4"}, lastIds)
+	assert.EqualValues(t, "4", s.LastEventId())
+}
+
+func TestClient_Events_WithCache(t *testing.T) {
+	svr := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ew, err := NewEventWriter(w, r)
+		require.Nil(t, err)
+		defer ew.Close()
+		ew.Send(Event{Data: "hello"})
+		<-r.Context().Done()
+	}))
+	defer svr.Close()
+
+	c := NewClient(_newStandardClient(), WithBaseUrl(svr.URL), WithCache(NewLRUCacheStore(10)))
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	s := c.Events(ctx, "/events")
+	defer s.Close()
+
+	require.True(t, s.Next(), s.Err())
+	assert.EqualValues(t, "hello", s.Event().Data)
+}
+
+func TestClient_Events_Error(t *testing.T) {
+	svr := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/text" {
+			w.Write([]byte("text"))
+			return
+		}
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer svr.Close()
+
+	c := NewClient(_newStandardClient(), WithBaseUrl(svr.URL))
+	s := c.Events(context.Background(), "/text")
+	assert.False(t, s.Next())
+	assert.True(t, strings.Contains(s.Err().Error(), "Content-Type"))
+
+	s = c.Events(context.Background(), "/down", WithStreamRetry(time.Millisecond), WithStreamMaxRetries(2))
+	assert.False(t, s.Next())
+	assert.True(t, IsServerError(s.Err()))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	s = c.Events(ctx, "/down")
+	assert.False(t, s.Next())
+	assert.ErrorIs(t, s.Err(), context.Canceled)
+
+	errToken := errors.New("no token")
+	s = c.Events(context.Background(), "/down", WithStreamRequestOpt(RequestOptionFunc(func(req *http.Request) error {
+		return errToken
+	})))
+	assert.False(t, s.Next())
+	assert.ErrorIs(t, s.Err(), errToken)
+}
@@ -0,0 +1,410 @@
+package sihttp
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/textproto"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/wonksing/si/v2/sio"
+)
+
+var (
+	// ErrSizeMismatch is returned when the size of a downloaded content differs from the expected one.
+	ErrSizeMismatch = errors.New("size mismatch")
+	// ErrChecksumMismatch is returned when the checksum of a downloaded content differs from the expected one.
+	ErrChecksumMismatch = errors.New("checksum mismatch")
+)
+
+// FormPart is a part of a multipart/form-data body.
+type FormPart struct {
+	// Name is the name of the form field.
+	Name string
+	// FileName makes the part a file. It is sent as the filename of Content-Disposition.
+	FileName string
+	// ContentType is the Content-Type of the part. It defaults to application/octet-stream for files.
+	ContentType string
+
+	// Content is read to the end to write the part. It is closed after it if it is an io.Closer.
+	Content io.Reader
+	// Path is a file opened when the part is written if Content is nil.
+	Path string
+}
+
+// FormField returns a FormPart of a form field.
+func FormField(name, value string) FormPart {
+	return FormPart{Name: name, Content: strings.NewReader(value)}
+}
+
+// FormFile returns a FormPart of a file read from r.
+func FormFile(name, fileName, contentType string, r io.Reader) FormPart {
+	return FormPart{Name: name, FileName: fileName, ContentType: contentType, Content: r}
+}
+
+// FormFilePath returns a FormPart of a file at path, which is opened when the part is written.
+func FormFilePath(name, path, contentType string) FormPart {
+	return FormPart{Name: name, FileName: filepath.Base(path), ContentType: contentType, Path: path}
+}
+
+var _quoteEscaper = strings.NewReplacer("\\", "\\\\", `"`, "\\\"")
+
+func (p *FormPart) header() textproto.MIMEHeader {
+	h := make(textproto.MIMEHeader)
+	disposition := `form-data; name="` + _quoteEscaper.Replace(p.Name) + `"`
+	if p.FileName != "" {
+		disposition += `; filename="` + _quoteEscaper.Replace(p.FileName) + `"`
+	}
+	h.Set("Content-Disposition", disposition)
+
+	contentType := p.ContentType
+	if contentType == "" && p.FileName != "" {
+		contentType = "application/octet-stream"
+	}
+	if contentType != "" {
+		h.Set("Content-Type", contentType)
+	}
+	return h
+}
+
+func (p *FormPart) writeTo(mw *multipart.Writer) error {
+	r := p.Content
+	if r == nil {
+		f, err := os.Open(p.Path)
+		if err != nil {
+			return err
+		}
+		r = f
+	}
+	if c, ok := r.(io.Closer); ok {
+		defer c.Close()
+	}
+
+	w, err := mw.CreatePart(p.header())
+	if err != nil {
+		return err
+	}
+
+	sr := sio.GetReader(r)
+	defer sio.PutReader(sr)
+	_, err = sr.WriteTo(w)
+	return err
+}
+
+// writeMultipart writes parts to pw then closes it with the error occurred, if any.
+func writeMultipart(pw *io.PipeWriter, mw *multipart.Writer, parts []FormPart) {
+	var err error
+	for i := range parts {
+		if err = parts[i].writeTo(mw); err != nil {
+			closeParts(parts[i+1:])
+			break
+		}
+	}
+	if err == nil {
+		err = mw.Close()
+	}
+	pw.CloseWithError(err)
+}
+
+// closeParts closes the contents of parts that have not been written.
+func closeParts(parts []FormPart) {
+	for _, p := range parts {
+		if c, ok := p.Content.(io.Closer); ok {
+			c.Close()
+		}
+	}
+}
+
+// PostMultipart sends parts as a multipart/form-data body. See PostMultipartContext.
+func (hc *Client) PostMultipart(url string, header http.Header, parts []FormPart, opts ...RequestOption) ([]byte, error) {
+	return hc.PostMultipartContext(context.Background(), url, header, parts, opts...)
+}
+
+// PostMultipartContext sends parts as a multipart/form-data body.
+//
+// The body is streamed through a pipe as it is sent, so that files are not loaded into memory.
+// Consequently the request is not retried after its body has been sent. Options reading the body,
+// such as WithHeaderSignature, load the whole body into memory.
+func (hc *Client) PostMultipartContext(ctx context.Context, url string, header http.Header, parts []FormPart,
+	opts ...RequestOption) ([]byte, error) {
+
+	pr, pw := io.Pipe()
+	mw := multipart.NewWriter(pw)
+
+	// set Content-Type, overwrite existing Content-Type
+	h := make(http.Header, len(header)+1)
+	for k, v := range header {
+		h[k] = v
+	}
+	h["Content-Type"] = []string{mw.FormDataContentType()}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, hc.baseUrl+url, pr)
+	if err != nil {
+		closeParts(parts)
+		return nil, err
+	}
+	setHeader(req, h)
+
+	// the writer is started before options are applied, since options signing the body read it.
+	// the pipe is closed by the transport when it is done with the body, which unblocks the writer.
+	// it is closed here as well in case an option fails or a middleware doesn't send the body.
+	go writeMultipart(pw, mw, parts)
+	defer pr.Close()
+
+	if err := ApplyRequestOptions(req, hc.requestOpts...); err != nil {
+		return nil, err
+	}
+	if err := ApplyRequestOptions(req, opts...); err != nil {
+		return nil, err
+	}
+
+	return hc.DoRead(req)
+}
+
+// DownloadOption is an option of DownloadTo.
+type DownloadOption interface {
+	apply(c *downloadConfig)
+}
+
+// DownloadOptionFunc wraps a function to conforms to DownloadOption interface.
+type DownloadOptionFunc func(c *downloadConfig)
+
+func (o DownloadOptionFunc) apply(c *downloadConfig) {
+	o(c)
+}
+
+type downloadConfig struct {
+	offset     int64
+	maxResumes int
+	size       int64
+	hash       hash.Hash
+	checksum   []byte
+	progress   func(done, total int64)
+	opts       []RequestOption
+}
+
+// WithDownloadOffset starts downloading from offset with a Range request, eg. to resume a partially downloaded file.
+// If the server ignores the range, the first offset bytes of the content are skipped.
+func WithDownloadOffset(offset int64) DownloadOptionFunc {
+	return DownloadOptionFunc(func(c *downloadConfig) {
+		c.offset = offset
+	})
+}
+
+// WithDownloadResumes resumes downloading with a Range request from where it stopped
+// when reading the response body fails, up to n times.
+func WithDownloadResumes(n int) DownloadOptionFunc {
+	return DownloadOptionFunc(func(c *downloadConfig) {
+		c.maxResumes = n
+	})
+}
+
+// WithDownloadSize verifies that the size of the content, including the offset, is size.
+func WithDownloadSize(size int64) DownloadOptionFunc {
+	return DownloadOptionFunc(func(c *downloadConfig) {
+		c.size = size
+	})
+}
+
+// WithDownloadChecksum verifies that h of the downloaded bytes is checksum.
+// Only bytes downloaded by DownloadTo are written to h, so write the bytes before the offset to it in advance
+// when resuming with WithDownloadOffset.
+func WithDownloadChecksum(h hash.Hash, checksum []byte) DownloadOptionFunc {
+	return DownloadOptionFunc(func(c *downloadConfig) {
+		c.hash = h
+		c.checksum = checksum
+	})
+}
+
+// WithDownloadProgress calls progress whenever bytes are written.
+// done is the number of bytes of the content including the offset, and total is the size of the content or -1 if unknown.
+func WithDownloadProgress(progress func(done, total int64)) DownloadOptionFunc {
+	return DownloadOptionFunc(func(c *downloadConfig) {
+		c.progress = progress
+	})
+}
+
+// WithDownloadRequestOpt applies opt to download requests.
+func WithDownloadRequestOpt(opt RequestOption) DownloadOptionFunc {
+	return DownloadOptionFunc(func(c *downloadConfig) {
+		c.opts = append(c.opts, opt)
+	})
+}
+
+// downloadWriter writes to w while counting, hashing and reporting progress.
+type downloadWriter struct {
+	w        io.Writer
+	hash     hash.Hash
+	progress func(done, total int64)
+
+	offset  int64
+	written int64
+	total   int64
+	err     error
+}
+
+func (dw *downloadWriter) Write(p []byte) (int, error) {
+	n, err := dw.w.Write(p)
+	if n > 0 {
+		if dw.hash != nil {
+			dw.hash.Write(p[:n])
+		}
+		dw.written += int64(n)
+		if dw.progress != nil {
+			dw.progress(dw.offset+dw.written, dw.total)
+		}
+	}
+	if err != nil {
+		dw.err = err
+	}
+	return n, err
+}
+
+// DownloadTo downloads the content of url into w and returns the number of bytes written.
+func (hc *Client) DownloadTo(ctx context.Context, url string, w io.Writer, opts ...DownloadOption) (int64, error) {
+	conf := &downloadConfig{}
+	for _, o := range opts {
+		if o == nil {
+			continue
+		}
+		o.apply(conf)
+	}
+
+	dw := &downloadWriter{
+		w:        w,
+		hash:     conf.hash,
+		progress: conf.progress,
+		offset:   conf.offset,
+		total:    -1,
+	}
+	for resumes := 0; ; resumes++ {
+		resumable, err := hc.download(ctx, hc.baseUrl+url, dw, conf.opts)
+		if err == nil {
+			break
+		}
+		if !resumable || resumes >= conf.maxResumes || ctx.Err() != nil {
+			return dw.written, err
+		}
+	}
+
+	if conf.size > 0 && dw.offset+dw.written != conf.size {
+		return dw.written, fmt.Errorf("%w: expected %d bytes, got %d", ErrSizeMismatch, conf.size, dw.offset+dw.written)
+	}
+	if conf.hash != nil && !bytes.Equal(conf.hash.Sum(nil), conf.checksum) {
+		return dw.written, ErrChecksumMismatch
+	}
+	return dw.written, nil
+}
+
+// download requests the content from where dw has written, and copies it to dw.
+// It reports whether the download can be resumed when it fails.
+func (hc *Client) download(ctx context.Context, url string, dw *downloadWriter, opts []RequestOption) (bool, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return false, err
+	}
+	start := dw.offset + dw.written
+	if start > 0 {
+		req.Header.Set("Range", "bytes="+strconv.FormatInt(start, 10)+"-")
+	}
+	if err := ApplyRequestOptions(req, hc.requestOpts...); err != nil {
+		return false, err
+	}
+	if err := ApplyRequestOptions(req, opts...); err != nil {
+		return false, err
+	}
+
+	resp, tr, err := hc.doTrace(req)
+	if err != nil {
+		return false, err
+	}
+	defer resp.Body.Close()
+
+	skip := int64(0)
+	switch {
+	case resp.StatusCode == http.StatusPartialContent:
+		from, total, ok := parseContentRange(resp.Header.Get("Content-Range"))
+		if !ok || from != start {
+			return false, fmt.Errorf("unexpected Content-Range %q for offset %d", resp.Header.Get("Content-Range"), start)
+		}
+		dw.total = total
+	case resp.StatusCode == http.StatusRequestedRangeNotSatisfiable && start > 0:
+		// nothing left to download
+		_, total, ok := parseContentRange(resp.Header.Get("Content-Range"))
+		if ok && total >= 0 && total != start {
+			return false, tr.readError(resp, hc.readerOpts)
+		}
+		return false, nil
+	case resp.StatusCode >= 200 && resp.StatusCode <= 299:
+		// the range is ignored, the whole content is sent
+		skip = start
+		dw.total = resp.ContentLength
+	default:
+		return false, tr.readError(resp, hc.readerOpts)
+	}
+
+	if skip > 0 {
+		if _, err := io.CopyN(io.Discard, resp.Body, skip); err != nil {
+			return true, err
+		}
+	}
+
+	sr := sio.GetReader(resp.Body)
+	defer sio.PutReader(sr)
+	if _, err := sr.WriteTo(dw); err != nil {
+		// writer errors are not resumable
+		return dw.err == nil, err
+	}
+	return false, nil
+}
+
+// readError reads the body of resp into Error.
+func (tr *requestTrace) readError(resp *http.Response, readerOpts []sio.ReaderOption) error {
+	r := sio.GetReader(resp.Body)
+	defer sio.PutReader(r)
+
+	b, _ := r.ReadAll()
+	return tr.error(resp, b, nil, readerOpts)
+}
+
+// parseContentRange parses "bytes first-last/total" or "bytes */total". total is -1 if it is "*".
+func parseContentRange(v string) (first, total int64, ok bool) {
+	v, found := strings.CutPrefix(v, "bytes ")
+	if !found {
+		return 0, 0, false
+	}
+	rng, size, found := strings.Cut(v, "/")
+	if !found {
+		return 0, 0, false
+	}
+
+	total = -1
+	if size != "*" {
+		n, err := strconv.ParseInt(size, 10, 64)
+		if err != nil {
+			return 0, 0, false
+		}
+		total = n
+	}
+	if rng == "*" {
+		return -1, total, true
+	}
+
+	f, _, found := strings.Cut(rng, "-")
+	if !found {
+		return 0, 0, false
+	}
+	n, err := strconv.ParseInt(f, 10, 64)
+	if err != nil {
+		return 0, 0, false
+	}
+	return n, total, true
+}
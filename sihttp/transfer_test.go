@@ -0,0 +1,275 @@
+package sihttp
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/wonksing/si/v2/codec/sign"
+)
+
+func Test_Client_PostMultipart(t *testing.T) {
+	testFile, err := os.ReadFile("./tests/data/testfile.txt")
+	require.Nil(t, err)
+
+	svr := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.EqualValues(t, "yes", r.Header.Get("X-Custom"))
+		assert.EqualValues(t, -1, r.ContentLength, "body must be streamed")
+
+		if err := r.ParseMultipartForm(1024); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		assert.EqualValues(t, "v1", r.FormValue("f1"))
+		assert.EqualValues(t, `quoted "v2"`, r.FormValue("f2"))
+
+		files := r.MultipartForm.File["files"]
+		require.Len(t, files, 2)
+		assert.EqualValues(t, "a.json", files[0].Filename)
+		assert.EqualValues(t, "application/json", files[0].Header.Get("Content-Type"))
+		assert.EqualValues(t, "testfile.txt", files[1].Filename)
+		assert.EqualValues(t, "text/plain", files[1].Header.Get("Content-Type"))
+
+		raw := r.MultipartForm.File["raw"]
+		require.Len(t, raw, 1)
+		assert.EqualValues(t, "application/octet-stream", raw[0].Header.Get("Content-Type"))
+
+		f, _ := files[1].Open()
+		defer f.Close()
+		b, _ := io.ReadAll(f)
+		assert.EqualValues(t, testFile, b)
+
+		w.Write([]byte("success"))
+	}))
+	defer svr.Close()
+
+	c := NewClient(_newStandardClient(), WithBaseUrl(svr.URL))
+	res, err := c.PostMultipart("/upload", http.Header{"Content-Type": {"application/json"}}, []FormPart{
+		FormField("f1", "v1"),
+		FormField("f2", `quoted "v2"`),
+		FormFile("files", "a.json", "application/json", strings.NewReader(`{"a":1}`)),
+		FormFilePath("files", "./tests/data/testfile.txt", "text/plain"),
+		FormFile("raw", "raw.bin", "", bytes.NewReader([]byte{0, 1, 2})),
+	}, WithHeaderSet("X-Custom", "yes"))
+	require.Nil(t, err)
+	assert.EqualValues(t, "success", string(res))
+}
+
+func Test_Client_PostMultipart_FileNotFound(t *testing.T) {
+	svr := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.Copy(io.Discard, r.Body)
+	}))
+	defer svr.Close()
+
+	c := NewClient(_newStandardClient())
+	_, err := c.PostMultipart(svr.URL, nil, []FormPart{
+		FormFilePath("file", "./tests/data/not_exists.txt", ""),
+	})
+	require.NotNil(t, err)
+	assert.ErrorIs(t, err, os.ErrNotExist)
+}
+
+func Test_Client_PostMultipart_Signature(t *testing.T) {
+	key := []byte("asdf")
+	svr := httptest.NewServer(VerifySignature("X-Sig", sign.NewHmacSha256(key), sign.EncodingHex)(
+		http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if err := r.ParseMultipartForm(1024); err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			w.Write([]byte(r.FormValue("f1")))
+		})))
+	defer svr.Close()
+
+	c := NewClient(_newStandardClient(), WithBaseUrl(svr.URL))
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	res, err := c.PostMultipartContext(ctx, "/", nil, []FormPart{
+		FormField("f1", "v1"),
+		FormFile("file", "a.txt", "text/plain", strings.NewReader("hello")),
+	}, WithHeaderSignature("X-Sig", sign.NewHmacSha256(key), sign.EncodingHex))
+	require.Nil(t, err)
+	assert.EqualValues(t, "v1", string(res))
+}
+
+var _downloadContent = bytes.Repeat([]byte("0123456789abcdef"), 4096)
+
+func newDownloadServer(t *testing.T, failFirst bool) (*httptest.Server, *int) {
+	count := 0
+	svr := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		count++
+		if r.URL.Path == "/norange" {
+			w.Write(_downloadContent)
+			return
+		}
+		if failFirst && count == 1 {
+			// send half of the content then drop the connection
+			w.Header().Set("Content-Length", "65536")
+			w.Write(_downloadContent[:len(_downloadContent)/2])
+			w.(http.Flusher).Flush()
+			panic(http.ErrAbortHandler)
+		}
+		http.ServeContent(w, r, "content.bin", time.Time{}, bytes.NewReader(_downloadContent))
+	}))
+	return svr, &count
+}
+
+func Test_Client_DownloadTo(t *testing.T) {
+	svr, _ := newDownloadServer(t, false)
+	defer svr.Close()
+
+	sum := sha256.Sum256(_downloadContent)
+	var lastDone, lastTotal int64
+	buf := &bytes.Buffer{}
+	c := NewClient(_newStandardClient(), WithBaseUrl(svr.URL))
+	n, err := c.DownloadTo(context.Background(), "/content", buf,
+		WithDownloadSize(int64(len(_downloadContent))),
+		WithDownloadChecksum(sha256.New(), sum[:]),
+		WithDownloadProgress(func(done, total int64) {
+			assert.True(t, done > lastDone)
+			lastDone, lastTotal = done, total
+		}),
+	)
+	require.Nil(t, err)
+	assert.EqualValues(t, len(_downloadContent), n)
+	assert.EqualValues(t, _downloadContent, buf.Bytes())
+	assert.EqualValues(t, len(_downloadContent), lastDone)
+	assert.EqualValues(t, len(_downloadContent), lastTotal)
+}
+
+func Test_Client_DownloadTo_Offset(t *testing.T) {
+	svr, _ := newDownloadServer(t, false)
+	defer svr.Close()
+	c := NewClient(_newStandardClient(), WithBaseUrl(svr.URL))
+
+	for _, path := range []string{"/content", "/norange"} {
+		h := sha256.New()
+		h.Write(_downloadContent[:1000])
+		sum := sha256.Sum256(_downloadContent)
+
+		buf := bytes.NewBuffer(append([]byte{}, _downloadContent[:1000]...))
+		n, err := c.DownloadTo(context.Background(), path, buf,
+			WithDownloadOffset(1000),
+			WithDownloadSize(int64(len(_downloadContent))),
+			WithDownloadChecksum(h, sum[:]),
+		)
+		require.Nil(t, err, path)
+		assert.EqualValues(t, len(_downloadContent)-1000, n, path)
+		assert.EqualValues(t, _downloadContent, buf.Bytes(), path)
+	}
+
+	// already downloaded
+	n, err := c.DownloadTo(context.Background(), "/content", io.Discard, WithDownloadOffset(int64(len(_downloadContent))))
+	require.Nil(t, err)
+	assert.EqualValues(t, 0, n)
+}
+
+func Test_Client_DownloadTo_Resume(t *testing.T) {
+	svr, count := newDownloadServer(t, true)
+	defer svr.Close()
+	c := NewClient(_newStandardClient(), WithBaseUrl(svr.URL))
+
+	buf := &bytes.Buffer{}
+	_, err := c.DownloadTo(context.Background(), "/content", buf)
+	require.NotNil(t, err)
+
+	*count = 0
+	buf.Reset()
+	n, err := c.DownloadTo(context.Background(), "/content", buf, WithDownloadResumes(1))
+	require.Nil(t, err)
+	assert.EqualValues(t, 2, *count)
+	assert.EqualValues(t, len(_downloadContent), n)
+	assert.EqualValues(t, _downloadContent, buf.Bytes())
+}
+
+func Test_Client_DownloadTo_Verify(t *testing.T) {
+	svr, _ := newDownloadServer(t, false)
+	defer svr.Close()
+	c := NewClient(_newStandardClient(), WithBaseUrl(svr.URL))
+
+	_, err := c.DownloadTo(context.Background(), "/content", io.Discard, WithDownloadSize(10))
+	assert.ErrorIs(t, err, ErrSizeMismatch)
+
+	_, err = c.DownloadTo(context.Background(), "/content", io.Discard, WithDownloadChecksum(sha256.New(), []byte("wrong")))
+	assert.ErrorIs(t, err, ErrChecksumMismatch)
+}
+
+type _failingWriter struct{}
+
+func (_failingWriter) Write(p []byte) (int, error) {
+	return 0, errors.New("disk full")
+}
+
+func Test_Client_DownloadTo_Errors(t *testing.T) {
+	svr := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/missing" {
+			http.Error(w, "not here", http.StatusNotFound)
+			return
+		}
+		w.Write(_downloadContent)
+	}))
+	defer svr.Close()
+	c := NewClient(_newStandardClient(), WithBaseUrl(svr.URL))
+
+	_, err := c.DownloadTo(context.Background(), "/missing", io.Discard)
+	var e *Error
+	require.True(t, errors.As(err, &e))
+	assert.EqualValues(t, http.StatusNotFound, e.GetStatusCode(0))
+	assert.EqualValues(t, "not here\n", string(e.Body))
+
+	_, err = c.DownloadTo(context.Background(), "/content", _failingWriter{}, WithDownloadResumes(3))
+	assert.EqualError(t, err, "disk full")
+}
+
+func Test_Client_Transfer_RequestOptionError(t *testing.T) {
+	called := false
+	svr := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		called = true
+	}))
+	defer svr.Close()
+	c := NewClient(_newStandardClient(), WithBaseUrl(svr.URL))
+
+	errToken := errors.New("no token")
+	failing := RequestOptionFunc(func(req *http.Request) error {
+		return errToken
+	})
+
+	_, err := c.PostMultipart("/", nil, []FormPart{FormField("f1", "v1")}, failing)
+	assert.ErrorIs(t, err, errToken)
+
+	_, err = c.DownloadTo(context.Background(), "/content", io.Discard, WithDownloadRequestOpt(failing))
+	assert.ErrorIs(t, err, errToken)
+	assert.False(t, called, "request must not be sent when an option fails")
+}
+
+func Test_parseContentRange(t *testing.T) {
+	first, total, ok := parseContentRange("bytes 10-99/100")
+	assert.True(t, ok)
+	assert.EqualValues(t, 10, first)
+	assert.EqualValues(t, 100, total)
+
+	first, total, ok = parseContentRange("bytes 0-9/*")
+	assert.True(t, ok)
+	assert.EqualValues(t, 0, first)
+	assert.EqualValues(t, -1, total)
+
+	first, total, ok = parseContentRange("bytes */100")
+	assert.True(t, ok)
+	assert.EqualValues(t, -1, first)
+	assert.EqualValues(t, 100, total)
+
+	for _, v := range []string{"", "items 0-9/10", "bytes 0-9", "bytes a-9/10", "bytes 0-9/a", "bytes 09/10"} {
+		_, _, ok = parseContentRange(v)
+		assert.False(t, ok, v)
+	}
+}
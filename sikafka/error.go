@@ -24,3 +24,17 @@ func isRetryableError(err error) bool {
 		return false
 	}
 }
+
+// isMessageError reports whether err is caused by the message itself rather than the brokers.
+func isMessageError(err error) bool {
+	switch err {
+	case sarama.ErrMessageSizeTooLarge,
+		sarama.ErrInvalidMessage,
+		sarama.ErrInvalidMessageSize,
+		sarama.ErrInvalidTimestamp,
+		sarama.ErrInvalidRecord:
+		return true
+	default:
+		return false
+	}
+}
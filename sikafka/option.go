@@ -1,5 +1,7 @@
 package sikafka
 
+import "time"
+
 func WithSyncProducerOptionRetyMax(retryMax uint16) SyncProducerOptionFunc {
 	return SyncProducerOptionFunc(func(o *SyncProducer) error {
 		o.retryMax = retryMax
@@ -7,6 +9,14 @@ func WithSyncProducerOptionRetyMax(retryMax uint16) SyncProducerOptionFunc {
 	})
 }
 
+// WithSyncProducerOptionHealthWindow sets how long HealthCheck reports a failed produce, which is 30 seconds by default.
+func WithSyncProducerOptionHealthWindow(d time.Duration) SyncProducerOptionFunc {
+	return SyncProducerOptionFunc(func(o *SyncProducer) error {
+		o.healthWindow = d
+		return nil
+	})
+}
+
 type SyncProducerOption interface {
 	apply(o *SyncProducer) error
 }
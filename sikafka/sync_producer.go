@@ -1,21 +1,32 @@
 package sikafka
 
 import (
+	"context"
+	"sync"
 	"time"
 
 	"github.com/IBM/sarama"
 )
 
-const defaultRetryMax uint16 = 1
+const (
+	defaultRetryMax     uint16 = 1
+	defaultHealthWindow        = 30 * time.Second
+)
 
 type SyncProducer struct {
 	sarama.SyncProducer
 	topic    string
 	retryMax uint16
+
+	healthWindow time.Duration
+
+	mu        sync.Mutex
+	lastErr   error
+	lastErrAt time.Time
 }
 
 func NewSyncProducer(producer sarama.SyncProducer, topic string, opts ...SyncProducerOption) *SyncProducer {
-	p := &SyncProducer{producer, topic, defaultRetryMax}
+	p := &SyncProducer{SyncProducer: producer, topic: topic, retryMax: defaultRetryMax, healthWindow: defaultHealthWindow}
 	for _, o := range opts {
 		if o == nil {
 			continue
@@ -49,7 +60,30 @@ func (sp *SyncProducer) ProduceWithMessage(msg *sarama.ProducerMessage) (partiti
 	return
 }
 
+// HealthCheck returns the error of the last produce if it failed within the health window.
+// Errors caused by a message itself, such as sarama.ErrMessageSizeTooLarge, are not reported.
+// The error expires so that a producer taken out of traffic becomes ready again.
+func (sp *SyncProducer) HealthCheck(ctx context.Context) error {
+	sp.mu.Lock()
+	defer sp.mu.Unlock()
+	if sp.lastErr != nil && time.Since(sp.lastErrAt) < sp.healthWindow {
+		return sp.lastErr
+	}
+	return nil
+}
+
 func (sp *SyncProducer) produce(message *sarama.ProducerMessage) (int32, int64, error) {
+	partition, offset, err := sp.send(message)
+	if err == nil || !isMessageError(err) {
+		sp.mu.Lock()
+		sp.lastErr = err
+		sp.lastErrAt = time.Now()
+		sp.mu.Unlock()
+	}
+	return partition, offset, err
+}
+
+func (sp *SyncProducer) send(message *sarama.ProducerMessage) (int32, int64, error) {
 	var err error
 	var partition int32
 	var offset int64
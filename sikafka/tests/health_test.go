@@ -0,0 +1,40 @@
+package sikafka_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/IBM/sarama"
+	"github.com/IBM/sarama/mocks"
+	"github.com/stretchr/testify/assert"
+	"github.com/wonksing/si/v2/sikafka"
+)
+
+func TestSyncProducer_HealthCheck(t *testing.T) {
+	producer := mocks.NewSyncProducer(t, nil)
+	defer producer.Close()
+	sp := sikafka.NewSyncProducer(producer, "tp-test", sikafka.WithSyncProducerOptionHealthWindow(50*time.Millisecond))
+
+	// an error of the message itself doesn't fail the check
+	producer.ExpectSendMessageAndFail(sarama.ErrMessageSizeTooLarge)
+	_, _, err := sp.Produce(nil, []byte("too large"))
+	assert.ErrorIs(t, err, sarama.ErrMessageSizeTooLarge)
+	assert.Nil(t, sp.HealthCheck(context.Background()))
+
+	producer.ExpectSendMessageAndFail(sarama.ErrOutOfBrokers)
+	_, _, err = sp.Produce(nil, []byte("asdf"))
+	assert.ErrorIs(t, err, sarama.ErrOutOfBrokers)
+	assert.ErrorIs(t, sp.HealthCheck(context.Background()), sarama.ErrOutOfBrokers)
+
+	// the error expires without another produce
+	time.Sleep(60 * time.Millisecond)
+	assert.Nil(t, sp.HealthCheck(context.Background()))
+
+	producer.ExpectSendMessageAndFail(sarama.ErrOutOfBrokers)
+	sp.Produce(nil, []byte("asdf"))
+	producer.ExpectSendMessageAndSucceed()
+	_, _, err = sp.Produce(nil, []byte("asdf"))
+	assert.Nil(t, err)
+	assert.Nil(t, sp.HealthCheck(context.Background()))
+}
@@ -66,6 +66,13 @@ func SetJsonDecoder() ReaderOption {
 	})
 }
 
+// WithValidation sets r to run struct-tag validation after decoding.
+func WithValidation() ReaderOption {
+	return ReaderOptionFunc(func(r *Reader) {
+		r.SetValidation(true)
+	})
+}
+
 // RowScannerOption is an interface that wraps an apply method.
 type RowScannerOption interface {
 	apply(rs *RowScanner)
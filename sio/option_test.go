@@ -455,3 +455,11 @@ func Test_WithSqlColumnType(t *testing.T) {
 		}
 	})
 }
+
+func Test_WithValidation(t *testing.T) {
+	o := WithValidation()
+
+	r := Reader{}
+	o.apply(&r)
+	require.True(t, r.validate)
+}
@@ -10,19 +10,26 @@ import (
 )
 
 var (
-	_rowScannerPool = sync.Pool{
-		New: func() interface{} {
-			return newRowScanner()
-		},
-	}
+	_rowScannerPool = sync.Pool{}
 )
 
 func getRowScanner(opts ...RowScannerOption) *RowScanner {
-	rs := _rowScannerPool.Get().(*RowScanner)
+	g := _rowScannerPool.Get()
+	if g == nil {
+		_poolMeters[PoolRowScanner].get(true)
+		rs := newRowScanner()
+		rs.Reset(opts...)
+		return rs
+	}
+	_poolMeters[PoolRowScanner].get(false)
+	rs := g.(*RowScanner)
 	rs.Reset(opts...)
 	return rs
 }
 func putRowScanner(rs *RowScanner) {
+	if !_poolMeters[PoolRowScanner].put(len(rs.sqlCol)) {
+		return
+	}
 	rs.Reset()
 	_rowScannerPool.Put(rs)
 }
@@ -44,13 +51,18 @@ var (
 func getReader(r io.Reader, opt ...ReaderOption) *Reader {
 	g := _readerPool.Get()
 	if g == nil {
+		_poolMeters[PoolReader].get(true)
 		return newReader(r, opt...)
 	}
+	_poolMeters[PoolReader].get(false)
 	rd := g.(*Reader)
 	rd.Reset(r, opt...)
 	return rd
 }
 func putReader(r *Reader) {
+	if !_poolMeters[PoolReader].put(r.capacity()) {
+		return
+	}
 	r.Reset(nil)
 	_readerPool.Put(r)
 }
@@ -74,8 +86,10 @@ func getWriter(w io.Writer, opt ...WriterOption) *Writer {
 
 	var wr *Writer
 	if g == nil {
+		_poolMeters[PoolWriter].get(true)
 		wr = newWriter(w, opt...)
 	} else {
+		_poolMeters[PoolWriter].get(false)
 		wr = g.(*Writer)
 		wr.Reset(w, opt...)
 	}
@@ -83,6 +97,9 @@ func getWriter(w io.Writer, opt ...WriterOption) *Writer {
 }
 
 func putWriter(w *Writer) {
+	if !_poolMeters[PoolWriter].put(w.capacity()) {
+		return
+	}
 	w.Reset(nil)
 	_writerPool.Put(w)
 }
@@ -115,10 +132,12 @@ var (
 func getReadWriter(r io.Reader, w io.Writer) *ReadWriter {
 	g := _readwriterPool.Get()
 	if g == nil {
+		_poolMeters[PoolReadWriter].get(true)
 		rd := GetReader(r)
 		wr := GetWriter(w)
 		return newReadWriter(rd, wr)
 	}
+	_poolMeters[PoolReadWriter].get(false)
 	rw := g.(*ReadWriter)
 	rw.Reader.Reset(r)
 	rw.Writer.Reset(w)
@@ -127,6 +146,9 @@ func getReadWriter(r io.Reader, w io.Writer) *ReadWriter {
 }
 
 func putReadWriter(rw *ReadWriter) {
+	if !_poolMeters[PoolReadWriter].put(rw.Reader.capacity() + rw.Writer.capacity()) {
+		return
+	}
 	rw.Reader.Reset(nil)
 	rw.Writer.Reset(nil)
 	_readwriterPool.Put(rw)
@@ -180,15 +202,18 @@ func PutBytesReader(r *bytes.Reader) {
 
 var (
 	// bytes.Buffer pool
-	_bytesBufferPool = sync.Pool{
-		New: func() interface{} {
-			return bytes.NewBuffer(make([]byte, 0, 512))
-		},
-	}
+	_bytesBufferPool = sync.Pool{}
 )
 
 func getBytesBuffer(b []byte) *bytes.Buffer {
-	bb := _bytesBufferPool.Get().(*bytes.Buffer)
+	var bb *bytes.Buffer
+	if g := _bytesBufferPool.Get(); g == nil {
+		_poolMeters[PoolBytesBuffer].get(true)
+		bb = bytes.NewBuffer(make([]byte, 0, GetBufferSize()))
+	} else {
+		_poolMeters[PoolBytesBuffer].get(false)
+		bb = g.(*bytes.Buffer)
+	}
 	bb.Reset()
 	if len(b) > 0 {
 		_, err := bb.Write(b)
@@ -200,6 +225,9 @@ func getBytesBuffer(b []byte) *bytes.Buffer {
 }
 
 func putBytesBuffer(r *bytes.Buffer) {
+	if !_poolMeters[PoolBytesBuffer].put(r.Cap()) {
+		return
+	}
 	_bytesBufferPool.Put(r)
 }
 
@@ -0,0 +1,178 @@
+package sio
+
+import (
+	"sync/atomic"
+)
+
+// Pool identifies one of the pools kept in sio.
+type Pool int
+
+const (
+	PoolReader Pool = iota
+	PoolWriter
+	PoolReadWriter
+	PoolBytesBuffer
+	PoolRowScanner
+
+	numPools
+)
+
+// String returns name of p.
+func (p Pool) String() string {
+	switch p {
+	case PoolReader:
+		return "reader"
+	case PoolWriter:
+		return "writer"
+	case PoolReadWriter:
+		return "readwriter"
+	case PoolBytesBuffer:
+		return "bytesbuffer"
+	case PoolRowScanner:
+		return "rowscanner"
+	}
+	return "unknown"
+}
+
+// PoolStats is a snapshot of counters of a pool.
+// Counters are updated only while stats are enabled with EnablePoolStats.
+type PoolStats struct {
+	Gets      uint64 // number of gets
+	Puts      uint64 // number of puts, including discarded ones
+	News      uint64 // number of gets that created a new value
+	Discarded uint64 // number of puts that were dropped because of exceeding max capacity
+}
+
+// HitRate returns the ratio of gets served by the pool without creating a new value.
+func (s PoolStats) HitRate() float64 {
+	if s.Gets == 0 {
+		return 0
+	}
+	return float64(s.Gets-s.News) / float64(s.Gets)
+}
+
+type poolMeter struct {
+	gets      atomic.Uint64
+	puts      atomic.Uint64
+	news      atomic.Uint64
+	discarded atomic.Uint64
+
+	maxCap atomic.Int64
+}
+
+func (m *poolMeter) get(created bool) {
+	if !_poolStatsEnabled.Load() {
+		return
+	}
+	m.gets.Add(1)
+	if created {
+		m.news.Add(1)
+	}
+}
+
+// put counts a put and reports whether a value of capacity c may be retained.
+func (m *poolMeter) put(c int) bool {
+	maxCap := m.maxCap.Load()
+	retain := maxCap <= 0 || int64(c) <= maxCap
+
+	if _poolStatsEnabled.Load() {
+		m.puts.Add(1)
+		if !retain {
+			m.discarded.Add(1)
+		}
+	}
+	return retain
+}
+
+func (m *poolMeter) stats() PoolStats {
+	return PoolStats{
+		Gets:      m.gets.Load(),
+		Puts:      m.puts.Load(),
+		News:      m.news.Load(),
+		Discarded: m.discarded.Load(),
+	}
+}
+
+func (m *poolMeter) reset() {
+	m.gets.Store(0)
+	m.puts.Store(0)
+	m.news.Store(0)
+	m.discarded.Store(0)
+}
+
+var (
+	_poolStatsEnabled atomic.Bool
+	_poolMeters       [numPools]poolMeter
+)
+
+func meterOf(p Pool) *poolMeter {
+	if p < 0 || p >= numPools {
+		return nil
+	}
+	return &_poolMeters[p]
+}
+
+// EnablePoolStats turns counting of pool stats on or off. It is off by default.
+func EnablePoolStats(enable bool) {
+	_poolStatsEnabled.Store(enable)
+}
+
+// GetPoolStats returns a snapshot of stats of p.
+func GetPoolStats(p Pool) PoolStats {
+	m := meterOf(p)
+	if m == nil {
+		return PoolStats{}
+	}
+	return m.stats()
+}
+
+// ResetPoolStats sets counters of every pool to zero.
+func ResetPoolStats() {
+	for i := range _poolMeters {
+		_poolMeters[i].reset()
+	}
+}
+
+// SetPoolMaxCapacity sets the max capacity of a value that p retains when it is put back.
+// Values exceeding it are left to the garbage collector. Zero or negative n means no limit, which is the default.
+//
+// Capacity is measured in bytes of buffers for PoolReader, PoolWriter, PoolReadWriter and PoolBytesBuffer,
+// and in number of registered column types for PoolRowScanner.
+func SetPoolMaxCapacity(p Pool, n int) {
+	m := meterOf(p)
+	if m == nil {
+		return
+	}
+	m.maxCap.Store(int64(n))
+}
+
+// GetPoolMaxCapacity returns the max capacity of p.
+func GetPoolMaxCapacity(p Pool) int {
+	m := meterOf(p)
+	if m == nil {
+		return 0
+	}
+	return int(m.maxCap.Load())
+}
+
+var _bufferSize atomic.Int64
+
+// SetBufferSize sets the size of buffers of Readers and Writers created afterwards.
+// It is the size of underlying bufio.Reader and bufio.Writer and the size by which ReadAll grows its buffer.
+// Pooled Readers and Writers keep the size they were created with.
+// Zero or negative n restores the default, which is bufio's default size for bufio.Reader and bufio.Writer
+// and defaultBufferSize for ReadAll.
+func SetBufferSize(n int) {
+	if n < 0 {
+		n = 0
+	}
+	_bufferSize.Store(int64(n))
+}
+
+// GetBufferSize returns the buffer size set by SetBufferSize, or defaultBufferSize if not set.
+func GetBufferSize() int {
+	if n := _bufferSize.Load(); n > 0 {
+		return int(n)
+	}
+	return defaultBufferSize
+}
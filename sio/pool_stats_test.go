@@ -0,0 +1,98 @@
+package sio
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPool_String(t *testing.T) {
+	assert.EqualValues(t, "reader", PoolReader.String())
+	assert.EqualValues(t, "writer", PoolWriter.String())
+	assert.EqualValues(t, "readwriter", PoolReadWriter.String())
+	assert.EqualValues(t, "bytesbuffer", PoolBytesBuffer.String())
+	assert.EqualValues(t, "rowscanner", PoolRowScanner.String())
+	assert.EqualValues(t, "unknown", Pool(-1).String())
+}
+
+func TestPoolStats_HitRate(t *testing.T) {
+	assert.EqualValues(t, 0, PoolStats{}.HitRate())
+	assert.EqualValues(t, 0.75, PoolStats{Gets: 4, News: 1}.HitRate())
+}
+
+func TestGetPoolStats(t *testing.T) {
+	EnablePoolStats(true)
+	defer EnablePoolStats(false)
+	ResetPoolStats()
+	defer ResetPoolStats()
+
+	r := GetReader(bytes.NewBufferString("hello"))
+	PutReader(r)
+	r = GetReader(bytes.NewBufferString("hello"))
+	PutReader(r)
+
+	s := GetPoolStats(PoolReader)
+	assert.EqualValues(t, 2, s.Gets)
+	assert.EqualValues(t, 2, s.Puts)
+	assert.EqualValues(t, 0, s.Discarded)
+	assert.LessOrEqual(t, s.News, s.Gets)
+
+	ResetPoolStats()
+	assert.EqualValues(t, PoolStats{}, GetPoolStats(PoolReader))
+	assert.EqualValues(t, PoolStats{}, GetPoolStats(Pool(-1)))
+}
+
+func TestGetPoolStats_Disabled(t *testing.T) {
+	ResetPoolStats()
+	EnablePoolStats(false)
+
+	w := GetWriter(&bytes.Buffer{})
+	PutWriter(w)
+	assert.EqualValues(t, PoolStats{}, GetPoolStats(PoolWriter))
+}
+
+func TestSetPoolMaxCapacity(t *testing.T) {
+	EnablePoolStats(true)
+	defer EnablePoolStats(false)
+	ResetPoolStats()
+	defer ResetPoolStats()
+
+	SetPoolMaxCapacity(PoolBytesBuffer, 1024)
+	defer SetPoolMaxCapacity(PoolBytesBuffer, 0)
+	require.EqualValues(t, 1024, GetPoolMaxCapacity(PoolBytesBuffer))
+
+	small := GetBytesBuffer(nil)
+	PutBytesBuffer(small)
+
+	large := GetBytesBuffer(make([]byte, 4096))
+	require.Greater(t, large.Cap(), 1024)
+	PutBytesBuffer(large)
+
+	s := GetPoolStats(PoolBytesBuffer)
+	assert.EqualValues(t, 2, s.Puts)
+	assert.EqualValues(t, 1, s.Discarded)
+
+	SetPoolMaxCapacity(Pool(-1), 10)
+	assert.EqualValues(t, 0, GetPoolMaxCapacity(Pool(-1)))
+}
+
+func TestSetBufferSize(t *testing.T) {
+	defer SetBufferSize(0)
+
+	assert.EqualValues(t, defaultBufferSize, GetBufferSize())
+
+	SetBufferSize(64)
+	assert.EqualValues(t, 64, GetBufferSize())
+
+	r := newReader(bytes.NewBufferString("hello"))
+	assert.EqualValues(t, 64, r.Size())
+	assert.EqualValues(t, 64, cap(r.bufAll))
+
+	w := newWriter(&bytes.Buffer{})
+	assert.EqualValues(t, 64, w.Size())
+
+	SetBufferSize(-1)
+	assert.EqualValues(t, defaultBufferSize, GetBufferSize())
+}
@@ -31,6 +31,8 @@ type Reader struct {
 	dec siencoding.Decoder
 	chk EofChecker
 
+	validate bool
+
 	bufAll []byte
 }
 
@@ -38,10 +40,10 @@ func newReader(r io.Reader, opt ...ReaderOption) *Reader {
 	var br *bufio.Reader
 	var ok bool
 	if br, ok = r.(*bufio.Reader); !ok {
-		br = bufio.NewReader(r)
+		br = newBufioReader(r)
 	}
 
-	rd := &Reader{br: br, bufAll: make([]byte, 0, defaultBufferSize)}
+	rd := &Reader{br: br, bufAll: make([]byte, 0, GetBufferSize())}
 	rd.ApplyOptions(opt...)
 	return rd
 }
@@ -63,6 +65,18 @@ func (rd *Reader) ApplyOptions(opts ...ReaderOption) {
 	}
 }
 
+func newBufioReader(r io.Reader) *bufio.Reader {
+	if n := _bufferSize.Load(); n > 0 {
+		return bufio.NewReaderSize(r, int(n))
+	}
+	return bufio.NewReader(r)
+}
+
+// capacity returns the number of bytes retained by rd's buffers.
+func (rd *Reader) capacity() int {
+	return cap(rd.bufAll) + rd.br.Size()
+}
+
 // SetEofChecker sets EofChecker to underlying Reader.
 func (rd *Reader) SetEofChecker(chk EofChecker) {
 	rd.chk = chk
@@ -72,6 +86,11 @@ func (rd *Reader) SetDecoder(dec siencoding.Decoder) {
 	rd.dec = dec
 }
 
+// SetValidation sets whether to run struct-tag validation after Decode.
+func (rd *Reader) SetValidation(validate bool) {
+	rd.validate = validate
+}
+
 // Reset resets underlying Reader with r and opt.
 func (rd *Reader) Reset(r io.Reader, opt ...ReaderOption) {
 	rd.bufAll = rd.bufAll[:0]
@@ -89,6 +108,7 @@ func (rd *Reader) Reset(r io.Reader, opt ...ReaderOption) {
 		rd.dec = nil
 	}
 	rd.chk = nil
+	rd.validate = false
 
 	if r != nil {
 		rd.ApplyOptions(opt...)
@@ -118,7 +138,7 @@ func (rd *Reader) readAll() ([]byte, error) {
 	rd.bufAll = rd.bufAll[:0]
 	for {
 		if len(rd.bufAll) == cap(rd.bufAll) {
-			if err := utils.GrowByteSliceCap(&rd.bufAll, defaultBufferSize); err != nil {
+			if err := utils.GrowByteSliceCap(&rd.bufAll, GetBufferSize()); err != nil {
 				return nil, err
 			}
 		}
@@ -143,10 +163,11 @@ func (rd *Reader) readAll() ([]byte, error) {
 // readAll reads all data from r and returns it
 func readAll(r io.Reader, chk EofChecker) ([]byte, error) {
 
-	b := make([]byte, 0, defaultBufferSize)
+	size := GetBufferSize()
+	b := make([]byte, 0, size)
 	for {
 		if len(b) == cap(b) {
-			if err := utils.GrowByteSliceCap(&b, defaultBufferSize); err != nil {
+			if err := utils.GrowByteSliceCap(&b, size); err != nil {
 				return nil, err
 			}
 		}
@@ -167,11 +188,18 @@ func readAll(r io.Reader, chk EofChecker) ([]byte, error) {
 var ErrNoDecoder = errors.New("no decoder was provided")
 
 // Decode decodes data from underlying Reader(rd.br) and saves it to the value pointed by v.
+// If validation is set, it returns *ValidationError when decoded v is invalid.
 func (rd *Reader) Decode(v any) error {
 	if rd.dec == nil {
 		return ErrNoDecoder
 	}
-	return rd.dec.Decode(v)
+	if err := rd.dec.Decode(v); err != nil {
+		return err
+	}
+	if rd.validate {
+		return Validate(v)
+	}
+	return nil
 }
 
 // Peek returns next n bytes of underlying Reader(rd.br) without advancing the Reader.
@@ -205,7 +233,7 @@ type Writer struct {
 func newWriter(w io.Writer, opt ...WriterOption) *Writer {
 	bw, ok := w.(*bufio.Writer)
 	if !ok {
-		bw = bufio.NewWriter(w)
+		bw = newBufioWriter(w)
 	}
 
 	wr := &Writer{bw: bw}
@@ -225,6 +253,18 @@ func (wr *Writer) ApplyOptions(opts ...WriterOption) {
 	}
 }
 
+func newBufioWriter(w io.Writer) *bufio.Writer {
+	if n := _bufferSize.Load(); n > 0 {
+		return bufio.NewWriterSize(w, int(n))
+	}
+	return bufio.NewWriter(w)
+}
+
+// capacity returns the number of bytes retained by wr's buffer.
+func (wr *Writer) capacity() int {
+	return wr.bw.Size()
+}
+
 func (wr *Writer) SetEncoder(enc siencoding.Encoder) {
 	wr.enc = enc
 }
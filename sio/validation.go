@@ -0,0 +1,156 @@
+package sio
+
+import (
+	"errors"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/go-playground/validator/v10"
+)
+
+// FieldError describes a field that failed validation.
+type FieldError struct {
+	// Field is the path of the field named after json tags, eg. "books[0].title".
+	Field string `json:"field" xml:"field"`
+	// Tag is the validation tag that failed, eg. "required".
+	Tag string `json:"tag" xml:"tag"`
+	// Param is the parameter of Tag, eg. "10" of "max=10".
+	Param   string `json:"param,omitempty" xml:"param,omitempty"`
+	Message string `json:"message" xml:"message"`
+}
+
+// ValidationError is returned when a decoded value fails struct-tag validation.
+type ValidationError struct {
+	Errors []FieldError `json:"errors"`
+}
+
+func (e *ValidationError) Error() string {
+	msg := strings.Builder{}
+	msg.WriteString("validation failed")
+	for i, fe := range e.Errors {
+		if i == 0 {
+			msg.WriteString(": ")
+		} else {
+			msg.WriteString(", ")
+		}
+		msg.WriteString(fe.Message)
+	}
+	return msg.String()
+}
+
+var (
+	_validate     *validator.Validate
+	_validateOnce sync.Once
+)
+
+func getValidate() *validator.Validate {
+	_validateOnce.Do(func() {
+		_validate = validator.New()
+		_validate.RegisterTagNameFunc(jsonTagName)
+	})
+	return _validate
+}
+
+func jsonTagName(f reflect.StructField) string {
+	name, _, _ := strings.Cut(f.Tag.Get("json"), ",")
+	if name == "-" {
+		return ""
+	}
+	if name == "" {
+		return f.Name
+	}
+	return name
+}
+
+// Validate runs struct-tag validation(`validate:"..."`) on v.
+// v can be a struct, a slice or an array of structs, or pointer to these. Other types are not validated.
+// It returns *ValidationError when v is invalid.
+func Validate(v any) error {
+	rv := reflect.ValueOf(v)
+	for rv.Kind() == reflect.Pointer || rv.Kind() == reflect.Interface {
+		if rv.IsNil() {
+			return nil
+		}
+		rv = rv.Elem()
+	}
+
+	var fields []FieldError
+	switch rv.Kind() {
+	case reflect.Struct:
+		if err := validateStruct(rv, "", &fields); err != nil {
+			return err
+		}
+	case reflect.Slice, reflect.Array:
+		for i := 0; i < rv.Len(); i++ {
+			ev := rv.Index(i)
+			for ev.Kind() == reflect.Pointer || ev.Kind() == reflect.Interface {
+				if ev.IsNil() {
+					break
+				}
+				ev = ev.Elem()
+			}
+			if ev.Kind() != reflect.Struct {
+				continue
+			}
+			if err := validateStruct(ev, "["+strconv.Itoa(i)+"]", &fields); err != nil {
+				return err
+			}
+		}
+	default:
+		return nil
+	}
+
+	if len(fields) > 0 {
+		return &ValidationError{Errors: fields}
+	}
+	return nil
+}
+
+func validateStruct(rv reflect.Value, prefix string, fields *[]FieldError) error {
+	if !rv.CanAddr() {
+		pv := reflect.New(rv.Type())
+		pv.Elem().Set(rv)
+		rv = pv.Elem()
+	}
+
+	err := getValidate().Struct(rv.Addr().Interface())
+	if err == nil {
+		return nil
+	}
+
+	var ves validator.ValidationErrors
+	if !errors.As(err, &ves) {
+		return err
+	}
+	for _, fe := range ves {
+		field := fieldPath(prefix, fe.Namespace())
+		*fields = append(*fields, FieldError{
+			Field:   field,
+			Tag:     fe.Tag(),
+			Param:   fe.Param(),
+			Message: fieldMessage(field, fe),
+		})
+	}
+	return nil
+}
+
+// fieldPath strips the struct name from namespace, which is the first segment, then prepends prefix.
+func fieldPath(prefix, namespace string) string {
+	_, path, found := strings.Cut(namespace, ".")
+	if !found {
+		path = namespace
+	}
+	if prefix == "" {
+		return path
+	}
+	return prefix + "." + path
+}
+
+func fieldMessage(field string, fe validator.FieldError) string {
+	if fe.Param() == "" {
+		return field + " failed on '" + fe.Tag() + "'"
+	}
+	return field + " failed on '" + fe.Tag() + "=" + fe.Param() + "'"
+}
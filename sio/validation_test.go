@@ -0,0 +1,77 @@
+package sio
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type _validationBook struct {
+	Title string `json:"title" validate:"required"`
+}
+
+type _validationStudent struct {
+	Name  string            `json:"name" validate:"required"`
+	Age   int               `json:"age" validate:"gte=0,lte=150"`
+	Books []_validationBook `json:"books" validate:"dive"`
+	Memo  string            `validate:"max=3"`
+}
+
+func Test_Validate(t *testing.T) {
+	t.Run("valid", func(t *testing.T) {
+		s := _validationStudent{Name: "wonk", Age: 20}
+		require.Nil(t, Validate(&s))
+		require.Nil(t, Validate(s))
+	})
+
+	t.Run("invalid", func(t *testing.T) {
+		s := _validationStudent{Age: 200, Books: []_validationBook{{Title: "a"}, {}}, Memo: "asdf"}
+		err := Validate(&s)
+		require.NotNil(t, err)
+
+		ve, ok := err.(*ValidationError)
+		require.True(t, ok)
+		require.Len(t, ve.Errors, 4)
+		assert.EqualValues(t, FieldError{Field: "name", Tag: "required", Message: "name failed on 'required'"}, ve.Errors[0])
+		assert.EqualValues(t, FieldError{Field: "age", Tag: "lte", Param: "150", Message: "age failed on 'lte=150'"}, ve.Errors[1])
+		assert.EqualValues(t, "books[1].title", ve.Errors[2].Field)
+		assert.EqualValues(t, "Memo", ve.Errors[3].Field)
+		assert.EqualValues(t, "validation failed: name failed on 'required', age failed on 'lte=150', books[1].title failed on 'required', Memo failed on 'max=3'", ve.Error())
+	})
+
+	t.Run("slice", func(t *testing.T) {
+		s := []*_validationStudent{{Name: "wonk"}, nil, {}}
+		err := Validate(&s)
+		require.NotNil(t, err)
+
+		ve := err.(*ValidationError)
+		require.Len(t, ve.Errors, 1)
+		assert.EqualValues(t, "[2].name", ve.Errors[0].Field)
+	})
+
+	t.Run("not-struct", func(t *testing.T) {
+		var s *_validationStudent
+		require.Nil(t, Validate(s))
+		require.Nil(t, Validate(map[string]any{"name": ""}))
+		str := "asdf"
+		require.Nil(t, Validate(&str))
+	})
+}
+
+func TestReader_Decode_WithValidation(t *testing.T) {
+	r := GetReader(bytes.NewBufferString(`{"name":"","age":20}`), SetJsonDecoder(), WithValidation())
+	var s _validationStudent
+	err := r.Decode(&s)
+	PutReader(r)
+	require.NotNil(t, err)
+	assert.IsType(t, &ValidationError{}, err)
+	assert.EqualValues(t, 20, s.Age)
+
+	// validation is not kept after reset
+	r = GetReader(bytes.NewBufferString(`{"name":"","age":20}`), SetJsonDecoder())
+	err = r.Decode(&s)
+	PutReader(r)
+	require.Nil(t, err)
+}
@@ -0,0 +1,215 @@
+// Package sitls builds tls.Config with secure defaults for servers and clients of sihttp and sigrpc,
+// with certificates reloaded when their files change and client certificate verification for mutual TLS.
+//
+//	conf, err := sitls.NewConfig(
+//		sitls.WithReloadingCertificate("server.crt", "server.key", time.Minute),
+//		sitls.WithClientCAFile("ca.crt"),
+//	)
+package sitls
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+)
+
+var (
+	ErrNoCertificate    = errors.New("sitls: no certificate in pem")
+	ErrPeerNameMismatch = errors.New("sitls: peer certificate does not match allowed names")
+)
+
+// _cipherSuites are TLS 1.2 suites of forward secrecy and authenticated encryption.
+// TLS 1.3 suites are not configurable, and all of them are secure.
+var _cipherSuites = []uint16{
+	tls.TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256,
+	tls.TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256,
+	tls.TLS_ECDHE_ECDSA_WITH_AES_256_GCM_SHA384,
+	tls.TLS_ECDHE_RSA_WITH_AES_256_GCM_SHA384,
+	tls.TLS_ECDHE_ECDSA_WITH_CHACHA20_POLY1305_SHA256,
+	tls.TLS_ECDHE_RSA_WITH_CHACHA20_POLY1305_SHA256,
+}
+
+// CipherSuites returns the TLS 1.2 cipher suites of NewConfig.
+func CipherSuites() []uint16 {
+	suites := make([]uint16, len(_cipherSuites))
+	copy(suites, _cipherSuites)
+	return suites
+}
+
+// Option configures tls.Config of NewConfig.
+type Option interface {
+	apply(c *tls.Config) error
+}
+
+// OptionFunc wraps a function to conforms to Option interface.
+type OptionFunc func(c *tls.Config) error
+
+func (o OptionFunc) apply(c *tls.Config) error {
+	return o(c)
+}
+
+// NewConfig returns tls.Config of TLS 1.2 or later with cipher suites of CipherSuites, configured by opts.
+// It can be used by both servers and clients.
+func NewConfig(opts ...Option) (*tls.Config, error) {
+	c := &tls.Config{
+		MinVersion:       tls.VersionTLS12,
+		CipherSuites:     CipherSuites(),
+		CurvePreferences: []tls.CurveID{tls.X25519, tls.CurveP256, tls.CurveP384},
+	}
+	for _, o := range opts {
+		if o == nil {
+			continue
+		}
+		if err := o.apply(c); err != nil {
+			return nil, err
+		}
+	}
+	return c, nil
+}
+
+// WithMinVersion sets the minimum version, which is tls.VersionTLS12 by default.
+func WithMinVersion(version uint16) OptionFunc {
+	return OptionFunc(func(c *tls.Config) error {
+		c.MinVersion = version
+		return nil
+	})
+}
+
+// WithCertificate loads the certificate of certFile and keyFile, which is presented to peers.
+func WithCertificate(certFile, keyFile string) OptionFunc {
+	return OptionFunc(func(c *tls.Config) error {
+		cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+		if err != nil {
+			return err
+		}
+		c.Certificates = append(c.Certificates, cert)
+		return nil
+	})
+}
+
+// WithReloadingCertificate presents the certificate of certFile and keyFile to peers, reloading it
+// if the files have changed when it is requested after interval. See CertReloader.
+func WithReloadingCertificate(certFile, keyFile string, interval time.Duration) OptionFunc {
+	return OptionFunc(func(c *tls.Config) error {
+		r, err := NewCertReloader(certFile, keyFile, interval)
+		if err != nil {
+			return err
+		}
+		c.GetCertificate = r.GetCertificate
+		c.GetClientCertificate = r.GetClientCertificate
+		return nil
+	})
+}
+
+// WithRootCAFile verifies certificates of servers with the certificates in pem files instead of the system pool.
+func WithRootCAFile(files ...string) OptionFunc {
+	return OptionFunc(func(c *tls.Config) error {
+		pool, err := certPool(c.RootCAs, files)
+		if err != nil {
+			return err
+		}
+		c.RootCAs = pool
+		return nil
+	})
+}
+
+// WithServerName sets the name to verify the certificate of the server with, which is the host dialed by default.
+func WithServerName(name string) OptionFunc {
+	return OptionFunc(func(c *tls.Config) error {
+		c.ServerName = name
+		return nil
+	})
+}
+
+// WithClientCAFile verifies certificates of clients with the certificates in pem files for mutual TLS.
+// It requires clients to present a certificate unless WithClientAuth sets otherwise.
+func WithClientCAFile(files ...string) OptionFunc {
+	return OptionFunc(func(c *tls.Config) error {
+		pool, err := certPool(c.ClientCAs, files)
+		if err != nil {
+			return err
+		}
+		c.ClientCAs = pool
+		if c.ClientAuth == tls.NoClientCert {
+			c.ClientAuth = tls.RequireAndVerifyClientCert
+		}
+		return nil
+	})
+}
+
+// WithClientAuth sets the policy of client certificates.
+func WithClientAuth(auth tls.ClientAuthType) OptionFunc {
+	return OptionFunc(func(c *tls.Config) error {
+		c.ClientAuth = auth
+		return nil
+	})
+}
+
+// WithPeerNames accepts verified peer certificates only if one of names is their common name,
+// one of their DNS names or URIs. Connections without a peer certificate are not affected.
+func WithPeerNames(names ...string) OptionFunc {
+	return WithVerifyConnection(func(cs tls.ConnectionState) error {
+		if len(cs.PeerCertificates) == 0 {
+			return nil
+		}
+		if matchesName(cs.PeerCertificates[0], names) {
+			return nil
+		}
+		return fmt.Errorf("%w: %s", ErrPeerNameMismatch, cs.PeerCertificates[0].Subject)
+	})
+}
+
+// WithVerifyConnection calls verify after certificates are verified. It fails the handshake if verify returns
+// an error. It is chained with the one set before.
+func WithVerifyConnection(verify func(cs tls.ConnectionState) error) OptionFunc {
+	return OptionFunc(func(c *tls.Config) error {
+		prev := c.VerifyConnection
+		c.VerifyConnection = func(cs tls.ConnectionState) error {
+			if prev != nil {
+				if err := prev(cs); err != nil {
+					return err
+				}
+			}
+			return verify(cs)
+		}
+		return nil
+	})
+}
+
+func certPool(pool *x509.CertPool, files []string) (*x509.CertPool, error) {
+	if pool == nil {
+		pool = x509.NewCertPool()
+	}
+	for _, f := range files {
+		b, err := os.ReadFile(f)
+		if err != nil {
+			return nil, err
+		}
+		if !pool.AppendCertsFromPEM(b) {
+			return nil, fmt.Errorf("%w: %s", ErrNoCertificate, f)
+		}
+	}
+	return pool, nil
+}
+
+func matchesName(cert *x509.Certificate, names []string) bool {
+	for _, name := range names {
+		if cert.Subject.CommonName == name {
+			return true
+		}
+		for _, n := range cert.DNSNames {
+			if n == name {
+				return true
+			}
+		}
+		for _, u := range cert.URIs {
+			if u.String() == name {
+				return true
+			}
+		}
+	}
+	return false
+}
@@ -0,0 +1,165 @@
+package sitls
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type _ca struct {
+	cert *x509.Certificate
+	key  *ecdsa.PrivateKey
+	dir  string
+}
+
+// _newCA creates a certificate authority writing its certificate to ca.crt of a temporary directory.
+func _newCA(t *testing.T) *_ca {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.Nil(t, err)
+	tmpl := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "test-ca"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageCertSign,
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	require.Nil(t, err)
+	cert, _ := x509.ParseCertificate(der)
+
+	ca := &_ca{cert: cert, key: key, dir: t.TempDir()}
+	ca.write(t, "ca.crt", "CERTIFICATE", der)
+	return ca
+}
+
+func (ca *_ca) write(t *testing.T, name, typ string, der []byte) string {
+	path := filepath.Join(ca.dir, name)
+	require.Nil(t, os.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: typ, Bytes: der}), 0600))
+	return path
+}
+
+// issue writes a certificate of cn signed by ca to name.crt and name.key, and returns their paths.
+func (ca *_ca) issue(t *testing.T, name, cn string, serial int64) (string, string) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.Nil(t, err)
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(serial),
+		Subject:      pkix.Name{CommonName: cn},
+		DNSNames:     []string{cn},
+		IPAddresses:  []net.IP{net.ParseIP("127.0.0.1")},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, ca.cert, &key.PublicKey, ca.key)
+	require.Nil(t, err)
+	keyDer, err := x509.MarshalECPrivateKey(key)
+	require.Nil(t, err)
+	return ca.write(t, name+".crt", "CERTIFICATE", der), ca.write(t, name+".key", "EC PRIVATE KEY", keyDer)
+}
+
+func (ca *_ca) certFile() string {
+	return filepath.Join(ca.dir, "ca.crt")
+}
+
+func TestNewConfig(t *testing.T) {
+	c, err := NewConfig()
+	require.Nil(t, err)
+	assert.EqualValues(t, tls.VersionTLS12, c.MinVersion)
+	for _, s := range c.CipherSuites {
+		for _, insecure := range tls.InsecureCipherSuites() {
+			assert.NotEqualValues(t, insecure.ID, s)
+		}
+	}
+
+	c, err = NewConfig(WithMinVersion(tls.VersionTLS13), WithServerName("localhost"))
+	require.Nil(t, err)
+	assert.EqualValues(t, tls.VersionTLS13, c.MinVersion)
+	assert.EqualValues(t, "localhost", c.ServerName)
+
+	_, err = NewConfig(WithRootCAFile(filepath.Join(t.TempDir(), "none.crt")))
+	assert.ErrorIs(t, err, os.ErrNotExist)
+
+	empty := filepath.Join(t.TempDir(), "empty.crt")
+	require.Nil(t, os.WriteFile(empty, []byte("no pem"), 0600))
+	_, err = NewConfig(WithClientCAFile(empty))
+	assert.ErrorIs(t, err, ErrNoCertificate)
+}
+
+func TestNewConfig_MutualTLS(t *testing.T) {
+	ca := _newCA(t)
+	serverCert, serverKey := ca.issue(t, "server", "localhost", 2)
+	clientCert, clientKey := ca.issue(t, "client", "client-a", 3)
+
+	serverConf, err := NewConfig(
+		WithReloadingCertificate(serverCert, serverKey, time.Minute),
+		WithClientCAFile(ca.certFile()),
+		WithPeerNames("client-a"),
+	)
+	require.Nil(t, err)
+	assert.EqualValues(t, tls.RequireAndVerifyClientCert, serverConf.ClientAuth)
+
+	svr := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(r.TLS.PeerCertificates[0].Subject.CommonName))
+	}))
+	// httptest.Server.StartTLS sets its own certificate, which takes precedence over GetCertificate
+	svr.Listener = tls.NewListener(svr.Listener, serverConf)
+	svr.Start()
+	defer svr.Close()
+	url := "https://" + svr.Listener.Addr().String()
+
+	get := func(opts ...Option) (string, error) {
+		conf, err := NewConfig(append([]Option{WithRootCAFile(ca.certFile())}, opts...)...)
+		require.Nil(t, err)
+		c := &http.Client{Transport: &http.Transport{TLSClientConfig: conf}}
+		resp, err := c.Get(url)
+		if err != nil {
+			return "", err
+		}
+		defer resp.Body.Close()
+		b := make([]byte, 64)
+		n, _ := resp.Body.Read(b)
+		return string(b[:n]), nil
+	}
+
+	cn, err := get(WithCertificate(clientCert, clientKey))
+	require.Nil(t, err)
+	assert.EqualValues(t, "client-a", cn)
+
+	// reloading client certificates
+	cn, err = get(WithReloadingCertificate(clientCert, clientKey, time.Minute))
+	require.Nil(t, err)
+	assert.EqualValues(t, "client-a", cn)
+
+	_, err = get()
+	assert.NotNil(t, err)
+
+	otherCert, otherKey := ca.issue(t, "other", "client-b", 4)
+	_, err = get(WithCertificate(otherCert, otherKey))
+	assert.NotNil(t, err)
+
+	// a client rejects servers not signed by its root CAs
+	untrusted := _newCA(t)
+	conf, err := NewConfig(WithRootCAFile(untrusted.certFile()), WithCertificate(clientCert, clientKey))
+	require.Nil(t, err)
+	_, err = (&http.Client{Transport: &http.Transport{TLSClientConfig: conf}}).Get(url)
+	assert.NotNil(t, err)
+}
@@ -0,0 +1,127 @@
+package sitls
+
+import (
+	"crypto/tls"
+	"os"
+	"sync"
+	"time"
+)
+
+const defaultReloadInterval = time.Minute
+
+// CertReloader holds a certificate loaded from files, and reloads it when the files change. Changes are polled
+// when the certificate is requested after interval since the last poll, so no goroutine runs in the background.
+// The certificate in use is kept if reloading fails, and Err returns the error.
+type CertReloader struct {
+	certFile string
+	keyFile  string
+	interval time.Duration
+
+	mu       sync.Mutex
+	cert     *tls.Certificate
+	certMod  fileVersion
+	keyMod   fileVersion
+	polledAt time.Time
+	err      error
+}
+
+type fileVersion struct {
+	modTime time.Time
+	size    int64
+}
+
+// NewCertReloader loads the certificate of certFile and keyFile, which is polled for changes every interval,
+// one minute if zero.
+func NewCertReloader(certFile, keyFile string, interval time.Duration) (*CertReloader, error) {
+	if interval <= 0 {
+		interval = defaultReloadInterval
+	}
+	r := &CertReloader{certFile: certFile, keyFile: keyFile, interval: interval}
+	if err := r.Reload(); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// Reload loads the certificate from the files.
+func (r *CertReloader) Reload() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.reload()
+}
+
+func (r *CertReloader) reload() error {
+	r.polledAt = time.Now()
+	certMod, err := stat(r.certFile)
+	if err != nil {
+		r.err = err
+		return err
+	}
+	keyMod, err := stat(r.keyFile)
+	if err != nil {
+		r.err = err
+		return err
+	}
+	cert, err := tls.LoadX509KeyPair(r.certFile, r.keyFile)
+	if err != nil {
+		r.err = err
+		return err
+	}
+	r.cert, r.certMod, r.keyMod, r.err = &cert, certMod, keyMod, nil
+	return nil
+}
+
+// poll reloads the certificate if the files have changed since it was loaded.
+func (r *CertReloader) poll() {
+	if time.Since(r.polledAt) < r.interval {
+		return
+	}
+	r.polledAt = time.Now()
+
+	certMod, err := stat(r.certFile)
+	if err != nil {
+		r.err = err
+		return
+	}
+	keyMod, err := stat(r.keyFile)
+	if err != nil {
+		r.err = err
+		return
+	}
+	if certMod != r.certMod || keyMod != r.keyMod {
+		_ = r.reload()
+	}
+}
+
+// Certificate returns the certificate, reloading it if it's time to poll and the files have changed.
+func (r *CertReloader) Certificate() *tls.Certificate {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.poll()
+	return r.cert
+}
+
+// Err returns the error of the last poll or reload, which is nil if it succeeded.
+func (r *CertReloader) Err() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.err
+}
+
+// GetCertificate is tls.Config.GetCertificate for servers.
+func (r *CertReloader) GetCertificate(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	return r.Certificate(), nil
+}
+
+// GetClientCertificate is tls.Config.GetClientCertificate for clients.
+func (r *CertReloader) GetClientCertificate(*tls.CertificateRequestInfo) (*tls.Certificate, error) {
+	return r.Certificate(), nil
+}
+
+func stat(name string) (fileVersion, error) {
+	fi, err := os.Stat(name)
+	if err != nil {
+		return fileVersion{}, err
+	}
+	return fileVersion{modTime: fi.ModTime(), size: fi.Size()}, nil
+}
@@ -0,0 +1,61 @@
+package sitls
+
+import (
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCertReloader(t *testing.T) {
+	ca := _newCA(t)
+	certFile, keyFile := ca.issue(t, "server", "localhost", 10)
+
+	r, err := NewCertReloader(certFile, keyFile, 10*time.Millisecond)
+	require.Nil(t, err)
+	assert.EqualValues(t, 10, r.Certificate().Leaf.SerialNumber.Int64())
+
+	// replaced
+	newCert, newKey := ca.issue(t, "new", "localhost", 11)
+	_replace(t, newCert, certFile)
+	_replace(t, newKey, keyFile)
+
+	// not polled until interval passes
+	r.interval = time.Hour
+	assert.EqualValues(t, 10, r.Certificate().Leaf.SerialNumber.Int64())
+	r.interval = 10 * time.Millisecond
+	time.Sleep(20 * time.Millisecond)
+	assert.EqualValues(t, 11, r.Certificate().Leaf.SerialNumber.Int64())
+	assert.Nil(t, r.Err())
+
+	// the certificate in use is kept on failure
+	require.Nil(t, os.WriteFile(certFile, []byte("broken"), 0600))
+	_touch(t, certFile)
+	time.Sleep(20 * time.Millisecond)
+	cert, err := r.GetCertificate(nil)
+	require.Nil(t, err)
+	assert.EqualValues(t, 11, cert.Leaf.SerialNumber.Int64())
+	assert.NotNil(t, r.Err())
+
+	require.Nil(t, os.Remove(keyFile))
+	assert.NotNil(t, r.Reload())
+
+	_, err = NewCertReloader(certFile, keyFile, 0)
+	assert.NotNil(t, err)
+}
+
+// _replace renames src to dst, and moves its modification time forward so that a change is detected
+// on file systems of coarse time resolution.
+func _replace(t *testing.T, src, dst string) {
+	require.Nil(t, os.Rename(src, dst))
+	_touch(t, dst)
+}
+
+func _touch(t *testing.T, name string) {
+	fi, err := os.Stat(name)
+	require.Nil(t, err)
+	later := fi.ModTime().Add(time.Second)
+	require.Nil(t, os.Chtimes(name, later, later))
+}
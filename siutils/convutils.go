@@ -2,8 +2,130 @@ package siutils
 
 import (
 	"encoding/json"
+	"reflect"
+	"time"
+
+	"github.com/mitchellh/mapstructure"
 )
 
+const defaultConvertTagName = "json"
+
+type convertConfig struct {
+	tagName     string
+	weak        bool
+	errorUnused bool
+	timeLayout  string
+	hooks       []mapstructure.DecodeHookFunc
+}
+
+// ConvertOption is an interface with apply method.
+type ConvertOption interface {
+	apply(c *convertConfig)
+}
+
+// ConvertOptionFunc wraps a function to conforms to ConvertOption interface
+type ConvertOptionFunc func(c *convertConfig)
+
+// apply implements ConvertOption's apply method.
+func (o ConvertOptionFunc) apply(c *convertConfig) {
+	o(c)
+}
+
+// WithTagName sets the struct tag to read field names from, eg. "json" or "si". Default is "json".
+func WithTagName(tagName string) ConvertOptionFunc {
+	return ConvertOptionFunc(func(c *convertConfig) {
+		c.tagName = tagName
+	})
+}
+
+// WithWeaklyTypedInput sets whether to convert between weak types, eg. "1" to 1 or 1 to true.
+func WithWeaklyTypedInput(weak bool) ConvertOptionFunc {
+	return ConvertOptionFunc(func(c *convertConfig) {
+		c.weak = weak
+	})
+}
+
+// WithErrorUnused sets whether to return an error listing the keys of input that were not used.
+func WithErrorUnused(errorUnused bool) ConvertOptionFunc {
+	return ConvertOptionFunc(func(c *convertConfig) {
+		c.errorUnused = errorUnused
+	})
+}
+
+// WithTimeLayout sets the layout to parse strings into time.Time. Default is time.RFC3339.
+func WithTimeLayout(layout string) ConvertOptionFunc {
+	return ConvertOptionFunc(func(c *convertConfig) {
+		c.timeLayout = layout
+	})
+}
+
+// WithDecodeHook appends a custom hook which runs after default hooks.
+func WithDecodeHook(hook mapstructure.DecodeHookFunc) ConvertOptionFunc {
+	return ConvertOptionFunc(func(c *convertConfig) {
+		c.hooks = append(c.hooks, hook)
+	})
+}
+
+// Convert decodes input into output, which must be a pointer.
+// input and output can be maps, structs and slices of them. Integers are kept without losing precision.
+// By default, field names are read from json tag, embedded structs are squashed and
+// strings are converted to time.Duration and time.Time(time.RFC3339).
+func Convert(input any, output any, opts ...ConvertOption) error {
+	c := convertConfig{
+		tagName:    defaultConvertTagName,
+		timeLayout: time.RFC3339,
+	}
+	for _, o := range opts {
+		if o == nil {
+			continue
+		}
+		o.apply(&c)
+	}
+
+	// mapstructure resolves the type of a hook on every value, so default hooks are merged into one.
+	var hook mapstructure.DecodeHookFunc = stringToTimeHook(c.timeLayout)
+	if len(c.hooks) > 0 {
+		hook = mapstructure.ComposeDecodeHookFunc(append([]mapstructure.DecodeHookFunc{hook}, c.hooks...)...)
+	}
+
+	dec, err := mapstructure.NewDecoder(&mapstructure.DecoderConfig{
+		DecodeHook:       hook,
+		ErrorUnused:      c.errorUnused,
+		WeaklyTypedInput: c.weak,
+		Squash:           true,
+		TagName:          c.tagName,
+		Result:           output,
+	})
+	if err != nil {
+		return err
+	}
+	return dec.Decode(input)
+}
+
+var (
+	_durationType = reflect.TypeOf(time.Duration(0))
+	_timeType     = reflect.TypeOf(time.Time{})
+)
+
+// stringToTimeHook converts strings to time.Duration and time.Time with layout.
+func stringToTimeHook(layout string) mapstructure.DecodeHookFuncType {
+	return func(from reflect.Type, to reflect.Type, data interface{}) (interface{}, error) {
+		if from.Kind() != reflect.String {
+			return data, nil
+		}
+		switch to {
+		case _durationType:
+			return time.ParseDuration(data.(string))
+		case _timeType:
+			return time.Parse(layout, data.(string))
+		}
+		return data, nil
+	}
+}
+
+// DecodeAny decodes input into output by encoding it to json and decoding it back, so that types
+// implementing json.Marshaler such as time.Time are converted as they are in json. Use Convert to keep
+// integers without losing precision.
 func DecodeAny(input any, output any) error {
 	b, err := json.Marshal(input)
 	if err != nil {
@@ -12,23 +134,3 @@ func DecodeAny(input any, output any) error {
 
 	return json.Unmarshal(b, output)
 }
-
-// var ji = jsoniter.Config{
-// 	EscapeHTML:             true,
-// 	ValidateJsonRawMessage: true,
-// }.Froze()
-
-// // var ji = jsoniter.ConfigCompatibleWithStandardLibrary
-// // var ji = jsoniter.ConfigFastest
-
-// func DecodeAny(input any, output any) error {
-// 	// js := jsoniter.ConfigDefault
-// 	if b, err := ji.Marshal(input); err != nil {
-// 		return err
-// 	} else {
-// 		if err = ji.Unmarshal(b, output); err != nil {
-// 			return err
-// 		}
-// 	}
-// 	return nil
-// }
@@ -1,7 +1,11 @@
 package siutils_test
 
 import (
+	"errors"
+	"reflect"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
@@ -25,6 +29,27 @@ func TestDecodeAny(t *testing.T) {
 		require.EqualValues(t, Person{"wonk", 20}, p)
 	})
 
+	t.Run("time-to-map", func(t *testing.T) {
+		type Row struct {
+			CreatedAt time.Time `json:"created_at"`
+		}
+		m := map[string]interface{}{}
+		require.Nil(t, siutils.DecodeAny(Row{time.Date(2022, 1, 1, 0, 0, 0, 0, time.UTC)}, &m))
+		assert.EqualValues(t, map[string]interface{}{"created_at": "2022-01-01T00:00:00Z"}, m)
+	})
+
+	t.Run("time-to-string", func(t *testing.T) {
+		type Row struct {
+			CreatedAt time.Time `json:"created_at"`
+		}
+		type View struct {
+			CreatedAt string `json:"created_at"`
+		}
+		v := View{}
+		require.Nil(t, siutils.DecodeAny(Row{time.Date(2022, 1, 1, 0, 0, 0, 0, time.UTC)}, &v))
+		assert.EqualValues(t, "2022-01-01T00:00:00Z", v.CreatedAt)
+	})
+
 	t.Run("fail", func(t *testing.T) {
 		m := map[string]interface{}{
 			"name": "wonk",
@@ -40,6 +65,120 @@ func TestDecodeAny(t *testing.T) {
 	})
 }
 
+func TestConvert(t *testing.T) {
+	t.Run("time", func(t *testing.T) {
+		m := map[string]interface{}{
+			"timeout":    "1m30s",
+			"created_at": "2022-05-01T10:20:30+09:00",
+			"updated_at": time.Date(2022, 5, 2, 0, 0, 0, 0, time.UTC),
+		}
+		type Config struct {
+			Timeout   time.Duration `json:"timeout"`
+			CreatedAt time.Time     `json:"created_at"`
+			UpdatedAt time.Time     `json:"updated_at"`
+		}
+		c := Config{}
+		err := siutils.Convert(m, &c)
+		require.Nil(t, err)
+		assert.EqualValues(t, 90*time.Second, c.Timeout)
+		assert.True(t, time.Date(2022, 5, 1, 1, 20, 30, 0, time.UTC).Equal(c.CreatedAt))
+		assert.True(t, time.Date(2022, 5, 2, 0, 0, 0, 0, time.UTC).Equal(c.UpdatedAt))
+	})
+
+	t.Run("time-layout", func(t *testing.T) {
+		m := map[string]interface{}{"date": "2022-05-01"}
+		type Row struct {
+			Date time.Time `json:"date"`
+		}
+		r := Row{}
+		err := siutils.Convert(m, &r, siutils.WithTimeLayout("2006-01-02"))
+		require.Nil(t, err)
+		assert.EqualValues(t, time.Date(2022, 5, 1, 0, 0, 0, 0, time.UTC), r.Date)
+	})
+
+	t.Run("int-precision", func(t *testing.T) {
+		m := map[string]interface{}{"id": int64(9007199254740993)}
+		type Row struct {
+			ID int64 `json:"id"`
+		}
+		r := Row{}
+		require.Nil(t, siutils.Convert(m, &r))
+		assert.EqualValues(t, int64(9007199254740993), r.ID)
+	})
+
+	t.Run("weak", func(t *testing.T) {
+		m := map[string]interface{}{"age": "20", "admin": 1}
+		type Person struct {
+			Age   int  `json:"age"`
+			Admin bool `json:"admin"`
+		}
+		p := Person{}
+		require.NotNil(t, siutils.Convert(m, &p))
+
+		p = Person{}
+		require.Nil(t, siutils.Convert(m, &p, siutils.WithWeaklyTypedInput(true)))
+		assert.EqualValues(t, Person{Age: 20, Admin: true}, p)
+	})
+
+	t.Run("tag-name", func(t *testing.T) {
+		m := map[string]interface{}{"student_name": "wonk"}
+		type Person struct {
+			Name string `json:"name" si:"student_name"`
+		}
+		p := Person{}
+		require.Nil(t, siutils.Convert(m, &p, siutils.WithTagName("si")))
+		assert.EqualValues(t, "wonk", p.Name)
+	})
+
+	t.Run("error-unused", func(t *testing.T) {
+		m := map[string]interface{}{"name": "wonk", "age": 20, "email": "wonk@wonk.org"}
+		type Person struct {
+			Name string `json:"name"`
+		}
+		p := Person{}
+		require.Nil(t, siutils.Convert(m, &p))
+
+		err := siutils.Convert(m, &p, siutils.WithErrorUnused(true))
+		require.NotNil(t, err)
+		assert.True(t, strings.Contains(err.Error(), "age"))
+		assert.True(t, strings.Contains(err.Error(), "email"))
+	})
+
+	t.Run("decode-hook", func(t *testing.T) {
+		m := map[string]interface{}{"name": "wonk"}
+		type Person struct {
+			Name string `json:"name"`
+		}
+		hook := func(f reflect.Type, t reflect.Type, data interface{}) (interface{}, error) {
+			if s, ok := data.(string); ok {
+				return strings.ToUpper(s), nil
+			}
+			return data, nil
+		}
+		p := Person{}
+		require.Nil(t, siutils.Convert(m, &p, siutils.WithDecodeHook(hook)))
+		assert.EqualValues(t, "WONK", p.Name)
+
+		failHook := func(f reflect.Type, t reflect.Type, data interface{}) (interface{}, error) {
+			return nil, errors.New("failed")
+		}
+		require.NotNil(t, siutils.Convert(m, &p, siutils.WithDecodeHook(failHook)))
+	})
+
+	t.Run("struct-to-map", func(t *testing.T) {
+		type Base struct {
+			ID int `json:"id"`
+		}
+		type Person struct {
+			Base
+			Name string `json:"name"`
+		}
+		m := map[string]interface{}{}
+		require.Nil(t, siutils.Convert(Person{Base{1}, "wonk"}, &m))
+		assert.EqualValues(t, map[string]interface{}{"id": 1, "name": "wonk"}, m)
+	})
+}
+
 func TestDecodeAnyJsonIter(t *testing.T) {
 	m := map[string]interface{}{
 		"name": "wonk",